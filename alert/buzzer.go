@@ -0,0 +1,105 @@
+package alert
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+// Buzzer is a device that can beep, such as pixoo.Client
+type Buzzer interface {
+	PlayBuzzer(activeMs, offMs, totalMs int) error
+}
+
+// QuietHours is a daily time range, in local time, during which audible
+// alerts are suppressed. The range may wrap past midnight.
+type QuietHours struct {
+	Start time.Duration // offset from midnight
+	End   time.Duration
+}
+
+// ParseQuietHours parses a range such as "22:00-07:00". An empty string
+// disables quiet hours.
+func ParseQuietHours(s string) (*QuietHours, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	var startH, startM, endH, endM int
+	if _, err := fmt.Sscanf(s, "%d:%d-%d:%d", &startH, &startM, &endH, &endM); err != nil {
+		return nil, fmt.Errorf("quiet hours %q: expected HH:MM-HH:MM", s)
+	}
+	for _, v := range []int{startH, endH} {
+		if v < 0 || v > 23 {
+			return nil, fmt.Errorf("quiet hours %q: hour out of range", s)
+		}
+	}
+	for _, v := range []int{startM, endM} {
+		if v < 0 || v > 59 {
+			return nil, fmt.Errorf("quiet hours %q: minute out of range", s)
+		}
+	}
+
+	return &QuietHours{
+		Start: time.Duration(startH)*time.Hour + time.Duration(startM)*time.Minute,
+		End:   time.Duration(endH)*time.Hour + time.Duration(endM)*time.Minute,
+	}, nil
+}
+
+// Contains reports whether t falls inside the quiet period
+func (q *QuietHours) Contains(t time.Time) bool {
+	if q == nil || q.Start == q.End {
+		return false
+	}
+
+	// Clock time, not time elapsed since midnight, which is an hour off
+	// on days the clocks change
+	offset := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute
+	if q.Start < q.End {
+		return offset >= q.Start && offset < q.End
+	}
+	// Range wraps past midnight, e.g. 22:00-07:00
+	return offset >= q.Start || offset < q.End
+}
+
+// BuzzerAction beeps the device when a critical rule starts firing,
+// except during quiet hours
+type BuzzerAction struct {
+	Buzzer Buzzer
+	Quiet  *QuietHours
+
+	// Beep pattern in milliseconds
+	ActiveMs int
+	OffMs    int
+	TotalMs  int
+}
+
+// NewBuzzerAction creates an action with a short triple-beep pattern
+func NewBuzzerAction(b Buzzer, quiet *QuietHours) *BuzzerAction {
+	return &BuzzerAction{
+		Buzzer:   b,
+		Quiet:    quiet,
+		ActiveMs: 300,
+		OffMs:    200,
+		TotalMs:  1500,
+	}
+}
+
+// Handle has the signature expected by Engine.OnEvent. The buzzer is
+// driven from a separate goroutine so evaluation is never blocked on the
+// device.
+func (a *BuzzerAction) Handle(ev Event) {
+	if ev.Status.State != StateFiring || ev.Status.Rule.Severity != Critical {
+		return
+	}
+	if a.Quiet.Contains(time.Now()) {
+		log.Printf("Alert %q: buzzer suppressed during quiet hours", ev.Status.Rule.Name)
+		return
+	}
+
+	go func() {
+		if err := a.Buzzer.PlayBuzzer(a.ActiveMs, a.OffMs, a.TotalMs); err != nil {
+			log.Printf("Alert %q: play buzzer: %v", ev.Status.Rule.Name, err)
+		}
+	}()
+}
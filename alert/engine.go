@@ -0,0 +1,228 @@
+package alert
+
+import (
+	"sync"
+	"time"
+
+	"divoom-monitor/metrics"
+)
+
+// State is where a rule is in its lifecycle
+type State int
+
+const (
+	StateOK State = iota
+	// StatePending means the condition holds but not yet for Rule.For
+	StatePending
+	StateFiring
+)
+
+func (s State) String() string {
+	switch s {
+	case StatePending:
+		return "pending"
+	case StateFiring:
+		return "firing"
+	default:
+		return "ok"
+	}
+}
+
+// Status is the current state of one rule
+type Status struct {
+	Rule  Rule
+	State State
+	// Value is the most recent reading of the rule's metric
+	Value float64
+	// Since is when the rule entered its current state
+	Since        time.Time
+	Acknowledged bool
+}
+
+// Event records a rule changing state during Evaluate
+type Event struct {
+	Status Status
+	From   State
+}
+
+// Engine evaluates rules against metric snapshots. It is safe for
+// concurrent use, so renderers can query it while samples arrive.
+type Engine struct {
+	mu       sync.RWMutex
+	rules    []*Status
+	handlers []func(Event)
+}
+
+func NewEngine(rules []Rule) *Engine {
+	e := &Engine{}
+	for _, r := range rules {
+		e.rules = append(e.rules, &Status{Rule: r})
+	}
+	return e
+}
+
+// OnEvent registers fn to be called for every state change. Handlers run
+// on the goroutine calling Evaluate and must not block.
+func (e *Engine) OnEvent(fn func(Event)) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.handlers = append(e.handlers, fn)
+}
+
+// Observe evaluates the rules against a sampled metric set. It has the
+// signature expected by metrics.Sampler.OnSample.
+func (e *Engine) Observe(m *metrics.SystemMetrics) {
+	e.Evaluate(m.Values, m.Timestamp)
+}
+
+// Evaluate updates every rule from snapshot, notifies handlers and returns
+// the state changes. Rules whose metric is missing from the snapshot keep
+// their state.
+func (e *Engine) Evaluate(snapshot metrics.Snapshot, now time.Time) []Event {
+	events := e.evaluate(snapshot, now)
+
+	e.mu.RLock()
+	handlers := e.handlers
+	e.mu.RUnlock()
+
+	for _, ev := range events {
+		for _, fn := range handlers {
+			fn(ev)
+		}
+	}
+	return events
+}
+
+func (e *Engine) evaluate(snapshot metrics.Snapshot, now time.Time) []Event {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var events []Event
+	for _, st := range e.rules {
+		value, ok := snapshot.Get(st.Rule.Metric)
+		if !ok {
+			continue
+		}
+		st.Value = value
+
+		from := st.State
+		switch st.State {
+		case StateOK:
+			if st.Rule.breached(value) {
+				st.State = StatePending
+				st.Since = now
+			}
+		case StatePending:
+			if !st.Rule.breached(value) {
+				st.State = StateOK
+				st.Since = now
+			}
+		case StateFiring:
+			if st.Rule.recovered(value) {
+				st.State = StateOK
+				st.Since = now
+				st.Acknowledged = false
+			}
+		}
+
+		// A pending rule fires once the condition has held long enough
+		if st.State == StatePending && now.Sub(st.Since) >= st.Rule.For {
+			st.State = StateFiring
+			st.Since = now
+		}
+
+		if st.State != from {
+			events = append(events, Event{Status: *st, From: from})
+		}
+	}
+	return events
+}
+
+// Statuses returns the state of every rule in configuration order
+func (e *Engine) Statuses() []Status {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	out := make([]Status, len(e.rules))
+	for i, st := range e.rules {
+		out[i] = *st
+	}
+	return out
+}
+
+// Firing returns the firing rules, most severe first
+func (e *Engine) Firing() []Status {
+	var critical, warning []Status
+	for _, st := range e.Statuses() {
+		if st.State != StateFiring {
+			continue
+		}
+		if st.Rule.Severity == Critical {
+			critical = append(critical, st)
+		} else {
+			warning = append(warning, st)
+		}
+	}
+	return append(critical, warning...)
+}
+
+// MetricAlert returns the most severe firing rule on metric
+func (e *Engine) MetricAlert(metric string) (Status, bool) {
+	for _, st := range e.Firing() {
+		if st.Rule.Metric == metric {
+			return st, true
+		}
+	}
+	return Status{}, false
+}
+
+// Flashing reports whether any firing rule asks for a flashing border
+func (e *Engine) Flashing() bool {
+	for _, st := range e.Firing() {
+		if st.Rule.Action >= ActionFlash && !st.Acknowledged {
+			return true
+		}
+	}
+	return false
+}
+
+// Takeover returns the most severe unacknowledged takeover alert
+func (e *Engine) Takeover() (Status, bool) {
+	for _, st := range e.Firing() {
+		if st.Rule.Action == ActionTakeover && !st.Acknowledged {
+			return st, true
+		}
+	}
+	return Status{}, false
+}
+
+// Acknowledge silences the flash and takeover of the named firing rule
+// until it clears. It reports whether a firing rule was acknowledged.
+func (e *Engine) Acknowledge(name string) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	found := false
+	for _, st := range e.rules {
+		if st.Rule.Name == name && st.State == StateFiring {
+			st.Acknowledged = true
+			found = true
+		}
+	}
+	return found
+}
+
+// AcknowledgeAll acknowledges every firing rule and returns how many
+func (e *Engine) AcknowledgeAll() int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	n := 0
+	for _, st := range e.rules {
+		if st.State == StateFiring && !st.Acknowledged {
+			st.Acknowledged = true
+			n++
+		}
+	}
+	return n
+}
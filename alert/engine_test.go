@@ -0,0 +1,230 @@
+package alert
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"divoom-monitor/metrics"
+)
+
+var t0 = time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+// step feeds one value at t0+at and expects the rule to end up in want.
+// A NaN value leaves the metric out of the snapshot.
+type step struct {
+	at    time.Duration
+	value float64
+	want  State
+}
+
+func snapshot(metric string, value float64) metrics.Snapshot {
+	if math.IsNaN(value) {
+		return metrics.Snapshot{}
+	}
+	return metrics.Snapshot{metric: {Name: metric, Value: value}}
+}
+
+func mustRule(t *testing.T, spec string) Rule {
+	t.Helper()
+	r, err := ParseRule(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestEngineEvaluate(t *testing.T) {
+	tests := []struct {
+		name  string
+		rule  string
+		steps []step
+	}{
+		{
+			name: "fires right away without for",
+			rule: "cpu.total > 90",
+			steps: []step{
+				{0, 50, StateOK},
+				{time.Second, 95, StateFiring},
+				{2 * time.Second, 91, StateFiring},
+			},
+		},
+		{
+			name: "for must hold",
+			rule: "cpu.total > 90 for 30s",
+			steps: []step{
+				{0, 95, StatePending},
+				{10 * time.Second, 95, StatePending},
+				{29 * time.Second, 99, StatePending},
+				{30 * time.Second, 95, StateFiring},
+			},
+		},
+		{
+			name: "dip restarts for",
+			rule: "cpu.total > 90 for 30s",
+			steps: []step{
+				{0, 95, StatePending},
+				{10 * time.Second, 80, StateOK},
+				{20 * time.Second, 95, StatePending},
+				{45 * time.Second, 95, StatePending},
+				{50 * time.Second, 95, StateFiring},
+			},
+		},
+		{
+			name: "threshold is exclusive for >",
+			rule: "cpu.total > 90",
+			steps: []step{
+				{0, 90, StateOK},
+				{time.Second, 90.1, StateFiring},
+			},
+		},
+		{
+			name: "threshold is inclusive for >=",
+			rule: "cpu.total >= 90",
+			steps: []step{
+				{0, 90, StateFiring},
+				{time.Second, 89.9, StateOK},
+			},
+		},
+		{
+			name: "hysteresis above",
+			rule: "cpu.total > 90 hysteresis=5",
+			steps: []step{
+				{0, 95, StateFiring},
+				{1 * time.Second, 88, StateFiring},
+				{2 * time.Second, 85, StateFiring},
+				{3 * time.Second, 84.9, StateOK},
+				{4 * time.Second, 88, StateOK},
+				{5 * time.Second, 91, StateFiring},
+			},
+		},
+		{
+			name: "hysteresis below",
+			rule: "mem.available_gb < 2 hysteresis=0.5",
+			steps: []step{
+				{0, 1.5, StateFiring},
+				{1 * time.Second, 2.3, StateFiring},
+				{2 * time.Second, 2.5, StateFiring},
+				{3 * time.Second, 2.6, StateOK},
+			},
+		},
+		{
+			name: "no hysteresis clears at the threshold",
+			rule: "cpu.total > 90",
+			steps: []step{
+				{0, 95, StateFiring},
+				{time.Second, 90, StateFiring},
+				{2 * time.Second, 89.9, StateOK},
+			},
+		},
+		{
+			name: "missing metric keeps state",
+			rule: "cpu.total > 90 for 10s",
+			steps: []step{
+				{0, 95, StatePending},
+				{5 * time.Second, math.NaN(), StatePending},
+				{10 * time.Second, 95, StateFiring},
+				{15 * time.Second, math.NaN(), StateFiring},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule := mustRule(t, tt.rule)
+			e := NewEngine([]Rule{rule})
+
+			prev := StateOK
+			for i, s := range tt.steps {
+				now := t0.Add(s.at)
+				events := e.Evaluate(snapshot(rule.Metric, s.value), now)
+
+				st := e.Statuses()[0]
+				if st.State != s.want {
+					t.Fatalf("step %d (%v at +%v): state %v, want %v", i, s.value, s.at, st.State, s.want)
+				}
+				switch {
+				case s.want == prev && len(events) != 0:
+					t.Errorf("step %d: events %+v without a state change", i, events)
+				case s.want != prev && (len(events) != 1 || events[0].From != prev || events[0].Status.State != s.want):
+					t.Errorf("step %d: events %+v, want one %v -> %v", i, events, prev, s.want)
+				case s.want != prev && !st.Since.Equal(now):
+					t.Errorf("step %d: since %v, want %v", i, st.Since, now)
+				}
+				prev = s.want
+			}
+		})
+	}
+}
+
+func TestEngineAcknowledge(t *testing.T) {
+	rule := mustRule(t, "cpu.total > 90 severity=critical action=takeover name=cpu")
+	e := NewEngine([]Rule{rule})
+
+	if e.Acknowledge("cpu") {
+		t.Error("acknowledged a rule that isn't firing")
+	}
+
+	e.Evaluate(snapshot(rule.Metric, 95), t0)
+	if _, ok := e.Takeover(); !ok || !e.Flashing() {
+		t.Fatal("firing takeover rule shows no takeover or flash")
+	}
+	if !e.Acknowledge("cpu") {
+		t.Fatal("Acknowledge found no firing rule")
+	}
+	if _, ok := e.Takeover(); ok || e.Flashing() {
+		t.Error("acknowledged rule still takes over or flashes")
+	}
+
+	// Still firing: the widget stays colored and the ack sticks
+	e.Evaluate(snapshot(rule.Metric, 97), t0.Add(time.Second))
+	if _, ok := e.MetricAlert(rule.Metric); !ok {
+		t.Error("acknowledged rule no longer colors its metric")
+	}
+	if _, ok := e.Takeover(); ok {
+		t.Error("acknowledgement lost while the rule kept firing")
+	}
+
+	// Recovery clears the acknowledgement, so the next breach takes over
+	e.Evaluate(snapshot(rule.Metric, 50), t0.Add(2*time.Second))
+	if st := e.Statuses()[0]; st.State != StateOK || st.Acknowledged {
+		t.Fatalf("after recovery: %+v, want ok and unacknowledged", st)
+	}
+	e.Evaluate(snapshot(rule.Metric, 95), t0.Add(3*time.Second))
+	if _, ok := e.Takeover(); !ok {
+		t.Error("rule firing again after recovery did not take over")
+	}
+	if n := e.AcknowledgeAll(); n != 1 {
+		t.Errorf("AcknowledgeAll = %d, want 1", n)
+	}
+	if n := e.AcknowledgeAll(); n != 0 {
+		t.Errorf("AcknowledgeAll again = %d, want 0", n)
+	}
+}
+
+func TestEngineFiringOrderAndHandlers(t *testing.T) {
+	rules := []Rule{
+		mustRule(t, "cpu.total > 50 name=warn"),
+		mustRule(t, "cpu.total > 80 severity=critical name=crit"),
+		mustRule(t, "mem.used_percent > 90 name=mem"),
+	}
+	e := NewEngine(rules)
+	var seen []string
+	e.OnEvent(func(ev Event) { seen = append(seen, ev.Status.Rule.Name+":"+ev.Status.State.String()) })
+
+	e.Evaluate(metrics.Snapshot{
+		"cpu.total":        {Name: "cpu.total", Value: 85},
+		"mem.used_percent": {Name: "mem.used_percent", Value: 40},
+	}, t0)
+
+	firing := e.Firing()
+	if len(firing) != 2 || firing[0].Rule.Name != "crit" || firing[1].Rule.Name != "warn" {
+		t.Errorf("firing = %+v, want crit then warn", firing)
+	}
+	if st, ok := e.MetricAlert("cpu.total"); !ok || st.Rule.Name != "crit" {
+		t.Errorf("MetricAlert = %+v, %v, want crit", st, ok)
+	}
+	if want := "warn:firing crit:firing"; len(seen) != 2 || seen[0]+" "+seen[1] != want {
+		t.Errorf("handler saw %v, want %s", seen, want)
+	}
+}
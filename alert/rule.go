@@ -0,0 +1,182 @@
+package alert
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Severity ranks how urgent a firing rule is
+type Severity int
+
+const (
+	Warning Severity = iota
+	Critical
+)
+
+func (s Severity) String() string {
+	if s == Critical {
+		return "critical"
+	}
+	return "warning"
+}
+
+// Action is how a firing rule shows up on the panel
+type Action int
+
+const (
+	// ActionColor recolors the widget bound to the rule's metric
+	ActionColor Action = iota
+	// ActionFlash also flashes a border around the dashboard
+	ActionFlash
+	// ActionTakeover replaces the dashboard with an alert page until the
+	// alert is acknowledged or clears
+	ActionTakeover
+)
+
+func (a Action) String() string {
+	switch a {
+	case ActionFlash:
+		return "flash"
+	case ActionTakeover:
+		return "takeover"
+	default:
+		return "color"
+	}
+}
+
+// Rule is a threshold condition on one metric
+type Rule struct {
+	Name      string
+	Metric    string
+	Op        string
+	Threshold float64
+	// For is how long the condition must hold before the rule fires
+	For time.Duration
+	// Hysteresis is how far back past the threshold the value must move
+	// before a firing rule clears, to avoid flapping
+	Hysteresis float64
+	Severity   Severity
+	Action     Action
+}
+
+// ParseRule parses a rule of the form
+//
+//	<metric> <op> <threshold> [for <duration>] [key=value ...]
+//
+// where op is one of > >= < <= and the optional keys are
+// severity=warning|critical, action=color|flash|takeover, hysteresis=<n>
+// and name=<name>. For example:
+//
+//	cpu.total > 90 for 2m severity=critical action=flash hysteresis=5
+//	disk./ > 95 action=takeover
+func ParseRule(s string) (Rule, error) {
+	fields := strings.Fields(s)
+	if len(fields) < 3 {
+		return Rule{}, fmt.Errorf("rule %q: expected <metric> <op> <threshold>", s)
+	}
+
+	r := Rule{
+		Name:   strings.Join(fields[:3], " "),
+		Metric: fields[0],
+		Op:     fields[1],
+	}
+
+	switch r.Op {
+	case ">", ">=", "<", "<=":
+	default:
+		return Rule{}, fmt.Errorf("rule %q: unknown operator %q", s, r.Op)
+	}
+
+	threshold, err := strconv.ParseFloat(fields[2], 64)
+	if err != nil {
+		return Rule{}, fmt.Errorf("rule %q: invalid threshold: %w", s, err)
+	}
+	r.Threshold = threshold
+
+	rest := fields[3:]
+	if len(rest) >= 2 && rest[0] == "for" {
+		d, err := time.ParseDuration(rest[1])
+		if err != nil {
+			return Rule{}, fmt.Errorf("rule %q: invalid duration: %w", s, err)
+		}
+		r.For = d
+		rest = rest[2:]
+	}
+
+	for _, opt := range rest {
+		key, value, ok := strings.Cut(opt, "=")
+		if !ok {
+			return Rule{}, fmt.Errorf("rule %q: unexpected %q", s, opt)
+		}
+
+		switch key {
+		case "severity":
+			switch value {
+			case "warning":
+				r.Severity = Warning
+			case "critical":
+				r.Severity = Critical
+			default:
+				return Rule{}, fmt.Errorf("rule %q: unknown severity %q", s, value)
+			}
+		case "action":
+			switch value {
+			case "color":
+				r.Action = ActionColor
+			case "flash":
+				r.Action = ActionFlash
+			case "takeover":
+				r.Action = ActionTakeover
+			default:
+				return Rule{}, fmt.Errorf("rule %q: unknown action %q", s, value)
+			}
+		case "hysteresis":
+			h, err := strconv.ParseFloat(value, 64)
+			if err != nil || h < 0 {
+				return Rule{}, fmt.Errorf("rule %q: invalid hysteresis %q", s, value)
+			}
+			r.Hysteresis = h
+		case "name":
+			r.Name = value
+		default:
+			return Rule{}, fmt.Errorf("rule %q: unknown option %q", s, key)
+		}
+	}
+
+	return r, nil
+}
+
+// breached reports whether value violates the rule's threshold
+func (r Rule) breached(value float64) bool {
+	switch r.Op {
+	case ">":
+		return value > r.Threshold
+	case ">=":
+		return value >= r.Threshold
+	case "<":
+		return value < r.Threshold
+	default: // "<="
+		return value <= r.Threshold
+	}
+}
+
+// recovered reports whether value has moved far enough past the
+// threshold, including hysteresis, for a firing rule to clear
+func (r Rule) recovered(value float64) bool {
+	switch r.Op {
+	case ">", ">=":
+		return value < r.Threshold-r.Hysteresis
+	default:
+		return value > r.Threshold+r.Hysteresis
+	}
+}
+
+func (r Rule) String() string {
+	s := fmt.Sprintf("%s %s %g", r.Metric, r.Op, r.Threshold)
+	if r.For > 0 {
+		s += " for " + r.For.String()
+	}
+	return s
+}
@@ -0,0 +1,155 @@
+package alert
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseRule(t *testing.T) {
+	tests := []struct {
+		spec string
+		want Rule
+	}{
+		{
+			spec: "cpu.total > 90",
+			want: Rule{Name: "cpu.total > 90", Metric: "cpu.total", Op: ">", Threshold: 90},
+		},
+		{
+			spec: "cpu.total > 90 for 2m severity=critical action=flash hysteresis=5",
+			want: Rule{
+				Name: "cpu.total > 90", Metric: "cpu.total", Op: ">", Threshold: 90,
+				For: 2 * time.Minute, Hysteresis: 5, Severity: Critical, Action: ActionFlash,
+			},
+		},
+		{
+			spec: "disk./ >= 95.5 action=takeover name=disk",
+			want: Rule{Name: "disk", Metric: "disk./", Op: ">=", Threshold: 95.5, Action: ActionTakeover},
+		},
+		{
+			spec: "mem.available_gb <= 0.5 severity=warning action=color",
+			want: Rule{Name: "mem.available_gb <= 0.5", Metric: "mem.available_gb", Op: "<=", Threshold: 0.5},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			got, err := ParseRule(tt.spec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseRuleErrors(t *testing.T) {
+	tests := []struct {
+		spec, wantErr string
+	}{
+		{"", "expected <metric> <op> <threshold>"},
+		{"cpu.total >", "expected <metric> <op> <threshold>"},
+		{"cpu.total == 90", `unknown operator "=="`},
+		{"cpu.total > ninety", "invalid threshold"},
+		{"cpu.total > 90 for soon", "invalid duration"},
+		{"cpu.total > 90 loud", `unexpected "loud"`},
+		{"cpu.total > 90 severity=fatal", `unknown severity "fatal"`},
+		{"cpu.total > 90 action=blink", `unknown action "blink"`},
+		{"cpu.total > 90 hysteresis=-1", `invalid hysteresis "-1"`},
+		{"cpu.total > 90 hysteresis=lots", `invalid hysteresis "lots"`},
+		{"cpu.total > 90 colour=red", `unknown option "colour"`},
+		// for must come right after the threshold
+		{"cpu.total > 90 severity=critical for 2m", `unexpected "for"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			_, err := ParseRule(tt.spec)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("err = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseQuietHours(t *testing.T) {
+	q, err := ParseQuietHours("22:30-07:05")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q.Start != 22*time.Hour+30*time.Minute || q.End != 7*time.Hour+5*time.Minute {
+		t.Errorf("got %+v", q)
+	}
+	if q, err := ParseQuietHours(""); q != nil || err != nil {
+		t.Errorf("empty = %+v, %v, want disabled", q, err)
+	}
+	for _, s := range []string{"22-07", "24:00-07:00", "22:00-07:60", "late"} {
+		if _, err := ParseQuietHours(s); err == nil {
+			t.Errorf("ParseQuietHours(%q) succeeded", s)
+		}
+	}
+}
+
+func TestQuietHoursContains(t *testing.T) {
+	at := func(h, m int) time.Time { return time.Date(2026, 6, 1, h, m, 0, 0, time.UTC) }
+	tests := []struct {
+		spec string
+		t    time.Time
+		want bool
+	}{
+		{"09:00-17:00", at(8, 59), false},
+		{"09:00-17:00", at(9, 0), true},
+		{"09:00-17:00", at(16, 59), true},
+		{"09:00-17:00", at(17, 0), false},
+		// Wraps past midnight
+		{"22:00-07:00", at(21, 59), false},
+		{"22:00-07:00", at(22, 0), true},
+		{"22:00-07:00", at(23, 59), true},
+		{"22:00-07:00", at(0, 0), true},
+		{"22:00-07:00", at(6, 59), true},
+		{"22:00-07:00", at(7, 0), false},
+		{"22:00-07:00", at(12, 0), false},
+		// An empty range is never quiet
+		{"07:00-07:00", at(7, 0), false},
+	}
+	for _, tt := range tests {
+		q, err := ParseQuietHours(tt.spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := q.Contains(tt.t); got != tt.want {
+			t.Errorf("%s contains %s = %v, want %v", tt.spec, tt.t.Format("15:04"), got, tt.want)
+		}
+	}
+
+	var disabled *QuietHours
+	if disabled.Contains(at(3, 0)) {
+		t.Error("nil quiet hours contain 03:00")
+	}
+}
+
+func TestQuietHoursContainsOnDSTDays(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("no time zone data: %v", err)
+	}
+	q, _ := ParseQuietHours("22:00-07:00")
+
+	tests := []struct {
+		name string
+		t    time.Time
+		want bool
+	}{
+		// Clocks go forward at 02:00, so only 21.5h have passed by 22:30
+		{"spring forward, quiet", time.Date(2026, 3, 8, 22, 30, 0, 0, loc), true},
+		{"spring forward, awake", time.Date(2026, 3, 8, 7, 30, 0, 0, loc), false},
+		// Clocks go back at 02:00, so 7.5h have passed by 06:30
+		{"fall back, quiet", time.Date(2026, 11, 1, 6, 30, 0, 0, loc), true},
+		{"fall back, awake", time.Date(2026, 11, 1, 21, 30, 0, 0, loc), false},
+	}
+	for _, tt := range tests {
+		if got := q.Contains(tt.t); got != tt.want {
+			t.Errorf("%s: contains %s = %v, want %v", tt.name, tt.t.Format("15:04 MST"), got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,376 @@
+// Package control exposes a local REST API for steering a running
+// monitor: switching pages, pushing messages and images, and reading back
+// what the panel currently shows.
+package control
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif"  // register GIF decoder for POST /image
+	_ "image/jpeg" // register JPEG decoder for POST /image
+	"image/png"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"divoom-monitor/notify"
+)
+
+// maxImageBytes bounds POST /image uploads
+const maxImageBytes = 4 << 20
+
+// ErrUnknownPage is returned by Display.SetPage for unknown page names
+var ErrUnknownPage = errors.New("unknown page")
+
+// AlertState describes one alert rule in GET /state
+type AlertState struct {
+	Name         string    `json:"name"`
+	Metric       string    `json:"metric"`
+	State        string    `json:"state"`
+	Severity     string    `json:"severity"`
+	Value        float64   `json:"value"`
+	Since        time.Time `json:"since"`
+	Acknowledged bool      `json:"acknowledged"`
+}
+
+// MessageState describes one queued notification in GET /state
+type MessageState struct {
+	Key      string    `json:"key"`
+	Text     string    `json:"text"`
+	Priority string    `json:"priority"`
+	Count    int       `json:"count"`
+	Expires  time.Time `json:"expires"`
+}
+
+// DeviceState describes one panel in GET /state
+type DeviceState struct {
+	Name string `json:"name"`
+	// Pages is the panel's own page set; empty when it mirrors Page
+	Pages       []string   `json:"pages,omitempty"`
+	Online      bool       `json:"online"`
+	Failures    int        `json:"failures,omitempty"`
+	LastError   string     `json:"last_error,omitempty"`
+	LastSuccess *time.Time `json:"last_success,omitempty"`
+}
+
+// State is the monitor state reported by GET /state
+type State struct {
+	Page          string             `json:"page"`
+	Pages         []string           `json:"pages"`
+	Brightness    int                `json:"brightness"`
+	Override      string             `json:"override,omitempty"`
+	OverrideUntil *time.Time         `json:"override_until,omitempty"`
+	Alerts        []AlertState       `json:"alerts,omitempty"`
+	Messages      []MessageState     `json:"messages,omitempty"`
+	Devices       []DeviceState      `json:"devices,omitempty"`
+	Metrics       map[string]float64 `json:"metrics,omitempty"`
+	LastFrame     *time.Time         `json:"last_frame,omitempty"`
+}
+
+// Display is implemented by the running monitor. Implementations record
+// the request and let their own render loop talk to the device, so API
+// callers never race the loop for it.
+type Display interface {
+	SetPage(name string) error
+	// ShowMessage queues a notification
+	ShowMessage(msg notify.Message) error
+	// ClearMessage removes the queued notification with key
+	ClearMessage(key string) bool
+	ShowImage(img image.Image, d time.Duration) error
+	SetBrightness(brightness int) error
+	// Acknowledge silences firing alerts and returns how many
+	Acknowledge() int
+	State() State
+	// Frame returns the last frame sent to the panel, or nil
+	Frame() image.Image
+}
+
+// Server routes the REST API to a Display
+type Server struct {
+	display Display
+	mux     *http.ServeMux
+}
+
+func NewServer(display Display) *Server {
+	s := &Server{display: display, mux: http.NewServeMux()}
+
+	s.mux.HandleFunc("POST /page", s.handlePage)
+	s.mux.HandleFunc("POST /message", s.handleMessage)
+	s.mux.HandleFunc("DELETE /message/{key}", s.handleClearMessage)
+	s.mux.HandleFunc("POST /brightness", s.handleBrightness)
+	s.mux.HandleFunc("POST /image", s.handleImage)
+	s.mux.HandleFunc("POST /ack", s.handleAck)
+	s.mux.HandleFunc("GET /state", s.handleState)
+	s.mux.HandleFunc("GET /frame.png", s.handleFrame)
+
+	return s
+}
+
+// Handle mounts an additional handler on the API mux
+func (s *Server) Handle(pattern string, h http.Handler) {
+	s.mux.Handle(pattern, h)
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+func (s *Server) handlePage(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Page string `json:"page"`
+	}
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := s.display.SetPage(req.Page); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, ErrUnknownPage) {
+			status = http.StatusBadRequest
+		}
+		writeError(w, status, err)
+		return
+	}
+	writeJSON(w, s.display.State())
+}
+
+// MessageRequest is the JSON body of POST /message, shared with other
+// transports (MQTT, webhooks) that accept notifications
+type MessageRequest struct {
+	Text     string `json:"text"`
+	Scroll   string `json:"scroll"`
+	Key      string `json:"key"`
+	Color    string `json:"color"`
+	Icon     string `json:"icon"`
+	Priority string `json:"priority"`
+	TTL      string `json:"ttl"`
+	// Duration is accepted as an alias for TTL
+	Duration string `json:"duration"`
+}
+
+// Message validates the request and converts it to a notification
+func (req MessageRequest) Message() (notify.Message, error) {
+	if req.Text == "" {
+		return notify.Message{}, fmt.Errorf("text is required")
+	}
+	if !notify.ValidIcon(req.Icon) {
+		return notify.Message{}, fmt.Errorf("unknown icon %q (available: %s)", req.Icon, strings.Join(notify.Icons(), ", "))
+	}
+
+	msg := notify.Message{Text: req.Text, Scroll: req.Scroll, Key: req.Key, Icon: req.Icon, Color: color.RGBA{255, 255, 255, 255}}
+	if req.Color != "" {
+		c, err := ParseColor(req.Color)
+		if err != nil {
+			return notify.Message{}, err
+		}
+		msg.Color = c
+	}
+
+	priority, err := notify.ParsePriority(req.Priority)
+	if err != nil {
+		return notify.Message{}, err
+	}
+	msg.Priority = priority
+
+	if ttl := req.TTL; ttl != "" || req.Duration != "" {
+		if ttl == "" {
+			ttl = req.Duration
+		}
+		d, err := time.ParseDuration(ttl)
+		if err != nil {
+			return notify.Message{}, fmt.Errorf("invalid ttl: %w", err)
+		}
+		msg.TTL = d
+	}
+	return msg, nil
+}
+
+func (s *Server) handleMessage(w http.ResponseWriter, r *http.Request) {
+	var req MessageRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	msg, err := req.Message()
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := s.display.ShowMessage(msg); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, s.display.State())
+}
+
+func (s *Server) handleClearMessage(w http.ResponseWriter, r *http.Request) {
+	if !s.display.ClearMessage(r.PathValue("key")) {
+		writeError(w, http.StatusNotFound, fmt.Errorf("no queued message with key %q", r.PathValue("key")))
+		return
+	}
+	writeJSON(w, s.display.State())
+}
+
+func (s *Server) handleBrightness(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Brightness *int `json:"brightness"`
+	}
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if req.Brightness == nil || *req.Brightness < 0 || *req.Brightness > 100 {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("brightness must be between 0 and 100"))
+		return
+	}
+
+	if err := s.display.SetBrightness(*req.Brightness); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, s.display.State())
+}
+
+// handleImage accepts a raw PNG, JPEG or GIF body. The optional duration
+// query parameter controls how long it stays up (default 30s) and must be
+// positive.
+func (s *Server) handleImage(w http.ResponseWriter, r *http.Request) {
+	d := 30 * time.Second
+	if v := r.URL.Query().Get("duration"); v != "" {
+		parsed, err := time.ParseDuration(v)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid duration: %w", err))
+			return
+		}
+		if parsed <= 0 {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("duration must be positive, got %s", v))
+			return
+		}
+		d = parsed
+	}
+
+	img, err := DecodeImage(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := s.display.ShowImage(img, d); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, s.display.State())
+}
+
+// DecodeImage reads a PNG, JPEG or GIF and checks that it is 64x64
+func DecodeImage(r io.Reader) (image.Image, error) {
+	img, _, err := image.Decode(io.LimitReader(r, maxImageBytes))
+	if err != nil {
+		return nil, fmt.Errorf("decode image: %w", err)
+	}
+	if b := img.Bounds(); b.Dx() != 64 || b.Dy() != 64 {
+		return nil, fmt.Errorf("image must be 64x64 pixels, got %dx%d", b.Dx(), b.Dy())
+	}
+	return img, nil
+}
+
+func (s *Server) handleAck(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, map[string]int{"acknowledged": s.display.Acknowledge()})
+}
+
+func (s *Server) handleState(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, s.display.State())
+}
+
+// handleFrame returns the last frame, upscaled by the optional scale
+// query parameter (1-16) so it is viewable in a browser
+func (s *Server) handleFrame(w http.ResponseWriter, r *http.Request) {
+	frame := s.display.Frame()
+	if frame == nil {
+		writeError(w, http.StatusNotFound, fmt.Errorf("no frame rendered yet"))
+		return
+	}
+
+	scale := 1
+	if v := r.URL.Query().Get("scale"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 16 {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("scale must be between 1 and 16"))
+			return
+		}
+		scale = n
+	}
+
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Cache-Control", "no-store")
+	png.Encode(w, upscale(frame, scale))
+}
+
+// upscale enlarges img by an integer factor with nearest-neighbor sampling
+func upscale(img image.Image, scale int) image.Image {
+	if scale == 1 {
+		return img
+	}
+
+	b := img.Bounds()
+	out := image.NewRGBA(image.Rect(0, 0, b.Dx()*scale, b.Dy()*scale))
+	for y := 0; y < out.Bounds().Dy(); y++ {
+		for x := 0; x < out.Bounds().Dx(); x++ {
+			out.Set(x, y, img.At(b.Min.X+x/scale, b.Min.Y+y/scale))
+		}
+	}
+	return out
+}
+
+// ParseColor parses "#rrggbb", "rrggbb" or a basic color name
+func ParseColor(s string) (color.RGBA, error) {
+	switch strings.ToLower(s) {
+	case "white":
+		return color.RGBA{255, 255, 255, 255}, nil
+	case "red":
+		return color.RGBA{255, 0, 0, 255}, nil
+	case "green":
+		return color.RGBA{0, 255, 0, 255}, nil
+	case "blue":
+		return color.RGBA{0, 150, 255, 255}, nil
+	case "yellow":
+		return color.RGBA{255, 220, 0, 255}, nil
+	case "orange":
+		return color.RGBA{255, 160, 0, 255}, nil
+	}
+
+	hex := strings.TrimPrefix(s, "#")
+	if len(hex) != 6 {
+		return color.RGBA{}, fmt.Errorf("invalid color %q", s)
+	}
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return color.RGBA{}, fmt.Errorf("invalid color %q", s)
+	}
+	return color.RGBA{uint8(v >> 16), uint8(v >> 8), uint8(v), 255}, nil
+}
+
+func decodeJSON(r *http.Request, v interface{}) error {
+	if err := json.NewDecoder(io.LimitReader(r.Body, 1<<20)).Decode(v); err != nil {
+		return fmt.Errorf("decode request: %w", err)
+	}
+	return nil
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+}
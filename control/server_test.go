@@ -0,0 +1,311 @@
+package control
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"divoom-monitor/notify"
+)
+
+// fakeDisplay records what the API asks of the monitor
+type fakeDisplay struct {
+	mu         sync.Mutex
+	page       string
+	brightness int
+	messages   []notify.Message
+	image      image.Image
+	imageFor   time.Duration
+	frame      image.Image
+}
+
+func (d *fakeDisplay) SetPage(name string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if name != "dashboard" && name != "text" {
+		return fmt.Errorf("%w %q", ErrUnknownPage, name)
+	}
+	d.page = name
+	return nil
+}
+
+func (d *fakeDisplay) ShowMessage(msg notify.Message) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.messages = append(d.messages, msg)
+	return nil
+}
+
+func (d *fakeDisplay) ClearMessage(key string) bool { return key == "queued" }
+
+func (d *fakeDisplay) ShowImage(img image.Image, dur time.Duration) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.image, d.imageFor = img, dur
+	return nil
+}
+
+func (d *fakeDisplay) SetBrightness(brightness int) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.brightness = brightness
+	return nil
+}
+
+func (d *fakeDisplay) Acknowledge() int { return 2 }
+
+func (d *fakeDisplay) State() State {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return State{Page: d.page, Pages: []string{"dashboard", "text"}, Brightness: d.brightness}
+}
+
+func (d *fakeDisplay) Frame() image.Image {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.frame
+}
+
+func do(t *testing.T, h http.Handler, method, path string, body []byte) *httptest.ResponseRecorder {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(method, path, bytes.NewReader(body)))
+	return rec
+}
+
+// testPNG encodes a w x h image filled with c
+func testPNG(t *testing.T, w, h int, c color.RGBA) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for i := 0; i < len(img.Pix); i += 4 {
+		img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = c.R, c.G, c.B, c.A
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// errorOf decodes the error body of a failed request
+func errorOf(t *testing.T, rec *httptest.ResponseRecorder) string {
+	t.Helper()
+	var body struct{ Error string }
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("error body %q: %v", rec.Body, err)
+	}
+	return body.Error
+}
+
+func TestPostPage(t *testing.T) {
+	d := &fakeDisplay{}
+	s := NewServer(d)
+
+	rec := do(t, s, http.MethodPost, "/page", []byte(`{"page":"text"}`))
+	if rec.Code != http.StatusOK || d.page != "text" {
+		t.Fatalf("status %d, page %q", rec.Code, d.page)
+	}
+	var st State
+	if err := json.Unmarshal(rec.Body.Bytes(), &st); err != nil || st.Page != "text" {
+		t.Errorf("state %+v, %v, want page text", st, err)
+	}
+
+	for body, want := range map[string]string{
+		`{"page":"clock"}`: `unknown page "clock"`,
+		`{"page":`:         "decode request",
+	} {
+		rec := do(t, s, http.MethodPost, "/page", []byte(body))
+		if rec.Code != http.StatusBadRequest || !strings.Contains(errorOf(t, rec), want) {
+			t.Errorf("%s: status %d %q, want 400 %q", body, rec.Code, rec.Body, want)
+		}
+	}
+}
+
+func TestPostMessage(t *testing.T) {
+	d := &fakeDisplay{}
+	s := NewServer(d)
+
+	rec := do(t, s, http.MethodPost, "/message",
+		[]byte(`{"text":"DEPLOY","scroll":"v1.2 is live","key":"deploy","color":"#00ff80","icon":"check","priority":"high","ttl":"5m"}`))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	want := notify.Message{
+		Text: "DEPLOY", Scroll: "v1.2 is live", Key: "deploy", Icon: "check",
+		Color: color.RGBA{0, 255, 128, 255}, Priority: notify.High, TTL: 5 * time.Minute,
+	}
+	if len(d.messages) != 1 || d.messages[0] != want {
+		t.Errorf("messages = %+v, want %+v", d.messages, want)
+	}
+
+	// duration is an alias for ttl
+	do(t, s, http.MethodPost, "/message", []byte(`{"text":"hi","duration":"10s"}`))
+	if got := d.messages[1]; got.TTL != 10*time.Second || got.Color != (color.RGBA{255, 255, 255, 255}) {
+		t.Errorf("message = %+v, want a white 10s message", got)
+	}
+
+	tests := []struct{ body, wantErr string }{
+		{`{"text":`, "decode request"},
+		{`{"scroll":"no text"}`, "text is required"},
+		{`{"text":"hi","color":"chartreuse"}`, `invalid color "chartreuse"`},
+		{`{"text":"hi","color":"#12345"}`, `invalid color "#12345"`},
+		{`{"text":"hi","icon":"rocket"}`, `unknown icon "rocket"`},
+		{`{"text":"hi","priority":"urgent"}`, "urgent"},
+		{`{"text":"hi","ttl":"soon"}`, "invalid ttl"},
+	}
+	for _, tt := range tests {
+		rec := do(t, s, http.MethodPost, "/message", []byte(tt.body))
+		if rec.Code != http.StatusBadRequest || !strings.Contains(errorOf(t, rec), tt.wantErr) {
+			t.Errorf("%s: status %d %q, want 400 %q", tt.body, rec.Code, rec.Body, tt.wantErr)
+		}
+	}
+	if len(d.messages) != 2 {
+		t.Errorf("invalid messages were queued: %+v", d.messages[2:])
+	}
+
+	if rec := do(t, s, http.MethodDelete, "/message/queued", nil); rec.Code != http.StatusOK {
+		t.Errorf("DELETE queued: status %d", rec.Code)
+	}
+	if rec := do(t, s, http.MethodDelete, "/message/other", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("DELETE other: status %d, want 404", rec.Code)
+	}
+}
+
+func TestPostBrightness(t *testing.T) {
+	d := &fakeDisplay{brightness: 50}
+	s := NewServer(d)
+
+	for _, v := range []int{0, 100, 35} {
+		rec := do(t, s, http.MethodPost, "/brightness", []byte(fmt.Sprintf(`{"brightness":%d}`, v)))
+		if rec.Code != http.StatusOK || d.brightness != v {
+			t.Errorf("brightness %d: status %d, display at %d", v, rec.Code, d.brightness)
+		}
+	}
+	for _, body := range []string{`{"brightness":-1}`, `{"brightness":101}`, `{}`, `{"brightness":"high"}`, `not json`} {
+		rec := do(t, s, http.MethodPost, "/brightness", []byte(body))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400", body, rec.Code)
+		}
+	}
+	if d.brightness != 35 {
+		t.Errorf("rejected requests changed brightness to %d", d.brightness)
+	}
+}
+
+func TestPostImage(t *testing.T) {
+	d := &fakeDisplay{}
+	s := NewServer(d)
+	red := testPNG(t, 64, 64, color.RGBA{255, 0, 0, 255})
+
+	rec := do(t, s, http.MethodPost, "/image", red)
+	if rec.Code != http.StatusOK || d.imageFor != 30*time.Second {
+		t.Fatalf("status %d, shown for %v, want 200 for the default 30s", rec.Code, d.imageFor)
+	}
+	if r, _, _, _ := d.image.At(10, 10).RGBA(); r>>8 != 255 {
+		t.Errorf("decoded image is not red")
+	}
+	if rec := do(t, s, http.MethodPost, "/image?duration=2m", red); rec.Code != http.StatusOK || d.imageFor != 2*time.Minute {
+		t.Errorf("duration=2m: status %d, shown for %v", rec.Code, d.imageFor)
+	}
+
+	tests := []struct {
+		name, path string
+		body       []byte
+		wantErr    string
+	}{
+		{"zero duration", "/image?duration=0s", red, "duration must be positive"},
+		{"negative duration", "/image?duration=-5s", red, "duration must be positive"},
+		{"bad duration", "/image?duration=forever", red, "invalid duration"},
+		{"wrong size", "/image", testPNG(t, 32, 32, color.RGBA{0, 0, 255, 255}), "must be 64x64 pixels, got 32x32"},
+		{"not an image", "/image", []byte("hello"), "decode image"},
+	}
+	for _, tt := range tests {
+		rec := do(t, s, http.MethodPost, tt.path, tt.body)
+		if rec.Code != http.StatusBadRequest || !strings.Contains(errorOf(t, rec), tt.wantErr) {
+			t.Errorf("%s: status %d %q, want 400 %q", tt.name, rec.Code, rec.Body, tt.wantErr)
+		}
+	}
+	if d.imageFor != 2*time.Minute {
+		t.Errorf("a rejected image was shown for %v", d.imageFor)
+	}
+}
+
+func TestGetState(t *testing.T) {
+	d := &fakeDisplay{page: "dashboard", brightness: 70}
+	s := NewServer(d)
+
+	rec := do(t, s, http.MethodGet, "/state", nil)
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("status %d, content type %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	var st State
+	if err := json.Unmarshal(rec.Body.Bytes(), &st); err != nil {
+		t.Fatal(err)
+	}
+	if st.Page != "dashboard" || st.Brightness != 70 || len(st.Pages) != 2 {
+		t.Errorf("state = %+v", st)
+	}
+
+	if rec := do(t, s, http.MethodPost, "/state", nil); rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST /state: status %d, want 405", rec.Code)
+	}
+	if rec := do(t, s, http.MethodPost, "/ack", nil); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"acknowledged":2`) {
+		t.Errorf("POST /ack: status %d %q", rec.Code, rec.Body)
+	}
+}
+
+func TestGetFrame(t *testing.T) {
+	d := &fakeDisplay{}
+	s := NewServer(d)
+
+	if rec := do(t, s, http.MethodGet, "/frame.png", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("before the first frame: status %d, want 404", rec.Code)
+	}
+
+	frame := image.NewRGBA(image.Rect(0, 0, 64, 64))
+	frame.Set(1, 0, color.RGBA{0, 255, 0, 255})
+	d.frame = frame
+
+	tests := []struct {
+		query string
+		size  int
+	}{
+		{"", 64},
+		{"?scale=4", 256},
+	}
+	for _, tt := range tests {
+		rec := do(t, s, http.MethodGet, "/frame.png"+tt.query, nil)
+		if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "image/png" {
+			t.Fatalf("%q: status %d, content type %q", tt.query, rec.Code, rec.Header().Get("Content-Type"))
+		}
+		img, err := png.Decode(rec.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if b := img.Bounds(); b.Dx() != tt.size || b.Dy() != tt.size {
+			t.Errorf("%q: got %dx%d, want %d", tt.query, b.Dx(), b.Dy(), tt.size)
+		}
+		scale := tt.size / 64
+		if _, g, _, _ := img.At(scale, 0).RGBA(); g>>8 != 255 {
+			t.Errorf("%q: green pixel missing at %d,0", tt.query, scale)
+		}
+		if _, g, _, _ := img.At(2*scale, 0).RGBA(); g != 0 {
+			t.Errorf("%q: pixel past the green one is lit", tt.query)
+		}
+	}
+
+	for _, q := range []string{"?scale=0", "?scale=17", "?scale=big"} {
+		if rec := do(t, s, http.MethodGet, "/frame.png"+q, nil); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400", q, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,265 @@
+// Package dashboard draws the monitor's pages. Rendering is kept apart
+// from sending: a page depends only on its Input, so the same metrics and
+// alert state always give the same frame.
+package dashboard
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+
+	"divoom-monitor/alert"
+	"divoom-monitor/metrics"
+	"divoom-monitor/pixoo"
+)
+
+const (
+	// PageDashboard shows bars, numbers and a CPU sparkline
+	PageDashboard = "dashboard"
+	// PageText is one line for the device's own text renderer
+	PageText = "text"
+)
+
+// Pages lists the pages Render draws
+var Pages = []string{PageDashboard, PageText}
+
+var (
+	WarningColor  = color.RGBA{255, 160, 0, 255} // Amber for warnings
+	CriticalColor = color.RGBA{255, 0, 0, 255}   // Red for critical alerts
+)
+
+// Alerts is the alert state pages react to. *alert.Engine implements it.
+type Alerts interface {
+	Firing() []alert.Status
+	MetricAlert(metric string) (alert.Status, bool)
+	Flashing() bool
+	Takeover() (alert.Status, bool)
+}
+
+// Input is everything a page is drawn from
+type Input struct {
+	Metrics *metrics.SystemMetrics
+	// CPUHistory is plotted along the bottom, oldest first
+	CPUHistory []metrics.Sample
+	// Alerts may be nil when no rules are set up
+	Alerts Alerts
+	// FlashOn is the phase of flashing alert borders, toggled every update
+	FlashOn bool
+}
+
+// Frame is a rendered page
+type Frame struct {
+	Image *image.RGBA
+	// Text, when set, is meant for the device's text renderer in
+	// TextColor; Image is then a local approximation of it
+	Text      string
+	TextColor color.RGBA
+	// Takeover is set when a takeover alert replaced the page
+	Takeover bool
+}
+
+// Render draws page; unknown pages get the dashboard
+func Render(page string, in Input) Frame {
+	return RenderSize(page, in, 64, 64)
+}
+
+// RenderSize draws page on a width x height canvas such as a video wall,
+// both multiples of 64. The dashboard is laid out across the whole
+// canvas; the text page and takeover alerts stay 64x64 for the caller to
+// scale up.
+func RenderSize(page string, in Input, width, height int) Frame {
+	takeover, hasTakeover := in.takeover()
+
+	// Text-only mode (faster, uses Pixoo's built-in text rendering)
+	if page == PageText {
+		m := in.Metrics
+		text := fmt.Sprintf("CPU:%.0f%% MEM:%.0f%% %.1fG",
+			m.CPUPercent, m.MemoryPercent, m.MemoryUsedGB)
+		textColor := color.RGBA{255, 255, 255, 255}
+		if hasTakeover {
+			text = fmt.Sprintf("ALERT %s %.1f", takeover.Rule.Metric, takeover.Value)
+			textColor = CriticalColor
+		} else if firing := in.firing(); len(firing) > 0 {
+			textColor = in.alertColor(firing[0].Rule.Metric, textColor)
+		}
+		return Frame{Image: pixoo.RenderText(text, textColor), Text: text, TextColor: textColor, Takeover: hasTakeover}
+	}
+
+	// Image mode (slower but with graphics)
+	if hasTakeover {
+		img := pixoo.CreateImage()
+		drawAlertPage(img, takeover, in.FlashOn)
+		return Frame{Image: img, Takeover: true}
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, max(width, 64), max(height, 64)))
+	drawDashboard(img, in)
+	return Frame{Image: img}
+}
+
+// drawDashboard lays the dashboard out across img. Text and spacing grow
+// by the largest whole factor that fits; bars and the sparkline span the
+// full width.
+func drawDashboard(img *image.RGBA, in Input) {
+	m := in.Metrics
+	w, h := img.Bounds().Dx(), img.Bounds().Dy()
+	s := min(w, h) / 64
+
+	// Colors
+	bgColor := color.RGBA{0, 0, 0, 255}         // Black background
+	cpuColor := color.RGBA{0, 255, 0, 255}      // Green for CPU
+	memColor := color.RGBA{0, 150, 255, 255}    // Blue for Memory
+	textColor := color.RGBA{255, 255, 255, 255} // White text
+
+	// Bars turn amber/red while an alert on their metric is firing
+	cpuColor = in.alertColor(metrics.MetricCPUTotal, cpuColor)
+	memColor = in.alertColor(metrics.MetricMemPercent, memColor)
+
+	// Fill background
+	pixoo.FillRect(img, 0, 0, w, h, bgColor)
+
+	// Draw title
+	drawText(img, "CPU:", 2*s, 2*s, s, textColor)
+	cpuText := fmt.Sprintf("%2.0f%%", m.CPUPercent)
+	drawText(img, cpuText, 30*s, 2*s, s, textColor)
+
+	// Draw CPU bar
+	barWidth := w - 4*s
+	cpuBarWidth := int((m.CPUPercent / 100.0) * float64(barWidth))
+	pixoo.FillRect(img, 2*s, 12*s, 2*s+cpuBarWidth, 16*s, cpuColor)
+
+	// Draw memory info
+	drawText(img, "MEM:", 2*s, 20*s, s, textColor)
+	memText := fmt.Sprintf("%2.0f%%", m.MemoryPercent)
+	drawText(img, memText, 30*s, 20*s, s, textColor)
+
+	// Draw memory bar
+	memBarWidth := int((m.MemoryPercent / 100.0) * float64(barWidth))
+	pixoo.FillRect(img, 2*s, 30*s, 2*s+memBarWidth, 34*s, memColor)
+
+	// Draw memory usage in GB
+	memGBText := fmt.Sprintf("%.1fG", m.MemoryUsedGB)
+	drawText(img, memGBText, 2*s, 38*s, s, textColor)
+
+	// Draw network stats (if available)
+	if m.NetRecvMB > 0 || m.NetSentMB > 0 {
+		netText := fmt.Sprintf("%.1fM", m.NetRecvMB)
+		drawText(img, netText, 2*s, 50*s, s, textColor)
+	}
+
+	// Draw CPU sparkline for the last minute along the bottom edge
+	drawSparkline(img, in.CPUHistory, 2*s, 58*s, barWidth, h-58*s, cpuColor)
+
+	// Flash a border while a flashing alert is unacknowledged
+	if in.Alerts != nil && in.Alerts.Flashing() && in.FlashOn {
+		drawBorder(img, CriticalColor)
+	}
+}
+
+// drawText draws text with the built-in font, each font pixel scale
+// pixels wide
+func drawText(img *image.RGBA, text string, x, y, scale int, c color.Color) {
+	if scale == 1 {
+		pixoo.DrawTextOnImage(img, text, x, y, c)
+		return
+	}
+	glyphs := image.NewRGBA(image.Rect(0, 0, pixoo.TextWidth(text), pixoo.LineHeight))
+	pixoo.DrawTextOnImage(glyphs, text, 0, 0, c)
+	b := glyphs.Bounds()
+	for gy := b.Min.Y; gy < b.Max.Y; gy++ {
+		for gx := b.Min.X; gx < b.Max.X; gx++ {
+			if glyphs.RGBAAt(gx, gy).A != 0 {
+				pixoo.FillRect(img, x+gx*scale, y+gy*scale, x+(gx+1)*scale, y+(gy+1)*scale, c)
+			}
+		}
+	}
+}
+
+func (in Input) takeover() (alert.Status, bool) {
+	if in.Alerts == nil {
+		return alert.Status{}, false
+	}
+	return in.Alerts.Takeover()
+}
+
+func (in Input) firing() []alert.Status {
+	if in.Alerts == nil {
+		return nil
+	}
+	return in.Alerts.Firing()
+}
+
+// alertColor returns the color for a widget showing metric: the normal
+// color unless a rule on that metric is firing
+func (in Input) alertColor(metric string, normal color.RGBA) color.RGBA {
+	if in.Alerts == nil {
+		return normal
+	}
+	st, ok := in.Alerts.MetricAlert(metric)
+	if !ok {
+		return normal
+	}
+	if st.Rule.Severity == alert.Critical {
+		return CriticalColor
+	}
+	return WarningColor
+}
+
+// drawSparkline plots the most recent samples (0-100 scale) into the
+// w x h box whose top-left corner is at x, y. Each sample takes one
+// column, or several when a minute of samples fits more than once.
+func drawSparkline(img *image.RGBA, samples []metrics.Sample, x, y, w, h int, c color.Color) {
+	step := max(1, w/60)
+	if len(samples) > w/step {
+		samples = samples[len(samples)-w/step:]
+	}
+
+	// Right-align so the newest sample is always at the right edge
+	offset := w - len(samples)*step
+	for i, s := range samples {
+		barHeight := int((s.Value / 100.0) * float64(h))
+		if barHeight < 1 && s.Value > 0 {
+			barHeight = 1
+		} else if barHeight > h {
+			barHeight = h
+		}
+		col := x + offset + i*step
+		pixoo.FillRect(img, col, y+h-barHeight, col+step, y+h, c)
+	}
+}
+
+// drawBorder outlines the whole image with a frame 1px wide per panel
+func drawBorder(img *image.RGBA, c color.Color) {
+	w, h := img.Bounds().Dx(), img.Bounds().Dy()
+	t := max(1, min(w, h)/64)
+	pixoo.FillRect(img, 0, 0, w, t, c)
+	pixoo.FillRect(img, 0, h-t, w, h, c)
+	pixoo.FillRect(img, 0, 0, t, h, c)
+	pixoo.FillRect(img, w-t, 0, w, h, c)
+}
+
+// drawAlertPage fills the panel with a takeover alert for st
+func drawAlertPage(img *image.RGBA, st alert.Status, flashOn bool) {
+	bg := color.RGBA{60, 0, 0, 255}
+	if st.Rule.Severity == alert.Warning {
+		bg = color.RGBA{60, 35, 0, 255}
+	}
+	accent := CriticalColor
+	if st.Rule.Severity == alert.Warning {
+		accent = WarningColor
+	}
+	textColor := color.RGBA{255, 255, 255, 255}
+
+	pixoo.FillRect(img, 0, 0, 64, 64, bg)
+	if flashOn {
+		drawBorder(img, accent)
+	}
+
+	pixoo.DrawTextOnImage(img, "ALERT!", 17, 4, accent)
+
+	pixoo.DrawTextOnImage(img, pixoo.Truncate(st.Rule.Metric, pixoo.CharsPerLine), 2, 18, textColor)
+
+	pixoo.DrawTextOnImage(img, fmt.Sprintf("%.1f", st.Value), 2, 30, accent)
+	pixoo.DrawTextOnImage(img, fmt.Sprintf("%s %g", st.Rule.Op, st.Rule.Threshold), 2, 42, textColor)
+	pixoo.DrawTextOnImage(img, st.Rule.Severity.String(), 2, 54, accent)
+}
@@ -0,0 +1,147 @@
+package dashboard
+
+import (
+	"flag"
+	"math"
+	"testing"
+	"time"
+
+	"divoom-monitor/alert"
+	"divoom-monitor/golden"
+	"divoom-monitor/metrics"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden PNGs in testdata/golden with the current rendering")
+
+// goldenTime is when every case is rendered, so nothing depends on the clock
+var goldenTime = time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+// goldenCase is one page rendered from fixed metrics and alert rules
+type goldenCase struct {
+	name    string
+	page    string
+	metrics metrics.SystemMetrics
+	// rules are evaluated once against metrics; rules without "for" fire
+	// right away
+	rules   []string
+	history []float64
+	flashOn bool
+	// width and height default to one 64x64 panel
+	width, height int
+}
+
+var idle = metrics.SystemMetrics{CPUPercent: 3, MemoryPercent: 22, MemoryUsedGB: 1.4}
+
+var busy = metrics.SystemMetrics{CPUPercent: 67, MemoryPercent: 58, MemoryUsedGB: 9.3, NetRecvMB: 12.5, NetSentMB: 3.2}
+
+var overloaded = metrics.SystemMetrics{CPUPercent: 97.5, MemoryPercent: 93, MemoryUsedGB: 14.9, NetRecvMB: 0.4, NetSentMB: 0.1}
+
+var goldenCases = []goldenCase{
+	{name: "dashboard-idle", page: PageDashboard, metrics: idle},
+	{name: "dashboard-busy", page: PageDashboard, metrics: busy, history: wave(60, 67)},
+	{name: "dashboard-history-short", page: PageDashboard, metrics: busy, history: wave(12, 40)},
+	{
+		name: "dashboard-warning", page: PageDashboard, metrics: busy, history: wave(60, 67),
+		rules: []string{"cpu.total > 60 severity=warning"},
+	},
+	{
+		name: "dashboard-critical-flash", page: PageDashboard, metrics: overloaded, history: wave(60, 90),
+		rules: []string{"mem.used_percent > 90 severity=critical action=flash"}, flashOn: true,
+	},
+	{
+		name: "dashboard-critical-flash-off", page: PageDashboard, metrics: overloaded, history: wave(60, 90),
+		rules: []string{"mem.used_percent > 90 severity=critical action=flash"},
+	},
+	{
+		name: "alert-takeover", page: PageDashboard, metrics: overloaded,
+		rules: []string{"cpu.total > 95 severity=critical action=takeover"}, flashOn: true,
+	},
+	{
+		name: "alert-takeover-warning", page: PageDashboard, metrics: overloaded,
+		rules: []string{"mem.used_percent >= 90 severity=warning action=takeover"},
+	},
+	{name: "text-idle", page: PageText, metrics: idle},
+	{name: "text-busy", page: PageText, metrics: busy},
+	{
+		name: "text-warning", page: PageText, metrics: busy,
+		rules: []string{"cpu.total > 60 severity=warning"},
+	},
+	{
+		name: "text-takeover", page: PageText, metrics: overloaded,
+		rules: []string{"cpu.total > 95 severity=critical action=takeover"},
+	},
+	{name: "wall-2x2-busy", page: PageDashboard, metrics: busy, history: wave(60, 67), width: 128, height: 128},
+	{
+		name: "wall-2x1-critical-flash", page: PageDashboard, metrics: overloaded, history: wave(60, 90),
+		rules: []string{"mem.used_percent > 90 severity=critical action=flash"}, flashOn: true, width: 128, height: 64,
+	},
+}
+
+// wave returns n CPU readings swinging around center, oldest first
+func wave(n int, center float64) []float64 {
+	values := make([]float64, n)
+	for i := range values {
+		values[i] = math.Max(0, math.Min(100, center+25*math.Sin(float64(i)/4)))
+	}
+	return values
+}
+
+// input builds the renderer input for c, timestamped at goldenTime
+func (c goldenCase) input(t *testing.T) Input {
+	t.Helper()
+
+	m := c.metrics
+	m.Timestamp = goldenTime
+	m.Values = metrics.Snapshot{
+		metrics.MetricCPUTotal:   {Name: metrics.MetricCPUTotal, Value: m.CPUPercent},
+		metrics.MetricMemPercent: {Name: metrics.MetricMemPercent, Value: m.MemoryPercent},
+		metrics.MetricMemUsedGB:  {Name: metrics.MetricMemUsedGB, Value: m.MemoryUsedGB},
+		metrics.MetricNetRecvMB:  {Name: metrics.MetricNetRecvMB, Value: m.NetRecvMB},
+		metrics.MetricNetSentMB:  {Name: metrics.MetricNetSentMB, Value: m.NetSentMB},
+	}
+
+	rules := make([]alert.Rule, 0, len(c.rules))
+	for _, spec := range c.rules {
+		rule, err := alert.ParseRule(spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rules = append(rules, rule)
+	}
+	engine := alert.NewEngine(rules)
+	engine.Evaluate(m.Values, goldenTime)
+
+	history := make([]metrics.Sample, len(c.history))
+	for i, v := range c.history {
+		history[i] = metrics.Sample{
+			Time:  goldenTime.Add(time.Duration(i-len(c.history)+1) * time.Second),
+			Value: v,
+		}
+	}
+
+	return Input{Metrics: &m, CPUHistory: history, Alerts: engine, FlashOn: c.flashOn}
+}
+
+// TestRenderGolden compares every page with its PNG in testdata/golden.
+// After an intended layout change, run
+//
+//	go test ./dashboard -update
+//
+// and review the rewritten PNGs before committing them.
+func TestRenderGolden(t *testing.T) {
+	h := golden.Harness{Dir: "testdata/golden", Update: *update}
+	for _, c := range goldenCases {
+		t.Run(c.name, func(t *testing.T) {
+			width, height := max(c.width, 64), max(c.height, 64)
+			res, err := h.Check(c.name, RenderSize(c.page, c.input(t), width, height).Image)
+			switch {
+			case err != nil:
+				t.Fatal(err)
+			case res.Updated:
+				t.Logf("updated %s", res.Path)
+			case !res.OK():
+				t.Errorf("%d pixel(s) differ from %s, see %s", res.Mismatched, res.Path, res.DiffPath)
+			}
+		})
+	}
+}
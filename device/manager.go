@@ -0,0 +1,392 @@
+// Package device drives several Pixoo panels from one process. Every
+// panel gets its own worker goroutine and queue, so a slow or unplugged
+// panel backs off and retries on its own without stalling the others.
+package device
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"divoom-monitor/pixoo"
+)
+
+// MaxBackoff caps the delay between retries of a failing panel
+const MaxBackoff = time.Minute
+
+// MaxAttempts is how many times an op is tried before it is dropped. The
+// client already retries each command, so this only covers a panel that
+// stays unreachable; the op is dropped rather than holding up the ops
+// queued behind it.
+const MaxAttempts = 3
+
+// OpTimeout bounds a single op, so a panel that stops answering mid-upload
+// does not hold its queue for the client's full timeout
+const OpTimeout = 20 * time.Second
+
+// Op is a unit of work for one panel, e.g. drawing a frame. ctx is done
+// when the op times out or the manager is closed.
+type Op func(ctx context.Context, c pixoo.PixooClient) error
+
+// Status describes the health of one panel
+type Status struct {
+	Name  string
+	Pages []string
+	// Online is false while the panel is failing and being retried
+	Online bool
+	// Failures counts consecutive failed ops
+	Failures    int
+	LastError   string
+	LastSuccess time.Time
+	// Pending is the number of ops waiting to be sent
+	Pending int
+}
+
+// Manager holds named panels. A panel with a page set shows those pages
+// in turn; one without mirrors the shared page.
+type Manager struct {
+	mu      sync.Mutex
+	workers []*worker
+	byName  map[string]*worker
+}
+
+// NewManager creates an empty manager
+func NewManager() *Manager {
+	return &Manager{byName: make(map[string]*worker)}
+}
+
+// Add registers a panel and starts its worker. pages may be empty to
+// mirror the shared page.
+func (m *Manager) Add(name string, client pixoo.PixooClient, pages []string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if name == "" {
+		return fmt.Errorf("device name is required")
+	}
+	if _, ok := m.byName[name]; ok {
+		return fmt.Errorf("device %q already added", name)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	w := &worker{
+		name:   name,
+		client: client,
+		pages:  pages,
+		wake:   make(chan struct{}, 1),
+		ctx:    ctx,
+		stop:   cancel,
+		done:   make(chan struct{}),
+	}
+	m.workers = append(m.workers, w)
+	m.byName[name] = w
+	go w.run()
+	return nil
+}
+
+// Names returns the panel names in the order they were added
+func (m *Manager) Names() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	names := make([]string, len(m.workers))
+	for i, w := range m.workers {
+		names[i] = w.name
+	}
+	return names
+}
+
+// Pages returns the page set of a panel; empty means it mirrors
+func (m *Manager) Pages(name string) []string {
+	if w := m.worker(name); w != nil {
+		return w.pages
+	}
+	return nil
+}
+
+// Client returns the client of a panel, or nil if there is none
+func (m *Manager) Client(name string) pixoo.PixooClient {
+	if w := m.worker(name); w != nil {
+		return w.client
+	}
+	return nil
+}
+
+func (m *Manager) worker(name string) *worker {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.byName[name]
+}
+
+// Submit queues op for a panel. An op replaces any pending op with the
+// same key, so a panel that falls behind only gets the latest frame.
+func (m *Manager) Submit(name, key string, op Op) error {
+	w := m.worker(name)
+	if w == nil {
+		return fmt.Errorf("unknown device %q", name)
+	}
+	w.submit(key, op)
+	return nil
+}
+
+// Broadcast queues op for every panel
+func (m *Manager) Broadcast(key string, op Op) {
+	m.mu.Lock()
+	workers := append([]*worker(nil), m.workers...)
+	m.mu.Unlock()
+
+	for _, w := range workers {
+		w.submit(key, op)
+	}
+}
+
+// PlayBuzzer sounds the buzzer of every panel that has one
+func (m *Manager) PlayBuzzer(activeMs, offMs, totalMs int) error {
+	m.Broadcast("buzzer", func(ctx context.Context, c pixoo.PixooClient) error {
+		b, ok := c.(interface {
+			PlayBuzzerContext(context.Context, int, int, int) error
+		})
+		if !ok {
+			return nil
+		}
+		return b.PlayBuzzerContext(ctx, activeMs, offMs, totalMs)
+	})
+	return nil
+}
+
+// Statuses reports the health of every panel
+func (m *Manager) Statuses() []Status {
+	m.mu.Lock()
+	workers := append([]*worker(nil), m.workers...)
+	m.mu.Unlock()
+
+	statuses := make([]Status, len(workers))
+	for i, w := range workers {
+		statuses[i] = w.snapshot()
+	}
+	return statuses
+}
+
+// Close stops all workers, aborting ops in flight and dropping those
+// not sent yet, then closes clients that hold resources
+func (m *Manager) Close() {
+	m.mu.Lock()
+	workers := m.workers
+	m.workers, m.byName = nil, make(map[string]*worker)
+	m.mu.Unlock()
+
+	for _, w := range workers {
+		w.stop()
+	}
+	for _, w := range workers {
+		<-w.done
+		if c, ok := w.client.(io.Closer); ok {
+			c.Close()
+		}
+	}
+}
+
+// pendingOp is a queued op and the key that coalesces it
+type pendingOp struct {
+	key      string
+	op       Op
+	attempts int
+}
+
+// worker sends ops to one panel in order, backing off while it fails
+type worker struct {
+	name   string
+	client pixoo.PixooClient
+	pages  []string
+	wake   chan struct{}
+	ctx    context.Context
+	stop   context.CancelFunc
+	done   chan struct{}
+
+	mu          sync.Mutex
+	pending     []pendingOp
+	failures    int
+	lastError   error
+	lastSuccess time.Time
+}
+
+func (w *worker) submit(key string, op Op) {
+	w.mu.Lock()
+	w.removeLocked(key)
+	w.pending = append(w.pending, pendingOp{key: key, op: op})
+	w.mu.Unlock()
+
+	select {
+	case w.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (w *worker) removeLocked(key string) {
+	for i, p := range w.pending {
+		if p.key == key {
+			w.pending = append(w.pending[:i], w.pending[i+1:]...)
+			return
+		}
+	}
+}
+
+// next pops the oldest pending op
+func (w *worker) next() (pendingOp, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if len(w.pending) == 0 {
+		return pendingOp{}, false
+	}
+	p := w.pending[0]
+	w.pending = w.pending[1:]
+	return p, true
+}
+
+func (w *worker) run() {
+	defer close(w.done)
+
+	for {
+		p, ok := w.next()
+		if !ok {
+			select {
+			case <-w.wake:
+				continue
+			case <-w.ctx.Done():
+				return
+			}
+		}
+
+		ctx, cancel := context.WithTimeout(w.ctx, OpTimeout)
+		err := p.op(ctx, w.client)
+		cancel()
+		if w.ctx.Err() != nil {
+			return
+		}
+		if err == nil {
+			w.succeeded()
+			continue
+		}
+		if pixoo.IsPermanent(err) {
+			w.rejected(p, err)
+			continue
+		}
+
+		select {
+		case <-time.After(w.failed(p, err)):
+		case <-w.ctx.Done():
+			return
+		}
+	}
+}
+
+func (w *worker) succeeded() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.failures > 0 {
+		log.Printf("Device %q back online after %d failure(s)", w.name, w.failures)
+	}
+	w.failures = 0
+	w.lastError = nil
+	w.lastSuccess = time.Now()
+}
+
+// rejected drops an op that can never succeed, e.g. one with an invalid
+// argument. The panel itself is fine, so there is no backoff.
+func (w *worker) rejected(p pendingOp, err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.lastError = err
+	log.Printf("Device %q rejected %s, dropping it: %v", w.name, p.key, err)
+}
+
+// failed puts p back at the front of the queue, unless a newer op with
+// the same key arrived meanwhile or p has had MaxAttempts, and returns
+// how long to back off
+func (w *worker) failed(p pendingOp, err error) time.Duration {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	p.attempts++
+	switch {
+	case w.hasLocked(p.key):
+	case p.attempts >= MaxAttempts:
+		log.Printf("Device %q: dropping %s after %d attempts: %v", w.name, p.key, p.attempts, err)
+	default:
+		w.pending = append([]pendingOp{p}, w.pending...)
+	}
+
+	w.failures++
+	w.lastError = err
+	delay := MaxBackoff
+	if w.failures <= 6 {
+		delay = time.Second << (w.failures - 1)
+	}
+	if w.failures == 1 {
+		log.Printf("Device %q failing, retrying with backoff: %v", w.name, err)
+	}
+	return delay
+}
+
+func (w *worker) hasLocked(key string) bool {
+	for _, p := range w.pending {
+		if p.key == key {
+			return true
+		}
+	}
+	return false
+}
+
+func (w *worker) snapshot() Status {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	st := Status{
+		Name:        w.name,
+		Pages:       w.pages,
+		Online:      w.failures == 0,
+		Failures:    w.failures,
+		LastSuccess: w.lastSuccess,
+		Pending:     len(w.pending),
+	}
+	if w.lastError != nil {
+		st.LastError = w.lastError.Error()
+	}
+	return st
+}
+
+// Spec is a panel given on the command line as name=host[/page,page...]
+type Spec struct {
+	Name  string
+	Host  string
+	Pages []string
+}
+
+// ParseSpec parses a -device flag value, e.g. "desk=192.168.1.20/text"
+func ParseSpec(s string) (Spec, error) {
+	name, rest, ok := strings.Cut(s, "=")
+	if !ok || name == "" || rest == "" {
+		return Spec{}, fmt.Errorf("device %q must be name=host[/page,page]", s)
+	}
+
+	spec := Spec{Name: name, Host: rest}
+	if host, pages, ok := strings.Cut(rest, "/"); ok {
+		spec.Host = host
+		for _, p := range strings.Split(pages, ",") {
+			if p = strings.TrimSpace(p); p != "" {
+				spec.Pages = append(spec.Pages, p)
+			}
+		}
+	}
+	if spec.Host == "" {
+		return Spec{}, fmt.Errorf("device %q has no host", s)
+	}
+	return spec, nil
+}
@@ -0,0 +1,77 @@
+package device
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"divoom-monitor/pixoo"
+)
+
+func TestManagerDropsRejectedOps(t *testing.T) {
+	m := NewManager()
+	defer m.Close()
+	if err := m.Add("desk", nil, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	var rejected atomic.Int32
+	m.Submit("desk", "brightness", func(ctx context.Context, c pixoo.PixooClient) error {
+		rejected.Add(1)
+		return pixoo.Permanent(fmt.Errorf("brightness must be between 0 and 100"))
+	})
+	sent := make(chan struct{})
+	m.Submit("desk", "frame", func(ctx context.Context, c pixoo.PixooClient) error {
+		close(sent)
+		return nil
+	})
+
+	// No backoff: the panel answered, it just refused the op
+	select {
+	case <-sent:
+	case <-time.After(500 * time.Millisecond):
+		t.Fatal("op queued behind a rejected one was not sent")
+	}
+	if n := rejected.Load(); n != 1 {
+		t.Errorf("rejected op ran %d times, want 1", n)
+	}
+	st := m.Statuses()[0]
+	if !st.Online || st.Failures != 0 || st.Pending != 0 {
+		t.Errorf("status = %+v, want online with nothing pending", st)
+	}
+}
+
+func TestManagerGivesUpAfterMaxAttempts(t *testing.T) {
+	if testing.Short() {
+		t.Skip("waits through the backoff")
+	}
+
+	m := NewManager()
+	defer m.Close()
+	if err := m.Add("desk", nil, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	var attempts atomic.Int32
+	m.Submit("desk", "buzzer", func(ctx context.Context, c pixoo.PixooClient) error {
+		attempts.Add(1)
+		return errors.New("connection refused")
+	})
+	// Backoff after the first two attempts is 1s + 2s
+	deadline := time.Now().Add(5 * time.Second)
+	for m.Statuses()[0].Pending > 0 || attempts.Load() < MaxAttempts {
+		if time.Now().After(deadline) {
+			t.Fatalf("op still queued after %d attempts", attempts.Load())
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	if n := attempts.Load(); n != MaxAttempts {
+		t.Errorf("op ran %d times, want %d", n, MaxAttempts)
+	}
+	if st := m.Statuses()[0]; st.Online || st.Failures != MaxAttempts {
+		t.Errorf("status = %+v, want offline after %d failures", st, MaxAttempts)
+	}
+}
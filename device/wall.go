@@ -0,0 +1,327 @@
+package device
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+
+	"divoom-monitor/pixoo"
+)
+
+// PanelSize is the width and height of one Pixoo 64 panel
+const PanelSize = 64
+
+// Tile places one panel of a video wall
+type Tile struct {
+	Client pixoo.PixooClient
+	// Col and Row locate the panel in the grid, 0,0 being top left
+	Col, Row int
+	// Rotation is how far the panel is physically turned clockwise:
+	// 0, 90, 180 or 270 degrees. Tiles are counter-rotated to compensate.
+	Rotation int
+	// OffsetX and OffsetY shift the part of the canvas the panel shows,
+	// e.g. to account for bezels between panels
+	OffsetX, OffsetY int
+}
+
+// Wall treats a grid of panels as one (64*cols)x(64*rows) canvas. It
+// implements pixoo.PixooClient: images of the canvas size are split into
+// tiles, while 64x64 images are scaled up to fill as much as fits.
+type Wall struct {
+	cols, rows int
+	tiles      []Tile
+}
+
+// NewWall checks that every tile is inside the grid and that no two
+// tiles share a position
+func NewWall(cols, rows int, tiles []Tile) (*Wall, error) {
+	if cols < 1 || rows < 1 {
+		return nil, fmt.Errorf("wall must be at least 1x1, got %dx%d", cols, rows)
+	}
+
+	seen := make(map[[2]int]bool)
+	for _, t := range tiles {
+		if t.Col < 0 || t.Col >= cols || t.Row < 0 || t.Row >= rows {
+			return nil, fmt.Errorf("tile %d,%d is outside the %dx%d wall", t.Col, t.Row, cols, rows)
+		}
+		if seen[[2]int{t.Col, t.Row}] {
+			return nil, fmt.Errorf("tile %d,%d is assigned twice", t.Col, t.Row)
+		}
+		seen[[2]int{t.Col, t.Row}] = true
+
+		switch t.Rotation {
+		case 0, 90, 180, 270:
+		default:
+			return nil, fmt.Errorf("tile %d,%d: rotation must be 0, 90, 180 or 270", t.Col, t.Row)
+		}
+	}
+	return &Wall{cols: cols, rows: rows, tiles: tiles}, nil
+}
+
+// Bounds returns the size of the whole canvas
+func (w *Wall) Bounds() image.Rectangle {
+	return image.Rect(0, 0, w.cols*PanelSize, w.rows*PanelSize)
+}
+
+// Split cuts img into one 64x64 image per tile, in tile order, with the
+// tile's offset and rotation applied
+func (w *Wall) Split(img image.Image) []*image.RGBA {
+	min := img.Bounds().Min
+	out := make([]*image.RGBA, len(w.tiles))
+	for i, t := range w.tiles {
+		tile := pixoo.CreateImage()
+		originX := t.Col*PanelSize + t.OffsetX
+		originY := t.Row*PanelSize + t.OffsetY
+
+		for py := 0; py < PanelSize; py++ {
+			for px := 0; px < PanelSize; px++ {
+				vx, vy := rotate(px, py, t.Rotation)
+				p := image.Pt(min.X+originX+vx, min.Y+originY+vy)
+				if p.In(img.Bounds()) {
+					tile.Set(px, py, img.At(p.X, p.Y))
+				} else {
+					tile.Set(px, py, color.RGBA{0, 0, 0, 255})
+				}
+			}
+		}
+		out[i] = tile
+	}
+	return out
+}
+
+// rotate maps a pixel of a panel turned clockwise by degrees to where
+// the viewer sees it in the upright tile
+func rotate(px, py, degrees int) (int, int) {
+	const last = PanelSize - 1
+	switch degrees {
+	case 90:
+		return last - py, px
+	case 180:
+		return last - px, last - py
+	case 270:
+		return py, last - px
+	}
+	return px, py
+}
+
+// Draw splits img into tiles and pushes them to all panels at once. The
+// tiles are prepared before any push starts so the panels update as
+// close together as the network allows. Every panel is attempted even
+// if others fail.
+func (w *Wall) Draw(img image.Image) error {
+	return w.DrawContext(context.Background(), img)
+}
+
+// DrawContext is Draw bounded by ctx
+func (w *Wall) DrawContext(ctx context.Context, img image.Image) error {
+	tiles := w.Split(img)
+	return w.each(func(i int, c pixoo.PixooClient) error {
+		return c.DrawImageContext(ctx, tiles[i])
+	})
+}
+
+// each runs fn for every tile concurrently, releasing them together
+func (w *Wall) each(fn func(i int, c pixoo.PixooClient) error) error {
+	start := make(chan struct{})
+	errs := make([]error, len(w.tiles))
+
+	var wg sync.WaitGroup
+	for i, t := range w.tiles {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			if err := fn(i, t.Client); err != nil {
+				errs[i] = fmt.Errorf("tile %d,%d: %w", t.Col, t.Row, err)
+			}
+		}()
+	}
+	close(start)
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// DrawImage draws a canvas-sized image across the wall. Smaller images,
+// such as a 64x64 page, are scaled up by the largest whole factor that
+// fits and centered.
+func (w *Wall) DrawImage(img image.Image) error {
+	return w.DrawImageContext(context.Background(), img)
+}
+
+// DrawImageContext is DrawImage bounded by ctx
+func (w *Wall) DrawImageContext(ctx context.Context, img image.Image) error {
+	bounds := w.Bounds()
+	if img.Bounds().Size() == bounds.Size() {
+		return w.DrawContext(ctx, img)
+	}
+
+	src := img.Bounds()
+	scale := min(bounds.Dx()/src.Dx(), bounds.Dy()/src.Dy())
+	if scale < 1 {
+		return fmt.Errorf("image %dx%d is larger than the %dx%d wall", src.Dx(), src.Dy(), bounds.Dx(), bounds.Dy())
+	}
+
+	canvas := image.NewRGBA(bounds)
+	pixoo.FillRect(canvas, 0, 0, bounds.Dx(), bounds.Dy(), color.RGBA{0, 0, 0, 255})
+	offX := (bounds.Dx() - src.Dx()*scale) / 2
+	offY := (bounds.Dy() - src.Dy()*scale) / 2
+	for y := 0; y < src.Dy()*scale; y++ {
+		for x := 0; x < src.Dx()*scale; x++ {
+			canvas.Set(offX+x, offY+y, img.At(src.Min.X+x/scale, src.Min.Y+y/scale))
+		}
+	}
+	return w.DrawContext(ctx, canvas)
+}
+
+// DrawText renders text locally and draws it across the wall, since the
+// device text renderer only covers a single panel
+func (w *Wall) DrawText(text string, r, g, b uint8) error {
+	return w.DrawTextContext(context.Background(), text, r, g, b)
+}
+
+// DrawTextContext is DrawText bounded by ctx
+func (w *Wall) DrawTextContext(ctx context.Context, text string, r, g, b uint8) error {
+	return w.DrawImageContext(ctx, pixoo.RenderText(text, color.RGBA{r, g, b, 255}))
+}
+
+// SetBrightness sets the brightness of every panel
+func (w *Wall) SetBrightness(brightness int) error {
+	return w.SetBrightnessContext(context.Background(), brightness)
+}
+
+// SetBrightnessContext is SetBrightness bounded by ctx
+func (w *Wall) SetBrightnessContext(ctx context.Context, brightness int) error {
+	return w.each(func(_ int, c pixoo.PixooClient) error {
+		return c.SetBrightnessContext(ctx, brightness)
+	})
+}
+
+// SetChannel switches every panel that supports it to channel
+func (w *Wall) SetChannel(channel int) error {
+	return w.SetChannelContext(context.Background(), channel)
+}
+
+// SetChannelContext is SetChannel bounded by ctx
+func (w *Wall) SetChannelContext(ctx context.Context, channel int) error {
+	return w.each(func(_ int, c pixoo.PixooClient) error {
+		if sc, ok := c.(interface {
+			SetChannelContext(context.Context, int) error
+		}); ok {
+			return sc.SetChannelContext(ctx, channel)
+		}
+		return nil
+	})
+}
+
+// CheckReboot re-applies settings on any panel that rebooted
+func (w *Wall) CheckReboot() (bool, error) {
+	return w.CheckRebootContext(context.Background())
+}
+
+// CheckRebootContext is CheckReboot bounded by ctx
+func (w *Wall) CheckRebootContext(ctx context.Context) (bool, error) {
+	var mu sync.Mutex
+	rebooted := false
+	err := w.each(func(_ int, c pixoo.PixooClient) error {
+		rc, ok := c.(interface {
+			CheckRebootContext(context.Context) (bool, error)
+		})
+		if !ok {
+			return nil
+		}
+		r, err := rc.CheckRebootContext(ctx)
+		mu.Lock()
+		rebooted = rebooted || r
+		mu.Unlock()
+		return err
+	})
+	return rebooted, err
+}
+
+// Close closes the panel clients that hold resources
+func (w *Wall) Close() error {
+	var errs []error
+	for _, t := range w.tiles {
+		if c, ok := t.Client.(io.Closer); ok {
+			errs = append(errs, c.Close())
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// ClearScreen clears every panel
+func (w *Wall) ClearScreen() error {
+	return w.ClearScreenContext(context.Background())
+}
+
+// ClearScreenContext is ClearScreen bounded by ctx
+func (w *Wall) ClearScreenContext(ctx context.Context) error {
+	return w.each(func(_ int, c pixoo.PixooClient) error {
+		return c.ClearScreenContext(ctx)
+	})
+}
+
+// ParseTile parses a -wall-tile flag value of the form
+// "<col>,<row> <host> [rotate=90] [dx=0] [dy=0]"
+func ParseTile(s string) (Tile, string, error) {
+	fields := strings.Fields(s)
+	if len(fields) < 2 {
+		return Tile{}, "", fmt.Errorf("tile %q: expected <col>,<row> <host>", s)
+	}
+
+	var t Tile
+	colStr, rowStr, ok := strings.Cut(fields[0], ",")
+	if !ok {
+		return Tile{}, "", fmt.Errorf("tile %q: position must be <col>,<row>", s)
+	}
+	var err error
+	if t.Col, err = strconv.Atoi(colStr); err != nil {
+		return Tile{}, "", fmt.Errorf("tile %q: invalid column: %w", s, err)
+	}
+	if t.Row, err = strconv.Atoi(rowStr); err != nil {
+		return Tile{}, "", fmt.Errorf("tile %q: invalid row: %w", s, err)
+	}
+
+	for _, opt := range fields[2:] {
+		key, value, ok := strings.Cut(opt, "=")
+		if !ok {
+			return Tile{}, "", fmt.Errorf("tile %q: unexpected %q", s, opt)
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return Tile{}, "", fmt.Errorf("tile %q: invalid %s: %w", s, key, err)
+		}
+		switch key {
+		case "rotate":
+			t.Rotation = n
+		case "dx":
+			t.OffsetX = n
+		case "dy":
+			t.OffsetY = n
+		default:
+			return Tile{}, "", fmt.Errorf("tile %q: unknown option %q", s, key)
+		}
+	}
+	return t, fields[1], nil
+}
+
+// ParseGrid parses a wall size such as "2x2"
+func ParseGrid(s string) (cols, rows int, err error) {
+	colStr, rowStr, ok := strings.Cut(strings.ToLower(s), "x")
+	if !ok {
+		return 0, 0, fmt.Errorf("wall size %q must be <cols>x<rows>", s)
+	}
+	if cols, err = strconv.Atoi(colStr); err != nil {
+		return 0, 0, fmt.Errorf("wall size %q: invalid columns: %w", s, err)
+	}
+	if rows, err = strconv.Atoi(rowStr); err != nil {
+		return 0, 0, fmt.Errorf("wall size %q: invalid rows: %w", s, err)
+	}
+	return cols, rows, nil
+}
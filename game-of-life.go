@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"image/color"
@@ -12,19 +13,28 @@ import (
 	"time"
 
 	"divoom-monitor/gameoflife"
+	"divoom-monitor/output"
 	"divoom-monitor/pixoo"
+	"divoom-monitor/sim"
 )
 
 func main() {
 	// Parse command line flags
-	host := flag.String("host", "", "Pixoo 64 device IP address (required)")
+	host := flag.String("host", "", "Pixoo 64 device IP address, \"auto\" to discover it, or \"sim\" to show it in a browser (required)")
+	cloudURL := flag.String("cloud-url", pixoo.DefaultCloudURL, "Divoom cloud API used by -host auto (empty to only scan the LAN)")
 	pattern := flag.String("pattern", "random", "Starting pattern: random, random-sparse, random-dense, gliders, gosper-gun, pulsar")
 	speed := flag.Int("speed", 200, "Update speed in milliseconds")
+	fps := flag.Float64("fps", pixoo.DefaultMaxFPS, "Maximum frames per second sent to the device; generations in between are skipped")
 	brightness := flag.Int("brightness", 70, "Screen brightness (0-100)")
+	outputPath := flag.String("output", "", "Write frames to a .png (latest frame), a numbered .png pattern or directory, an animated .gif, or \"term\" to draw them in the terminal; -host becomes optional")
+	outputScale := flag.Int("output-scale", 8, "Pixel size of -output frames")
+	outputLED := flag.Bool("output-led", false, "Draw -output pixels as LED dots")
+	simAddr := flag.String("sim-addr", sim.DefaultAddr, "Address the -host sim simulator is served on")
+	recordPath := flag.String("record", "", "Log every command sent to the device to this JSON Lines file, for pixoo-replay")
 	colorMode := flag.String("color", "age", "Color mode: age, rainbow, fire, ocean, matrix")
 	flag.Parse()
 
-	if *host == "" {
+	if *host == "" && *outputPath == "" {
 		fmt.Println("Error: -host flag is required")
 		fmt.Println("\nExample: go run game-of-life.go -host 192.168.1.140")
 		fmt.Println("\nPatterns:")
@@ -44,28 +54,92 @@ func main() {
 		os.Exit(1)
 	}
 
+	if *host == pixoo.AutoHost {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		found, err := pixoo.ResolveHost(ctx, *host, *cloudURL)
+		cancel()
+		if err != nil {
+			log.Fatalf("Error: %v (pass -host <ip> instead)", err)
+		}
+		log.Printf("Found Pixoo at %s", found)
+		*host = found
+	}
+
 	// Seed random number generator
 	rand.Seed(time.Now().UnixNano())
 
-	// Create Pixoo client
-	client := pixoo.NewClient(*host)
-
-	// Set brightness
-	if err := client.SetBrightness(*brightness); err != nil {
-		log.Printf("Warning: failed to set brightness: %v", err)
+	// Setup graceful shutdown; cancelling ctx also aborts an upload in flight
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Write every generation to files if asked; the GIF is written on exit
+	var out output.Target
+	if *outputPath != "" {
+		var err error
+		out, err = output.New(*outputPath, output.Options{Scale: *outputScale, LED: *outputLED})
+		if err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		defer func() {
+			if err := out.Close(); err != nil {
+				log.Printf("Error writing %s: %v", *outputPath, err)
+			} else if *outputPath != output.TerminalTarget {
+				log.Printf("Wrote %d frame(s) to %s", out.Frames(), *outputPath)
+			}
+		}()
 	}
 
-	// Switch to Custom channel
-	log.Println("Switching to Custom channel...")
-	if err := client.SetChannel(3); err != nil {
-		log.Printf("Warning: failed to set channel: %v", err)
+	var sink *pixoo.FrameSink
+	if *host == sim.Host {
+		simulator, err := sim.Start(*simAddr)
+		if err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		defer simulator.Close()
+		log.Printf("Serving panel simulator on %s", simulator.URL())
+		simulator.SetBrightness(*brightness)
+
+		sink = pixoo.NewFrameSink(simulator, *fps)
+		defer sink.Close()
+	} else if *host != "" {
+		// Create Pixoo client
+		client := pixoo.NewClient(*host)
+		defer client.Close()
+		if *recordPath != "" {
+			recorder, err := pixoo.CreateRecording(*recordPath)
+			if err != nil {
+				log.Fatalf("Error: %v", err)
+			}
+			defer recorder.Close()
+			client.SetRecorder(recorder)
+		}
+
+		// Set brightness
+		if err := client.SetBrightnessContext(ctx, *brightness); err != nil {
+			log.Printf("Warning: failed to set brightness: %v", err)
+		}
+
+		// Switch to Custom channel
+		log.Println("Switching to Custom channel...")
+		if err := client.SetChannelContext(ctx, 3); err != nil {
+			log.Printf("Warning: failed to set channel: %v", err)
+		}
+
+		// The simulation ticks at -speed regardless of how fast the device
+		// takes frames; the sink sends the newest one whenever it can
+		sink = pixoo.NewFrameSink(client, *fps)
+		defer sink.Close()
 	}
 
 	// Create game
 	game := gameoflife.NewGame()
 	game.LoadPattern(*pattern)
 
-	log.Printf("Starting Game of Life on %s", *host)
+	target := *host
+	if target == "" {
+		target = *outputPath
+	}
+	log.Printf("Starting Game of Life on %s", target)
 	log.Printf("Pattern: %s, Speed: %dms, Color: %s", *pattern, *speed, *colorMode)
 	log.Println("Press Ctrl+C to exit")
 
@@ -75,14 +149,13 @@ func main() {
 		cellAges[i] = make([]int, 64)
 	}
 
-	// Setup graceful shutdown
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
-
 	ticker := time.NewTicker(time.Duration(*speed) * time.Millisecond)
 	defer ticker.Stop()
 
 	generation := 0
+	// Each generation is drawn on the back buffer and swapped in whole,
+	// so the sink and -output only ever see complete frames
+	buf := pixoo.NewDoubleBuffer()
 
 	for {
 		select {
@@ -98,27 +171,41 @@ func main() {
 				}
 			}
 
-			// Render to image
-			img := pixoo.CreateImage()
+			// Render every pixel, so the stale back buffer needs no clearing
+			back := buf.Back()
 			for y := 0; y < 64; y++ {
 				for x := 0; x < 64; x++ {
 					if game.IsAlive(x, y) {
 						c := getCellColor(cellAges[y][x], *colorMode, x, y)
-						img.Set(x, y, c)
+						back.SetRGB(x, y, c.R, c.G, c.B)
 					} else {
-						img.Set(x, y, color.RGBA{0, 0, 0, 255}) // Dead = black
+						back.SetRGB(x, y, 0, 0, 0) // Dead = black
 					}
 				}
 			}
+			buf.Swap()
 
 			// Send to display
-			if err := client.DrawImage(img); err != nil {
-				log.Printf("Error drawing: %v", err)
+			if sink != nil {
+				sink.Send(buf.Front())
+			}
+			if out != nil {
+				if err := out.DrawImage(buf.Front()); err != nil {
+					log.Printf("Error writing frame: %v", err)
+				}
 			}
 
 			// Log stats every 50 generations
 			if generation%50 == 0 {
-				log.Printf("Generation %d: %d alive cells", generation, game.CountAlive())
+				if sink == nil {
+					log.Printf("Generation %d: %d alive cells", generation, game.CountAlive())
+				} else {
+					st := sink.Stats()
+					log.Printf("Generation %d: %d alive cells, %.1f fps, %d frames dropped", generation, game.CountAlive(), st.FPS, st.Dropped)
+					if st.LastError != nil {
+						log.Printf("Error drawing (%d failed): %v", st.Errors, st.LastError)
+					}
+				}
 			}
 
 			// Step simulation
@@ -147,7 +234,7 @@ func main() {
 				}
 			}
 
-		case <-sigChan:
+		case <-ctx.Done():
 			log.Println("Shutting down...")
 			return
 		}
@@ -0,0 +1,174 @@
+// Package golden compares rendered frames with reference PNGs kept in the
+// repository ("golden files"), so any change to what a page looks like
+// shows up as a failed check with a diff image to review.
+package golden
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"os"
+	"path/filepath"
+)
+
+// diffScale enlarges diff images so single pixels are easy to spot
+const diffScale = 4
+
+// Harness checks frames against the golden files in Dir
+type Harness struct {
+	Dir string
+	// Update rewrites the golden files with the frames checked instead of
+	// comparing against them
+	Update bool
+}
+
+// Result is the outcome of checking one frame
+type Result struct {
+	Name string
+	// Path is the golden file
+	Path string
+	// Mismatched counts the pixels that differ from the golden file
+	Mismatched int
+	// DiffPath is the diff image written for a mismatch
+	DiffPath string
+	// Updated is set when the golden file was (re)written
+	Updated bool
+}
+
+// OK reports whether the frame matched or its golden file was updated
+func (r Result) OK() bool {
+	return r.Mismatched == 0
+}
+
+// Check compares got with the golden file <Dir>/<name>.png. On a mismatch
+// it writes <name>.diff.png next to it, showing the golden frame, the new
+// frame and the differing pixels side by side. A diff left over from an
+// earlier run is removed once the frame matches again.
+func (h Harness) Check(name string, got image.Image) (Result, error) {
+	res := Result{
+		Name: name,
+		Path: filepath.Join(h.Dir, name+".png"),
+	}
+	diffPath := filepath.Join(h.Dir, name+".diff.png")
+
+	want, err := readPNG(res.Path)
+	switch {
+	case h.Update:
+		if err == nil {
+			if n, _ := Diff(want, got); n == 0 {
+				os.Remove(diffPath)
+				return res, nil
+			}
+		}
+		if err := os.MkdirAll(h.Dir, 0o755); err != nil {
+			return res, fmt.Errorf("create golden directory: %w", err)
+		}
+		if err := writePNG(res.Path, got); err != nil {
+			return res, err
+		}
+		os.Remove(diffPath)
+		res.Updated = true
+		return res, nil
+	case errors.Is(err, os.ErrNotExist):
+		return res, fmt.Errorf("no golden file %s (run with -update to create it)", res.Path)
+	case err != nil:
+		return res, err
+	}
+
+	n, diff := Diff(want, got)
+	if n == 0 {
+		os.Remove(diffPath)
+		return res, nil
+	}
+	res.Mismatched = n
+	if err := writePNG(diffPath, diff); err != nil {
+		return res, err
+	}
+	res.DiffPath = diffPath
+	return res, nil
+}
+
+// Diff counts the pixels that differ between want and got and draws a
+// diff image: want, got and a map of the differences (red, over a faded
+// copy of want) side by side. Pixels outside either image count as
+// different.
+func Diff(want, got image.Image) (int, *image.RGBA) {
+	wb, gb := want.Bounds(), got.Bounds()
+	w, h := max(wb.Dx(), gb.Dx()), max(wb.Dy(), gb.Dy())
+
+	out := image.NewRGBA(image.Rect(0, 0, (3*w+2)*diffScale, h*diffScale))
+	draw.Draw(out, out.Bounds(), image.NewUniform(color.RGBA{64, 64, 64, 255}), image.Point{}, draw.Src)
+
+	mismatched := 0
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			wc, wok := pixel(want, x, y)
+			gc, gok := pixel(got, x, y)
+			same := wok && gok && wc == gc
+
+			mark := color.RGBA{255, 0, 0, 255}
+			if same {
+				// Faded grey, so the layout stays recognizable
+				lum := (uint16(wc.R)*3 + uint16(wc.G)*6 + uint16(wc.B)) / 10
+				v := uint8(lum / 4)
+				mark = color.RGBA{v, v, v, 255}
+			} else {
+				mismatched++
+			}
+			if wok {
+				fillCell(out, x, y, wc)
+			}
+			if gok {
+				fillCell(out, w+1+x, y, gc)
+			}
+			fillCell(out, 2*w+2+x, y, mark)
+		}
+	}
+	return mismatched, out
+}
+
+// pixel returns the color at x, y relative to img's top left corner
+func pixel(img image.Image, x, y int) (color.RGBA, bool) {
+	b := img.Bounds()
+	if x >= b.Dx() || y >= b.Dy() {
+		return color.RGBA{}, false
+	}
+	return color.RGBAModel.Convert(img.At(b.Min.X+x, b.Min.Y+y)).(color.RGBA), true
+}
+
+func fillCell(img *image.RGBA, x, y int, c color.RGBA) {
+	r := image.Rect(x*diffScale, y*diffScale, (x+1)*diffScale, (y+1)*diffScale)
+	draw.Draw(img, r, image.NewUniform(c), image.Point{}, draw.Src)
+}
+
+func readPNG(path string) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	img, err := png.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("decode %s: %w", path, err)
+	}
+	return img, nil
+}
+
+func writePNG(path string, img image.Image) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("create %s: %w", path, err)
+	}
+	if err := png.Encode(f, img); err != nil {
+		f.Close()
+		return fmt.Errorf("encode %s: %w", path, err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("write %s: %w", path, err)
+	}
+	return nil
+}
@@ -1,70 +1,332 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"image"
 	"image/color"
+	"image/draw"
 	"log"
+	"net/http"
 	"os"
 	"os/signal"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
 	"syscall"
 	"time"
 
+	"divoom-monitor/alert"
+	"divoom-monitor/control"
+	"divoom-monitor/dashboard"
+	"divoom-monitor/device"
 	"divoom-monitor/metrics"
+	"divoom-monitor/mqttbridge"
+	"divoom-monitor/notify"
+	"divoom-monitor/output"
 	"divoom-monitor/pixoo"
+	"divoom-monitor/sim"
+	"divoom-monitor/webhook"
 )
 
 func main() {
 	// Parse command line flags
-	host := flag.String("host", "", "Pixoo 64 device IP address (required)")
+	host := flag.String("host", "", "Pixoo 64 device IP address, \"auto\" to discover it, or \"sim\" to show it in a browser (required unless -device, -wall or -output is given)")
+	cloudURL := flag.String("cloud-url", pixoo.DefaultCloudURL, "Divoom cloud API used by -host auto (empty to only scan the LAN)")
+	var deviceSpecs stringList
+	flag.Var(&deviceSpecs, "device", "Additional panel as name=host[/page,page]; without pages it mirrors the main page (repeatable)")
+	retries := flag.Int("retries", pixoo.DefaultRetryPolicy.MaxRetries, "Retries per device command, with exponential backoff and jitter")
+	breakerThreshold := flag.Int("breaker-threshold", pixoo.DefaultBreakerConfig.Threshold, "Failed commands before a device is treated as offline (0 disables)")
+	breakerCooldown := flag.Duration("breaker-cooldown", pixoo.DefaultBreakerConfig.Cooldown, "How long an offline device is left alone before trying again")
+	outputPath := flag.String("output", "", "Also write frames to a .png (latest frame), a numbered .png pattern or directory, an animated .gif, or \"term\" to draw them in the terminal")
+	outputScale := flag.Int("output-scale", 8, "Pixel size of -output frames")
+	outputLED := flag.Bool("output-led", false, "Draw -output pixels as LED dots")
+	simAddr := flag.String("sim-addr", sim.DefaultAddr, "Address the -host sim simulator is served on")
+	wallSize := flag.String("wall", "", "Drive a video wall of <cols>x<rows> panels as one canvas (e.g. 2x2)")
+	var wallTiles stringList
+	flag.Var(&wallTiles, "wall-tile", "Wall panel as '<col>,<row> <host> [rotate=90] [dx=0] [dy=0]' (repeatable)")
 	interval := flag.Int("interval", 5, "Update interval in seconds")
 	brightness := flag.Int("brightness", 50, "Screen brightness (0-100)")
+	refresh := flag.Duration("refresh", 0, "Re-send an unchanged frame after this long (0 skips unchanged frames indefinitely)")
+	recordPath := flag.String("record", "", "Log every command sent to the panels to this JSON Lines file, for pixoo-replay")
 	textOnly := flag.Bool("text", false, "Use text-only mode (faster, less detailed)")
+	sampleRate := flag.Duration("sample", time.Second, "Metric sampling interval")
+	promTarget := flag.String("prom", "", "Prometheus endpoint URL or exposition file to scrape")
+	var promQueries stringList
+	flag.Var(&promQueries, "prom-query", "Prometheus query as name=expr, exposed as prom.<name> (repeatable)")
+	metricsAddr := flag.String("metrics-addr", "", "Serve the monitor's own metrics on this address (e.g. :9090)")
+	var alertRules stringList
+	flag.Var(&alertRules, "alert", "Alert rule, e.g. 'cpu.total > 90 for 2m severity=critical action=flash' (repeatable)")
+	buzzer := flag.Bool("buzzer", false, "Sound the device buzzer when a critical alert fires")
+	quietHours := flag.String("quiet-hours", "22:00-07:00", "Local time range when the buzzer stays silent (empty to disable)")
+	apiAddr := flag.String("api-addr", "", "Serve the control API on this address (e.g. 127.0.0.1:8080)")
+	webhookConfig := flag.String("webhook-config", "", "JSON file with webhook templates (webhooks are served on -api-addr)")
+	mqttBroker := flag.String("mqtt-broker", "", "MQTT broker URL for remote control (e.g. tcp://localhost:1883)")
+	mqttPrefix := flag.String("mqtt-prefix", "divoom", "MQTT topic prefix")
+	mqttUser := flag.String("mqtt-user", "", "MQTT username")
+	mqttPassword := flag.String("mqtt-password", "", "MQTT password")
+	mqttDiscovery := flag.Bool("mqtt-discovery", true, "Publish Home Assistant MQTT discovery configs")
 	flag.Parse()
 
-	if *host == "" {
-		fmt.Println("Error: -host flag is required")
+	if *host == "" && len(deviceSpecs) == 0 && *wallSize == "" && *outputPath == "" {
+		fmt.Println("Error: one of -host, -device, -wall or -output is required")
 		flag.Usage()
 		os.Exit(1)
 	}
 
-	// Create Pixoo client
-	client := pixoo.NewClient(*host)
+	if *host == pixoo.AutoHost {
+		*host = discoverHost(*cloudURL)
+	}
 
-	// Set brightness
-	if err := client.SetBrightness(*brightness); err != nil {
-		log.Printf("Warning: failed to set brightness: %v", err)
+	var recorder *pixoo.Recorder
+	if *recordPath != "" {
+		var err error
+		recorder, err = pixoo.CreateRecording(*recordPath)
+		if err != nil {
+			log.Fatalf("Invalid -record: %v", err)
+		}
+		defer recorder.Close()
+	}
+
+	// Each panel gets its own worker, so one that is unplugged backs off
+	// without holding up the others
+	newClient := func(host string) *pixoo.Client {
+		client := pixoo.NewClient(host)
+		policy := pixoo.DefaultRetryPolicy
+		policy.MaxRetries = *retries
+		client.SetRetryPolicy(policy)
+		client.SetBreaker(pixoo.BreakerConfig{Threshold: *breakerThreshold, Cooldown: *breakerCooldown})
+		client.SetRefreshInterval(*refresh)
+		if recorder != nil {
+			client.SetRecorder(recorder)
+		}
+		return client
+	}
+	var mainClient pixoo.PixooClient
+	switch *host {
+	case "":
+	case sim.Host:
+		// Show the panel in a browser, e.g. for teammates without one
+		simulator, err := sim.Start(*simAddr)
+		if err != nil {
+			log.Fatalf("Invalid simulator: %v", err)
+		}
+		log.Printf("Serving panel simulator on %s", simulator.URL())
+		mainClient = simulator
+	default:
+		mainClient = newClient(*host)
+	}
+	devices, err := newDeviceManager(mainClient, deviceSpecs, newClient)
+	if err != nil {
+		log.Fatalf("Invalid device config: %v", err)
+	}
+	if *wallSize != "" {
+		wall, err := newWall(*wallSize, wallTiles, newClient)
+		if err != nil {
+			log.Fatalf("Invalid wall config: %v", err)
+		}
+		if err := devices.Add("wall", wall, nil); err != nil {
+			log.Fatalf("Invalid wall config: %v", err)
+		}
+	}
+	if *outputPath != "" {
+		out, err := output.New(*outputPath, output.Options{Scale: *outputScale, LED: *outputLED})
+		if err != nil {
+			log.Fatalf("Invalid output: %v", err)
+		}
+		if err := devices.Add("output", out, nil); err != nil {
+			log.Fatalf("Invalid output: %v", err)
+		}
 	}
+	defer devices.Close()
+
+	// Set brightness
+	devices.Broadcast("brightness", func(ctx context.Context, c pixoo.PixooClient) error {
+		return c.SetBrightnessContext(ctx, *brightness)
+	})
 
 	// Switch to Custom channel (channel 3) so our drawings appear
 	log.Println("Switching to Custom channel...")
-	if err := client.SetChannel(3); err != nil {
-		log.Printf("Warning: failed to set channel: %v", err)
-	}
+	devices.Broadcast("channel", func(ctx context.Context, c pixoo.PixooClient) error {
+		sc, ok := c.(interface {
+			SetChannelContext(context.Context, int) error
+		})
+		if !ok {
+			return nil
+		}
+		return sc.SetChannelContext(ctx, 3)
+	})
 
-	// Create metrics collector
+	// Sample metrics in the background so display updates never wait on collection
 	collector := metrics.NewCollector()
+	if *promTarget != "" {
+		source, err := newPromSource(*promTarget, promQueries)
+		if err != nil {
+			log.Fatalf("Invalid Prometheus config: %v", err)
+		}
+		if err := collector.Registry().Register(source); err != nil {
+			log.Fatalf("Register Prometheus source: %v", err)
+		}
+	}
+
+	// Values published to <prefix>/metric/<name> become mqtt.<name>
+	var mqttSource *mqttbridge.Source
+	if *mqttBroker != "" {
+		mqttSource = mqttbridge.NewSource("mqtt", 5*time.Minute)
+		if err := collector.Registry().Register(mqttSource); err != nil {
+			log.Fatalf("Register MQTT source: %v", err)
+		}
+	}
+
+	// Alert rules are evaluated on every sample, independent of the display rate
+	engine, err := newAlertEngine(alertRules)
+	if err != nil {
+		log.Fatalf("Invalid alert rule: %v", err)
+	}
+	engine.OnEvent(func(ev alert.Event) {
+		log.Printf("Alert %q: %s -> %s (value %.1f)", ev.Status.Rule.Name, ev.From, ev.Status.State, ev.Status.Value)
+	})
+	if *buzzer {
+		quiet, err := alert.ParseQuietHours(*quietHours)
+		if err != nil {
+			log.Fatalf("Invalid -quiet-hours: %v", err)
+		}
+		engine.OnEvent(alert.NewBuzzerAction(devices, quiet).Handle)
+	}
+
+	sampler := metrics.NewSampler(collector, *sampleRate)
+	history := metrics.NewHistory(metrics.DefaultHistorySize)
+	sampler.OnSample(history.Record)
+	sampler.OnSample(engine.Observe)
+	sampler.Start()
+	defer sampler.Stop()
+
+	page := pageDashboard
+	if *textOnly {
+		page = pageText
+	}
+	mon := &monitor{
+		devices:    devices,
+		panels:     make(map[string]*panel),
+		sampler:    sampler,
+		history:    history,
+		alerts:     engine,
+		messages:   notify.NewQueue(),
+		redraw:     make(chan struct{}, 1),
+		page:       page,
+		brightness: *brightness,
+	}
+
+	// The sampler's first reading arrives one interval after Start; draw it
+	// right away instead of waiting for the next update
+	var firstSample sync.Once
+	sampler.OnSample(func(*metrics.SystemMetrics) {
+		firstSample.Do(mon.requestRedraw)
+	})
+
+	// Expose our own metrics so a stalled panel can be alerted on. When the
+	// API shares the address, /metrics is served alongside it.
+	exporter := newExporter(sampler, devices, mon)
+	if *apiAddr != "" {
+		api := control.NewServer(mon)
+		if *metricsAddr == *apiAddr {
+			api.Handle("GET /metrics", exporter)
+		}
+		hooks, err := newWebhookHandler(*webhookConfig, mon)
+		if err != nil {
+			log.Fatalf("Invalid webhook config: %v", err)
+		}
+		api.Handle("/webhook/", hooks)
+		serve("control API", *apiAddr, api)
+	}
+	if *metricsAddr != "" && *metricsAddr != *apiAddr {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", exporter)
+		serve("metrics", *metricsAddr, mux)
+	}
+
+	if *mqttBroker != "" {
+		bridge := mqttbridge.New(mqttbridge.Config{
+			Broker:    *mqttBroker,
+			Username:  *mqttUser,
+			Password:  *mqttPassword,
+			Prefix:    *mqttPrefix,
+			Discovery: *mqttDiscovery,
+		}, mon, mqttSource)
+		if err := bridge.Start(); err != nil {
+			log.Printf("Warning: MQTT disabled: %v", err)
+		} else {
+			defer bridge.Stop()
+		}
+	}
 
 	// Setup graceful shutdown
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
 
-	log.Printf("Starting Divoom monitor on %s (update every %ds)", *host, *interval)
+	// SIGUSR1 acknowledges firing alerts (kill -USR1 <pid>)
+	ackChan := make(chan os.Signal, 1)
+	signal.Notify(ackChan, syscall.SIGUSR1)
+
+	log.Printf("Starting Divoom monitor on %s (update every %ds)", strings.Join(devices.Names(), ", "), *interval)
 	log.Println("Press Ctrl+C to exit")
 
 	// Main loop
 	ticker := time.NewTicker(time.Duration(*interval) * time.Second)
 	defer ticker.Stop()
 
+	// Flashing alerts need redraws faster than the update interval
+	flashTicker := time.NewTicker(time.Second)
+	defer flashTicker.Stop()
+
+	// A panel that rebooted without us noticing an outage comes back on
+	// its default channel and brightness
+	rebootTicker := time.NewTicker(time.Minute)
+	defer rebootTicker.Stop()
+
 	// Initial update
-	if err := updateDisplay(client, collector, *textOnly); err != nil {
+	if err := mon.updateDisplay(); err != nil {
 		log.Printf("Error updating display: %v", err)
 	}
 
 	for {
 		select {
 		case <-ticker.C:
-			if err := updateDisplay(client, collector, *textOnly); err != nil {
+			mon.rotatePages()
+			if err := mon.updateDisplay(); err != nil {
+				log.Printf("Error updating display: %v", err)
+			}
+		case <-flashTicker.C:
+			if !mon.alerts.Flashing() {
+				continue
+			}
+			mon.flashOn = !mon.flashOn
+			if err := mon.updateDisplay(); err != nil {
+				log.Printf("Error updating display: %v", err)
+			}
+		case <-rebootTicker.C:
+			for _, name := range devices.Names() {
+				devices.Submit(name, "reboot-check", func(ctx context.Context, c pixoo.PixooClient) error {
+					rc, ok := c.(interface {
+						CheckRebootContext(context.Context) (bool, error)
+					})
+					if !ok {
+						return nil
+					}
+					rebooted, err := rc.CheckRebootContext(ctx)
+					if rebooted {
+						log.Printf("Device %q appears to have rebooted; re-applied its settings", name)
+					}
+					return err
+				})
+			}
+		case <-ackChan:
+			log.Printf("Acknowledged %d alert(s)", mon.Acknowledge())
+		case <-mon.redraw:
+			if err := mon.updateDisplay(); err != nil {
 				log.Printf("Error updating display: %v", err)
 			}
 		case <-sigChan:
@@ -74,72 +336,683 @@ func main() {
 	}
 }
 
-func updateDisplay(client *pixoo.Client, collector *metrics.Collector, textOnly bool) error {
-	// Collect system metrics
-	m, err := collector.Collect()
+// defaultAlertRules recolor the bars when no -alert flags are given
+var defaultAlertRules = []string{
+	"cpu.total > 90 for 1m hysteresis=10",
+	"mem.used_percent > 90 for 1m hysteresis=5",
+}
+
+// newAlertEngine parses the -alert flags, falling back to the defaults
+func newAlertEngine(specs []string) (*alert.Engine, error) {
+	if len(specs) == 0 {
+		specs = defaultAlertRules
+	}
+
+	rules := make([]alert.Rule, 0, len(specs))
+	for _, spec := range specs {
+		rule, err := alert.ParseRule(spec)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return alert.NewEngine(rules), nil
+}
+
+// newExporter builds the /metrics handler for system, device and page state
+func newExporter(sampler *metrics.Sampler, devices *device.Manager, mon *monitor) *metrics.Exporter {
+	exporter := metrics.NewExporter(sampler)
+	exporter.AddCollector(func(w *metrics.ExpositionWriter) {
+		writeDeviceStats(w, devices)
+		w.Gauge("divoom_monitor_current_page", "Page currently shown on the panel", 1, "page", mon.currentPage())
+	})
+	return exporter
+}
+
+// newWebhookHandler loads the webhook templates, falling back to the
+// defaults when no config file is given
+func newWebhookHandler(path string, notifier webhook.Notifier) (*webhook.Handler, error) {
+	cfg := webhook.DefaultConfig()
+	if path != "" {
+		var err error
+		if cfg, err = webhook.LoadConfig(path); err != nil {
+			return nil, err
+		}
+	}
+	return webhook.NewHandler(cfg, notifier)
+}
+
+// serve runs an HTTP server in the background
+func serve(name, addr string, handler http.Handler) {
+	go func() {
+		log.Printf("Serving %s on http://%s", name, addr)
+		if err := http.ListenAndServe(addr, handler); err != nil {
+			log.Printf("%s server stopped: %v", name, err)
+		}
+	}()
+}
+
+// writeDeviceStats exports push latency, failures and frame counts for
+// every panel. Samples of a family have to be consecutive, so each family
+// loops over the panels.
+func writeDeviceStats(w *metrics.ExpositionWriter, devices *device.Manager) {
+	type deviceStats struct {
+		name     string
+		stats    pixoo.Stats
+		commands []string
+	}
+
+	var all []deviceStats
+	for _, name := range devices.Names() {
+		client, ok := devices.Client(name).(interface{ Stats() pixoo.Stats })
+		if !ok {
+			continue
+		}
+		ds := deviceStats{name: name, stats: client.Stats()}
+		for command := range ds.stats.Commands {
+			ds.commands = append(ds.commands, command)
+		}
+		sort.Strings(ds.commands)
+		all = append(all, ds)
+	}
+
+	for _, ds := range all {
+		for _, name := range ds.commands {
+			w.Counter("divoom_device_requests_total", "Commands pushed to the device",
+				float64(ds.stats.Commands[name].Requests), "device", ds.name, "command", name)
+		}
+	}
+	for _, ds := range all {
+		for _, name := range ds.commands {
+			w.Counter("divoom_device_push_failures_total", "Commands that failed to reach the device",
+				float64(ds.stats.Commands[name].Failures), "device", ds.name, "command", name)
+		}
+	}
+	bounds := make([]float64, len(pixoo.LatencyBounds))
+	for i, b := range pixoo.LatencyBounds {
+		bounds[i] = b.Seconds()
+	}
+	for _, ds := range all {
+		for _, name := range ds.commands {
+			cs := ds.stats.Commands[name]
+			w.Histogram("divoom_device_push_latency_seconds", "Time spent pushing commands",
+				bounds, cs.LatencyBuckets, cs.LatencySum.Seconds(), cs.Requests, "device", ds.name, "command", name)
+		}
+	}
+	for _, ds := range all {
+		for _, name := range ds.commands {
+			w.Gauge("divoom_device_push_last_latency_seconds", "Latency of the most recent push",
+				ds.stats.Commands[name].LastLatency.Seconds(), "device", ds.name, "command", name)
+		}
+	}
+	for _, ds := range all {
+		w.Counter("divoom_device_frames_sent_total", "Frames successfully sent to the device",
+			float64(ds.stats.FramesSent), "device", ds.name)
+	}
+	for _, ds := range all {
+		w.Counter("divoom_device_frames_skipped_total", "Frames not sent because the device already showed them",
+			float64(ds.stats.FramesSkipped), "device", ds.name)
+	}
+	for _, ds := range all {
+		if !ds.stats.LastSuccess.IsZero() {
+			w.Gauge("divoom_device_last_success_timestamp_seconds", "Unix time of the last successful push",
+				float64(ds.stats.LastSuccess.UnixNano())/1e9, "device", ds.name)
+		}
+	}
+	for _, st := range devices.Statuses() {
+		w.Gauge("divoom_device_up", "Whether the device is accepting commands", boolValue(st.Online), "device", st.Name)
+	}
+}
+
+// boolValue converts b to a 0/1 sample
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// discoverHost finds a device for -host auto or exits
+func discoverHost(cloudURL string) string {
+	log.Println("Looking for a Pixoo on the local network...")
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	host, err := pixoo.ResolveHost(ctx, pixoo.AutoHost, cloudURL)
 	if err != nil {
-		return fmt.Errorf("collect metrics: %w", err)
+		log.Fatalf("Error: %v (pass -host <ip> instead)", err)
 	}
+	log.Printf("Found Pixoo at %s", host)
+	return host
+}
 
-	// Log metrics
-	log.Println(m.String())
+// newWall builds the video wall from -wall and -wall-tile
+func newWall(size string, specs []string, newClient func(string) *pixoo.Client) (*device.Wall, error) {
+	cols, rows, err := device.ParseGrid(size)
+	if err != nil {
+		return nil, err
+	}
+	if len(specs) == 0 {
+		return nil, fmt.Errorf("-wall needs at least one -wall-tile")
+	}
+
+	tiles := make([]device.Tile, 0, len(specs))
+	for _, s := range specs {
+		tile, host, err := device.ParseTile(s)
+		if err != nil {
+			return nil, err
+		}
+		tile.Client = newClient(host)
+		tiles = append(tiles, tile)
+	}
+	return device.NewWall(cols, rows, tiles)
+}
+
+// newDeviceManager creates the panels from -host (named "main", mirroring
+// the selected page; nil if not given) and the -device flags
+func newDeviceManager(main pixoo.PixooClient, specs []string, newClient func(string) *pixoo.Client) (*device.Manager, error) {
+	devices := device.NewManager()
+	if main != nil {
+		if err := devices.Add("main", main, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, s := range specs {
+		spec, err := device.ParseSpec(s)
+		if err != nil {
+			devices.Close()
+			return nil, err
+		}
+		for _, p := range spec.Pages {
+			if !slices.Contains(pages, p) {
+				devices.Close()
+				return nil, fmt.Errorf("device %q: unknown page %q (available: %s)", spec.Name, p, strings.Join(pages, ", "))
+			}
+		}
+		if err := devices.Add(spec.Name, newClient(spec.Host), spec.Pages); err != nil {
+			devices.Close()
+			return nil, err
+		}
+	}
+	return devices, nil
+}
+
+// stringList collects the values of a repeatable flag
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ", ")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
 
-	// Text-only mode (faster, uses Pixoo's built-in text rendering)
-	if textOnly {
-		text := fmt.Sprintf("CPU:%.0f%% MEM:%.0f%% %.1fG",
-			m.CPUPercent, m.MemoryPercent, m.MemoryUsedGB)
-		if err := client.DrawText(text, 255, 255, 255); err != nil {
-			return fmt.Errorf("draw text: %w", err)
+// newPromSource builds the "prom" metric source from name=expr queries
+func newPromSource(target string, queries []string) (*metrics.PrometheusSource, error) {
+	exprs := make(map[string]string, len(queries))
+	for _, q := range queries {
+		name, expr, ok := strings.Cut(q, "=")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("query %q must be name=expr", q)
 		}
+		exprs[name] = expr
+	}
+	return metrics.NewPrometheusSource("prom", target, exprs)
+}
+
+// Pages selectable with -text or POST /page
+const (
+	pageDashboard = dashboard.PageDashboard
+	pageText      = dashboard.PageText
+)
+
+var pages = dashboard.Pages
+
+// monitor owns the panels: only the main loop draws, while API requests
+// update the state below and wake the loop through redraw
+type monitor struct {
+	devices  *device.Manager
+	sampler  *metrics.Sampler
+	history  *metrics.History
+	alerts   *alert.Engine
+	messages *notify.Queue
+	redraw   chan struct{}
+
+	// flashOn toggles the border of flashing alerts; main loop only
+	flashOn bool
+	// messageTurn alternates queued messages with the page; main loop only
+	messageTurn bool
+	// panels tracks what was last sent to each device; main loop only
+	panels map[string]*panel
+
+	mu              sync.Mutex
+	page            string
+	brightness      int
+	brightnessDirty bool
+	override        *override
+	frame           *image.RGBA
+	frameTime       time.Time
+	showingMessage  bool
+}
+
+// override is an image shown instead of the current page
+type override struct {
+	kind  string
+	img   *image.RGBA
+	until time.Time
+}
+
+// requestRedraw wakes the main loop without blocking
+func (mon *monitor) requestRedraw() {
+	select {
+	case mon.redraw <- struct{}{}:
+	default:
+	}
+}
+
+func (mon *monitor) SetPage(name string) error {
+	for _, p := range pages {
+		if p == name {
+			mon.mu.Lock()
+			mon.page = name
+			mon.override = nil
+			mon.mu.Unlock()
+
+			mon.requestRedraw()
+			return nil
+		}
+	}
+	return fmt.Errorf("%w %q (available: %s)", control.ErrUnknownPage, name, strings.Join(pages, ", "))
+}
+
+// ShowMessage queues a notification. High priority messages are drawn
+// right away; others wait for their turn between pages.
+func (mon *monitor) ShowMessage(msg notify.Message) error {
+	queued := mon.messages.Push(msg)
+	if queued.Priority == notify.High {
+		mon.requestRedraw()
+	}
+	return nil
+}
+
+func (mon *monitor) ClearMessage(key string) bool {
+	if !mon.messages.Remove(key) {
+		return false
+	}
+	mon.requestRedraw()
+	return true
+}
+
+func (mon *monitor) ShowImage(img image.Image, d time.Duration) error {
+	rgba := pixoo.CreateImage()
+	draw.Draw(rgba, rgba.Bounds(), img, img.Bounds().Min, draw.Src)
+	mon.setOverride("image", rgba, d)
+	return nil
+}
+
+func (mon *monitor) setOverride(kind string, img *image.RGBA, d time.Duration) {
+	mon.mu.Lock()
+	mon.override = &override{kind: kind, img: img, until: time.Now().Add(d)}
+	mon.mu.Unlock()
+
+	mon.requestRedraw()
+	// Redraw again once it expires so the page comes back promptly
+	time.AfterFunc(d, mon.requestRedraw)
+}
+
+func (mon *monitor) SetBrightness(brightness int) error {
+	mon.mu.Lock()
+	mon.brightness = brightness
+	mon.brightnessDirty = true
+	mon.mu.Unlock()
+
+	mon.requestRedraw()
+	return nil
+}
+
+func (mon *monitor) Acknowledge() int {
+	n := mon.alerts.AcknowledgeAll()
+	mon.requestRedraw()
+	return n
+}
+
+// currentPage names what the panel is showing, including overrides
+func (mon *monitor) currentPage() string {
+	if _, ok := mon.alerts.Takeover(); ok {
+		return "alert"
+	}
+
+	mon.mu.Lock()
+	defer mon.mu.Unlock()
+	if mon.override != nil && time.Now().Before(mon.override.until) {
+		return mon.override.kind
+	}
+	if mon.showingMessage {
+		return "message"
+	}
+	return mon.page
+}
+
+func (mon *monitor) State() control.State {
+	state := control.State{
+		Page:  mon.currentPage(),
+		Pages: pages,
+	}
+
+	mon.mu.Lock()
+	state.Brightness = mon.brightness
+	if ov := mon.override; ov != nil && time.Now().Before(ov.until) {
+		until := ov.until
+		state.Override = ov.kind
+		state.OverrideUntil = &until
+	}
+	if !mon.frameTime.IsZero() {
+		t := mon.frameTime
+		state.LastFrame = &t
+	}
+	mon.mu.Unlock()
+
+	for _, st := range mon.alerts.Statuses() {
+		state.Alerts = append(state.Alerts, control.AlertState{
+			Name:         st.Rule.Name,
+			Metric:       st.Rule.Metric,
+			State:        st.State.String(),
+			Severity:     st.Rule.Severity.String(),
+			Value:        st.Value,
+			Since:        st.Since,
+			Acknowledged: st.Acknowledged,
+		})
+	}
+
+	for _, msg := range mon.messages.Pending(time.Now()) {
+		state.Messages = append(state.Messages, control.MessageState{
+			Key:      msg.Key,
+			Text:     msg.Text,
+			Priority: msg.Priority.String(),
+			Count:    msg.Count,
+			Expires:  msg.Expires,
+		})
+	}
+
+	for _, st := range mon.devices.Statuses() {
+		ds := control.DeviceState{
+			Name:      st.Name,
+			Pages:     st.Pages,
+			Online:    st.Online,
+			Failures:  st.Failures,
+			LastError: st.LastError,
+		}
+		if !st.LastSuccess.IsZero() {
+			t := st.LastSuccess
+			ds.LastSuccess = &t
+		}
+		state.Devices = append(state.Devices, ds)
+	}
+
+	if m, _ := mon.sampler.Latest(); m != nil {
+		state.Metrics = make(map[string]float64, len(m.Values))
+		for name, v := range m.Values {
+			state.Metrics[name] = v.Value
+		}
+	}
+	return state
+}
+
+func (mon *monitor) Frame() image.Image {
+	mon.mu.Lock()
+	defer mon.mu.Unlock()
+
+	if mon.frame == nil {
 		return nil
 	}
+	frame := image.NewRGBA(mon.frame.Bounds())
+	copy(frame.Pix, mon.frame.Pix)
+	return frame
+}
+
+// frame is the content of one update for a panel: an image, optionally
+// with a scrolling message line, or a line for the device's own text
+// renderer
+type frame struct {
+	img       *image.RGBA // also kept for GET /frame.png
+	text      string      // drawn with DrawText instead of img when set
+	textColor color.RGBA
+	scroll    *notify.Message
+	message   bool
+}
+
+// panel tracks what the main loop last sent to one device
+type panel struct {
+	// turn indexes the device's page set
+	turn int
+	// overlay is set while device-rendered text may be on screen
+	overlay bool
+	// text is set while the device shows the text page
+	text bool
+}
+
+// textOverlay is implemented by clients that can scroll text over the
+// current image
+type textOverlay interface {
+	DrawScrollingTextContext(ctx context.Context, text string, y int, r, g, b uint8, speed int) error
+	ClearTextContext(ctx context.Context) error
+}
+
+func (mon *monitor) panel(name string) *panel {
+	p, ok := mon.panels[name]
+	if !ok {
+		p = &panel{}
+		mon.panels[name] = p
+	}
+	return p
+}
+
+// rotatePages advances every device with a page set to its next page
+func (mon *monitor) rotatePages() {
+	for _, name := range mon.devices.Names() {
+		if len(mon.devices.Pages(name)) > 0 {
+			mon.panel(name).turn++
+		}
+	}
+}
 
-	// Image mode (slower but with graphics)
+// devicePage returns the page a device shows: the next of its own page
+// set, or the shared page
+func (mon *monitor) devicePage(name, shared string) string {
+	set := mon.devices.Pages(name)
+	if len(set) == 0 {
+		return shared
+	}
+	return set[mon.panel(name).turn%len(set)]
+}
+
+// submit queues f for a device. Text overlays left by the previous frame
+// are cleared first, and the text page gets a blank background when it
+// follows an image.
+func (mon *monitor) submit(name string, f frame) {
+	p := mon.panel(name)
+	isText := f.text != ""
+	if p.overlay && !(isText && p.text) {
+		mon.devices.Submit(name, "clear-text", func(ctx context.Context, c pixoo.PixooClient) error {
+			if o, ok := c.(textOverlay); ok {
+				return o.ClearTextContext(ctx)
+			}
+			return nil
+		})
+	}
+	blank := isText && !p.text
+	p.overlay = isText || f.scroll != nil
+	p.text = isText
+
+	mon.devices.Submit(name, "frame", func(ctx context.Context, c pixoo.PixooClient) error {
+		if isText {
+			// Device text sits on top of the image layer, which needs
+			// clearing; clients without one render the text themselves
+			if _, ok := c.(textOverlay); ok && blank {
+				if err := c.DrawImageContext(ctx, blankFrame); err != nil {
+					return fmt.Errorf("draw image: %w", err)
+				}
+			}
+			if err := c.DrawTextContext(ctx, f.text, f.textColor.R, f.textColor.G, f.textColor.B); err != nil {
+				return fmt.Errorf("draw text: %w", err)
+			}
+			return nil
+		}
+
+		if err := c.DrawImageContext(ctx, f.img); err != nil {
+			return fmt.Errorf("draw image: %w", err)
+		}
+		if o, ok := c.(textOverlay); ok && f.scroll != nil {
+			sc := f.scroll.Color
+			if err := o.DrawScrollingTextContext(ctx, f.scroll.Scroll, notify.ScrollY-1, sc.R, sc.G, sc.B, 60); err != nil {
+				return fmt.Errorf("draw scrolling text: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+// blankFrame is a black image shown under the text page
+var blankFrame = func() *image.RGBA {
 	img := pixoo.CreateImage()
+	pixoo.FillRect(img, 0, 0, 64, 64, color.RGBA{0, 0, 0, 255})
+	return img
+}()
 
-	// Colors
-	bgColor := color.RGBA{0, 0, 0, 255}        // Black background
-	cpuColor := color.RGBA{0, 255, 0, 255}     // Green for CPU
-	memColor := color.RGBA{0, 150, 255, 255}   // Blue for Memory
-	textColor := color.RGBA{255, 255, 255, 255} // White text
+func (mon *monitor) recordFrame(img *image.RGBA, message bool) {
+	mon.mu.Lock()
+	defer mon.mu.Unlock()
+	mon.frame = img
+	mon.frameTime = time.Now()
+	mon.showingMessage = message
+}
 
-	// Fill background
-	pixoo.FillRect(img, 0, 0, 64, 64, bgColor)
+// nextMessage picks the queued message to show on this update, if any.
+// High priority messages take every update; others alternate with the page.
+func (mon *monitor) nextMessage() (notify.Message, bool) {
+	top, ok := mon.messages.Top(time.Now())
+	if !ok {
+		mon.messageTurn = false
+		return notify.Message{}, false
+	}
 
-	// Draw title
-	pixoo.DrawTextOnImage(img, "CPU:", 2, 2, textColor)
-	cpuText := fmt.Sprintf("%2.0f%%", m.CPUPercent)
-	pixoo.DrawTextOnImage(img, cpuText, 30, 2, textColor)
+	if top < notify.High {
+		mon.messageTurn = !mon.messageTurn
+		if !mon.messageTurn {
+			return notify.Message{}, false
+		}
+	}
+	return mon.messages.Next(time.Now())
+}
 
-	// Draw CPU bar
-	cpuBarWidth := int((m.CPUPercent / 100.0) * 60)
-	pixoo.FillRect(img, 2, 12, 2+cpuBarWidth, 16, cpuColor)
+func (mon *monitor) updateDisplay() error {
+	mon.mu.Lock()
+	page := mon.page
+	brightness, applyBrightness := mon.brightness, mon.brightnessDirty
+	mon.brightnessDirty = false
+	ov := mon.override
+	if ov != nil && !time.Now().Before(ov.until) {
+		ov, mon.override = nil, nil
+	}
+	mon.mu.Unlock()
 
-	// Draw memory info
-	pixoo.DrawTextOnImage(img, "MEM:", 2, 20, textColor)
-	memText := fmt.Sprintf("%2.0f%%", m.MemoryPercent)
-	pixoo.DrawTextOnImage(img, memText, 30, 20, textColor)
+	if applyBrightness {
+		mon.devices.Broadcast("brightness", func(ctx context.Context, c pixoo.PixooClient) error {
+			return c.SetBrightnessContext(ctx, brightness)
+		})
+	}
+
+	// Read the latest sampled metrics. Before the first sample there is
+	// nothing to draw yet; the sample requests a redraw when it arrives.
+	m, err := mon.sampler.Latest()
+	if errors.Is(err, metrics.ErrNoSample) {
+		return nil
+	}
+	if m == nil {
+		return fmt.Errorf("collect metrics: %w", err)
+	}
+	// A failed collection leaves the previous metrics on screen, so
+	// overrides and messages still get through
+	if err != nil {
+		log.Printf("Showing metrics from %s: %v", m.Timestamp.Format(time.TimeOnly), err)
+	}
 
-	// Draw memory bar
-	memBarWidth := int((m.MemoryPercent / 100.0) * 60)
-	pixoo.FillRect(img, 2, 30, 2+memBarWidth, 34, memColor)
+	// Log metrics
+	log.Println(m.String())
 
-	// Draw memory usage in GB
-	memGBText := fmt.Sprintf("%.1fG", m.MemoryUsedGB)
-	pixoo.DrawTextOnImage(img, memGBText, 2, 38, textColor)
+	_, hasTakeover := mon.alerts.Takeover()
 
-	// Draw network stats (if available)
-	if m.NetRecvMB > 0 || m.NetSentMB > 0 {
-		netText := fmt.Sprintf("%.1fM", m.NetRecvMB)
-		pixoo.DrawTextOnImage(img, netText, 2, 50, textColor)
+	// Images pushed through the API and queued notifications go to every
+	// panel; only a takeover alert outranks them
+	var top *frame
+	if ov != nil && !hasTakeover {
+		log.Printf("Sending %s to display...", ov.kind)
+		top = &frame{img: ov.img}
+	} else if !hasTakeover {
+		if msg, ok := mon.nextMessage(); ok {
+			log.Printf("Sending message %q to display...", msg.Text)
+			top = &frame{img: notify.Render(msg), message: true}
+			if msg.Scroll != "" {
+				top.scroll = &msg
+			}
+		}
 	}
 
-	// Send image to display
-	log.Println("Sending image to display...")
-	if err := client.DrawImage(img); err != nil {
-		return fmt.Errorf("draw image: %w", err)
+	// Otherwise each panel shows its own page, rendered once per update
+	// and canvas size
+	type renderKey struct {
+		page string
+		size image.Point
 	}
-	log.Println("Image sent successfully")
+	rendered := make(map[renderKey]frame)
+	for i, name := range mon.devices.Names() {
+		var f frame
+		if top != nil {
+			f = *top
+		} else {
+			key := renderKey{mon.devicePage(name, page), mon.canvasSize(name)}
+			var ok bool
+			if f, ok = rendered[key]; !ok {
+				f = mon.renderPage(key.page, key.size, m)
+				rendered[key] = f
+			}
+		}
 
+		mon.submit(name, f)
+		// GET /frame.png shows the first panel
+		if i == 0 {
+			mon.recordFrame(f.img, f.message)
+		}
+	}
 	return nil
 }
+
+// canvasSize returns the size of the image a device shows in full: the
+// whole canvas of a video wall, one panel otherwise
+func (mon *monitor) canvasSize(name string) image.Point {
+	if c, ok := mon.devices.Client(name).(interface{ Bounds() image.Rectangle }); ok {
+		return c.Bounds().Size()
+	}
+	return image.Pt(64, 64)
+}
+
+// renderPage draws one page of the given size for the latest metrics m
+func (mon *monitor) renderPage(page string, size image.Point, m *metrics.SystemMetrics) frame {
+	f := dashboard.RenderSize(page, dashboard.Input{
+		Metrics:    m,
+		CPUHistory: mon.history.Values(metrics.MetricCPUTotal, time.Minute),
+		Alerts:     mon.alerts,
+		FlashOn:    mon.flashOn,
+	}, size.X, size.Y)
+	if f.Text == "" {
+		if f.Takeover {
+			log.Println("Sending alert page to display...")
+		} else {
+			log.Println("Sending image to display...")
+		}
+	}
+	return frame{img: f.Image, text: f.Text, textColor: f.TextColor}
+}
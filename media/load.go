@@ -0,0 +1,305 @@
+// Package media loads pictures and animations from files and URLs and
+// fits them to the panel, ready to show with Show.
+package media
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	_ "image/jpeg" // register JPEG decoder
+	_ "image/png"  // register PNG decoder
+	"io"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	xdraw "golang.org/x/image/draw"
+	_ "golang.org/x/image/webp" // register WebP decoder
+
+	"divoom-monitor/pixoo"
+)
+
+// MaxBytes bounds the size of a file or download
+const MaxBytes = 32 << 20
+
+// MaxFrames bounds how many frames of an animation are decoded
+const MaxFrames = 1000
+
+// defaultDelay is how long a GIF frame without a usable delay is shown,
+// as browsers do
+const defaultDelay = 100 * time.Millisecond
+
+// Fit is how a picture is fitted to the square panel
+type Fit int
+
+const (
+	// Contain shows the whole picture, with black bars if it is not square
+	Contain Fit = iota
+	// Cover fills the panel, cropping the edges of the longer side
+	Cover
+	// Stretch fills the panel, distorting the aspect ratio
+	Stretch
+)
+
+// ParseFit parses "contain", "cover" or "stretch"
+func ParseFit(s string) (Fit, error) {
+	switch s {
+	case "contain":
+		return Contain, nil
+	case "cover":
+		return Cover, nil
+	case "stretch":
+		return Stretch, nil
+	}
+	return 0, fmt.Errorf("unknown fit %q (use contain, cover or stretch)", s)
+}
+
+// Options controls how pictures are fitted to the panel
+type Options struct {
+	Fit Fit
+	// Pixelated scales with nearest-neighbor sampling, which keeps pixel
+	// art and sprites sharp; otherwise a smooth filter suits photos
+	Pixelated bool
+	// Colors, if set, reduces each frame to a palette of that many colors
+	// (2-256) with Floyd-Steinberg dithering
+	Colors int
+}
+
+// Frame is one 64x64 picture and how long it is shown. Stills have a
+// single frame with no delay.
+type Frame struct {
+	Image *pixoo.Canvas
+	Delay time.Duration
+}
+
+// Load reads a picture from a file path or an http(s) URL. PNG, JPEG,
+// WebP and GIF are supported; every frame of an animated GIF is kept.
+func Load(ctx context.Context, src string, opts Options) ([]Frame, error) {
+	var data []byte
+	var err error
+	if strings.HasPrefix(src, "http://") || strings.HasPrefix(src, "https://") {
+		data, err = fetch(ctx, src)
+	} else {
+		data, err = readFile(src)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return Decode(bytes.NewReader(data), opts)
+}
+
+func fetch(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("download image: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("download image: unexpected status code: %d", resp.StatusCode)
+	}
+	return readLimited(resp.Body)
+}
+
+func readFile(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open image: %w", err)
+	}
+	defer f.Close()
+	return readLimited(f)
+}
+
+func readLimited(r io.Reader) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, MaxBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("read image: %w", err)
+	}
+	if len(data) > MaxBytes {
+		return nil, fmt.Errorf("image is larger than %d MB", MaxBytes>>20)
+	}
+	return data, nil
+}
+
+// Decode reads a picture and fits it to the panel
+func Decode(r io.ReadSeeker, opts Options) ([]Frame, error) {
+	if opts.Colors != 0 && (opts.Colors < 2 || opts.Colors > 256) {
+		return nil, fmt.Errorf("colors must be between 2 and 256")
+	}
+
+	_, format, err := image.DecodeConfig(r)
+	if err != nil {
+		return nil, fmt.Errorf("decode image: %w", err)
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("read image: %w", err)
+	}
+
+	if format == "gif" {
+		g, err := gif.DecodeAll(r)
+		if err != nil {
+			return nil, fmt.Errorf("decode gif: %w", err)
+		}
+		return gifFrames(g, opts), nil
+	}
+
+	img, _, err := image.Decode(r)
+	if err != nil {
+		return nil, fmt.Errorf("decode image: %w", err)
+	}
+	return []Frame{{Image: fitImage(img, opts)}}, nil
+}
+
+// gifFrames composes each GIF frame onto the ones before it, following
+// the frame's disposal method, and fits the result
+func gifFrames(g *gif.GIF, opts Options) []Frame {
+	bounds := image.Rect(0, 0, g.Config.Width, g.Config.Height)
+	if bounds.Empty() && len(g.Image) > 0 {
+		bounds = g.Image[0].Bounds()
+	}
+	screen := image.NewRGBA(bounds)
+
+	n := min(len(g.Image), MaxFrames)
+	frames := make([]Frame, 0, n)
+	for i, img := range g.Image[:n] {
+		disposal := byte(0)
+		if i < len(g.Disposal) {
+			disposal = g.Disposal[i]
+		}
+		var previous *image.RGBA
+		if disposal == gif.DisposalPrevious {
+			previous = image.NewRGBA(bounds)
+			copy(previous.Pix, screen.Pix)
+		}
+
+		draw.Draw(screen, img.Bounds(), img, img.Bounds().Min, draw.Over)
+
+		delay := defaultDelay
+		if i < len(g.Delay) && g.Delay[i] > 1 {
+			delay = time.Duration(g.Delay[i]) * 10 * time.Millisecond
+		}
+		frames = append(frames, Frame{Image: fitImage(screen, opts), Delay: delay})
+
+		switch disposal {
+		case gif.DisposalBackground:
+			draw.Draw(screen, img.Bounds(), image.Transparent, image.Point{}, draw.Src)
+		case gif.DisposalPrevious:
+			screen = previous
+		}
+	}
+
+	if len(frames) == 1 {
+		frames[0].Delay = 0
+	}
+	return frames
+}
+
+// fitImage scales img onto a black 64x64 canvas
+func fitImage(img image.Image, opts Options) *pixoo.Canvas {
+	src := img.Bounds()
+	dst := image.Rect(0, 0, 64, 64)
+	if src.Dx() > 0 && src.Dy() > 0 {
+		switch opts.Fit {
+		case Contain:
+			dst = fitRect(src.Dx(), src.Dy(), false)
+		case Cover:
+			dst = fitRect(src.Dx(), src.Dy(), true)
+		}
+	}
+
+	var scaler xdraw.Scaler = xdraw.CatmullRom
+	if opts.Pixelated {
+		scaler = xdraw.NearestNeighbor
+	}
+
+	// Scale onto black, so transparent areas come out black like the
+	// unlit panel
+	rgba := image.NewRGBA(image.Rect(0, 0, 64, 64))
+	draw.Draw(rgba, rgba.Bounds(), image.NewUniform(color.Black), image.Point{}, draw.Src)
+	scaler.Scale(rgba, dst, img, src, draw.Over, nil)
+
+	var out image.Image = rgba
+	if opts.Colors > 0 {
+		pal := image.NewPaletted(rgba.Bounds(), adaptivePalette(rgba, opts.Colors))
+		draw.FloydSteinberg.Draw(pal, pal.Bounds(), rgba, image.Point{})
+		out = pal
+	}
+
+	canvas := pixoo.NewCanvas()
+	draw.Draw(canvas, canvas.Bounds(), out, image.Point{}, draw.Src)
+	return canvas
+}
+
+// fitRect returns where a w x h picture goes on the panel: inside it, or
+// covering it when cover is set, centered either way
+func fitRect(w, h int, cover bool) image.Rectangle {
+	scale := min(64/float64(w), 64/float64(h))
+	if cover {
+		scale = max(64/float64(w), 64/float64(h))
+	}
+	sw := max(int(float64(w)*scale+0.5), 1)
+	sh := max(int(float64(h)*scale+0.5), 1)
+	x, y := (64-sw)/2, (64-sh)/2
+	return image.Rect(x, y, x+sw, y+sh)
+}
+
+// adaptivePalette picks n colors for img by median cut: the pixels are
+// split into n boxes, each time halving the box with the widest channel
+// range at its median, and each box is averaged into one color
+func adaptivePalette(img *image.RGBA, n int) color.Palette {
+	pixels := make([][3]uint8, 0, len(img.Pix)/4)
+	for i := 0; i < len(img.Pix); i += 4 {
+		pixels = append(pixels, [3]uint8{img.Pix[i], img.Pix[i+1], img.Pix[i+2]})
+	}
+
+	boxes := [][][3]uint8{pixels}
+	for len(boxes) < n {
+		// Split the box whose widest channel spans the most
+		best, bestChannel, bestRange := -1, 0, 0
+		for i, box := range boxes {
+			if len(box) < 2 {
+				continue
+			}
+			for c := range 3 {
+				lo, hi := box[0][c], box[0][c]
+				for _, p := range box {
+					lo, hi = min(lo, p[c]), max(hi, p[c])
+				}
+				if r := int(hi - lo); r > bestRange {
+					best, bestChannel, bestRange = i, c, r
+				}
+			}
+		}
+		if best < 0 {
+			break // every box holds a single color
+		}
+		box := boxes[best]
+		slices.SortFunc(box, func(a, b [3]uint8) int {
+			return int(a[bestChannel]) - int(b[bestChannel])
+		})
+		mid := len(box) / 2
+		boxes[best] = box[:mid]
+		boxes = append(boxes, box[mid:])
+	}
+
+	pal := make(color.Palette, 0, len(boxes))
+	for _, box := range boxes {
+		var r, g, b int
+		for _, p := range box {
+			r, g, b = r+int(p[0]), g+int(p[1]), b+int(p[2])
+		}
+		k := len(box)
+		pal = append(pal, color.RGBA{uint8(r / k), uint8(g / k), uint8(b / k), 255})
+	}
+	return pal
+}
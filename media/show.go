@@ -0,0 +1,105 @@
+package media
+
+import (
+	"context"
+	"image"
+	"time"
+
+	"divoom-monitor/pixoo"
+)
+
+// animator is implemented by clients the device plays animations on by
+// itself, like pixoo.Client
+type animator interface {
+	DrawAnimationContext(ctx context.Context, frames []image.Image, speed time.Duration) error
+}
+
+// Show puts frames on c. A still is drawn once. An animation is uploaded
+// to clients that play it themselves (see Uniform for how its timing is
+// kept); other clients are sent one frame at a time with the original
+// delays, once, or until ctx is done when loop is set.
+func Show(ctx context.Context, c pixoo.PixooClient, frames []Frame, loop bool) error {
+	if len(frames) == 1 {
+		return c.DrawImageContext(ctx, frames[0].Image)
+	}
+	if a, ok := c.(animator); ok {
+		images, speed := Uniform(frames, pixoo.MaxAnimationFrames)
+		return a.DrawAnimationContext(ctx, images, speed)
+	}
+	return Play(ctx, c, frames, loop)
+}
+
+// Play sends frames to c one at a time, each held for its delay
+func Play(ctx context.Context, c pixoo.PixooClient, frames []Frame, loop bool) error {
+	for {
+		for _, f := range frames {
+			start := time.Now()
+			if err := c.DrawImageContext(ctx, f.Image); err != nil {
+				return err
+			}
+			select {
+			case <-time.After(f.Delay - time.Since(start)):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		if !loop {
+			return nil
+		}
+	}
+}
+
+// Uniform turns frames with individual delays into at most maxFrames
+// images shown for one common duration, as the device plays them. A frame
+// is repeated to last a multiple of that duration. When that needs too many
+// images, the animation is sampled evenly at a coarser pace instead: each
+// image shows the frame on screen at that point of the original timeline,
+// so the loop keeps its length and frames shorter than the pace are merged
+// into their neighbours.
+func Uniform(frames []Frame, maxFrames int) ([]image.Image, time.Duration) {
+	const step = 10 * time.Millisecond
+
+	// The largest step-multiple dividing every delay keeps all timings
+	// exact
+	delays := make([]time.Duration, len(frames))
+	var speed, total time.Duration
+	for i, f := range frames {
+		delays[i] = max(f.Delay.Round(step), step)
+		speed = gcd(speed, delays[i])
+		total += delays[i]
+	}
+
+	if int(total/speed) <= maxFrames {
+		images := make([]image.Image, 0, total/speed)
+		for i, f := range frames {
+			for range delays[i] / speed {
+				images = append(images, f.Image)
+			}
+		}
+		return images, speed
+	}
+
+	// The smallest step-multiple that fits the loop into maxFrames images
+	speed = (total + time.Duration(maxFrames) - 1) / time.Duration(maxFrames)
+	speed = (speed + step - 1) / step * step
+	n := max(int((total+speed/2)/speed), 1)
+
+	images := make([]image.Image, 0, n)
+	i, end := 0, delays[0]
+	for slot := range n {
+		at := time.Duration(slot)*speed + speed/2
+		for at >= end && i < len(frames)-1 {
+			i++
+			end += delays[i]
+		}
+		images = append(images, frames[i].Image)
+	}
+	return images, speed
+}
+
+func gcd(a, b time.Duration) time.Duration {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
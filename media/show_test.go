@@ -0,0 +1,158 @@
+package media
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/gif"
+	"slices"
+	"testing"
+	"time"
+
+	"divoom-monitor/pixoo"
+)
+
+// encodeGIF builds a GIF whose frames are solid colors 0, 1, 2, ... of a
+// grey ramp, with the given delays in 1/100s
+func encodeGIF(t *testing.T, delays []int) []byte {
+	t.Helper()
+	pal := make(color.Palette, 256)
+	for i := range pal {
+		pal[i] = color.RGBA{uint8(i), uint8(i), uint8(i), 255}
+	}
+	g := &gif.GIF{}
+	for i, d := range delays {
+		p := image.NewPaletted(image.Rect(0, 0, 16, 16), pal)
+		for j := range p.Pix {
+			p.Pix[j] = uint8(i % 256)
+		}
+		g.Image = append(g.Image, p)
+		g.Delay = append(g.Delay, d)
+	}
+	var buf bytes.Buffer
+	if err := gif.EncodeAll(&buf, g); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func decodeGIF(t *testing.T, delays []int) []Frame {
+	t.Helper()
+	frames, err := Decode(bytes.NewReader(encodeGIF(t, delays)), Options{Pixelated: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(frames) != len(delays) {
+		t.Fatalf("decoded %d frames, want %d", len(frames), len(delays))
+	}
+	return frames
+}
+
+// shade returns which frame of encodeGIF an image shows
+func shade(img image.Image) int {
+	r, _, _, _ := img.At(32, 32).RGBA()
+	return int(r >> 8)
+}
+
+func shades(images []image.Image) []int {
+	s := make([]int, len(images))
+	for i, img := range images {
+		s[i] = shade(img)
+	}
+	return s
+}
+
+func TestUniformMixedDelays(t *testing.T) {
+	tests := []struct {
+		name      string
+		delays    []int
+		wantSpeed time.Duration
+		want      []int
+	}{
+		{
+			name:      "common divisor",
+			delays:    []int{10, 30, 5, 20},
+			wantSpeed: 50 * time.Millisecond,
+			want:      []int{0, 0, 1, 1, 1, 1, 1, 1, 2, 3, 3, 3, 3},
+		},
+		{
+			name:      "equal delays",
+			delays:    []int{8, 8, 8},
+			wantSpeed: 80 * time.Millisecond,
+			want:      []int{0, 1, 2},
+		},
+		{
+			name:      "missing delays default to 100ms",
+			delays:    []int{0, 20},
+			wantSpeed: 100 * time.Millisecond,
+			want:      []int{0, 1, 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			images, speed := Uniform(decodeGIF(t, tt.delays), pixoo.MaxAnimationFrames)
+			if speed != tt.wantSpeed {
+				t.Errorf("speed = %v, want %v", speed, tt.wantSpeed)
+			}
+			if got := shades(images); !slices.Equal(got, tt.want) {
+				t.Errorf("frames = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUniformLongAnimation(t *testing.T) {
+	delays := make([]int, 100)
+	for i := range delays {
+		delays[i] = 10
+	}
+	images, speed := Uniform(decodeGIF(t, delays), pixoo.MaxAnimationFrames)
+
+	if len(images) > pixoo.MaxAnimationFrames {
+		t.Fatalf("got %d images, want at most %d", len(images), pixoo.MaxAnimationFrames)
+	}
+	// The loop keeps its 10s length, give or take one image
+	if loop := time.Duration(len(images)) * speed; loop < 10*time.Second-speed || loop > 10*time.Second+speed {
+		t.Errorf("loop lasts %v (%d x %v), want about 10s", loop, len(images), speed)
+	}
+	// Frames are sampled evenly, in order, across the whole animation
+	got := shades(images)
+	for i := 1; i < len(got); i++ {
+		if d := got[i] - got[i-1]; d < 1 || d > 2 {
+			t.Fatalf("frames = %v, want an even walk through 0..99", got)
+		}
+	}
+	if got[0] > 1 || got[len(got)-1] < 98 {
+		t.Errorf("frames = %v, want the first and last frames covered", got)
+	}
+}
+
+func TestUniformOverflowWithMixedDelays(t *testing.T) {
+	// 60 frames alternating 20ms and 200ms: too many repeats for the
+	// device at 20ms, so the short frames merge into their neighbours
+	delays := make([]int, 60)
+	for i := range delays {
+		delays[i] = 2
+		if i%2 == 1 {
+			delays[i] = 20
+		}
+	}
+	images, speed := Uniform(decodeGIF(t, delays), pixoo.MaxAnimationFrames)
+
+	if len(images) > pixoo.MaxAnimationFrames {
+		t.Fatalf("got %d images, want at most %d", len(images), pixoo.MaxAnimationFrames)
+	}
+	if speed%(10*time.Millisecond) != 0 {
+		t.Errorf("speed %v is not a multiple of 10ms", speed)
+	}
+	// Every long frame lasts longer than one image, so each shows up
+	seen := make(map[int]bool)
+	for _, s := range shades(images) {
+		seen[s] = true
+	}
+	for i := 1; i < 60; i += 2 {
+		if !seen[i] {
+			t.Errorf("frame %d (200ms) is missing from %v", i, shades(images))
+		}
+	}
+}
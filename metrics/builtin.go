@@ -0,0 +1,255 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/shirou/gopsutil/v3/cpu"
+	"github.com/shirou/gopsutil/v3/disk"
+	"github.com/shirou/gopsutil/v3/mem"
+	"github.com/shirou/gopsutil/v3/net"
+)
+
+// Units used by the built-in sources
+const (
+	UnitPercent         = "percent"
+	UnitGigabytes       = "GB"
+	UnitMegabytesPerSec = "MB/s"
+)
+
+// Additional metric names produced by the built-in sources
+const (
+	MetricMemTotalGB = "mem.total_gb"
+	// MetricDiskPrefix is followed by the mount point, e.g. "disk./"
+	MetricDiskPrefix = "disk."
+)
+
+// RegisterBuiltins adds the cpu, mem, net and disk sources to r
+func RegisterBuiltins(r *Registry) error {
+	for _, s := range []Source{
+		NewCPUSource(),
+		NewMemorySource(),
+		NewNetworkSource(),
+		NewDiskSource(),
+	} {
+		if err := r.Register(s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CPUSource reports total CPU usage since its previous Collect, so it
+// never blocks waiting for a sample window
+type CPUSource struct {
+	mu       sync.Mutex
+	lastTime *cpu.TimesStat
+}
+
+func NewCPUSource() *CPUSource {
+	s := &CPUSource{}
+
+	// Prime the counters so the first Collect has a baseline
+	if times, err := cpu.Times(false); err == nil && len(times) > 0 {
+		s.lastTime = &times[0]
+	}
+	return s
+}
+
+func (s *CPUSource) Name() string { return "cpu" }
+
+func (s *CPUSource) Schema() []Desc {
+	return []Desc{
+		{Name: MetricCPUTotal, Help: "CPU usage across all cores", Unit: UnitPercent, Kind: Gauge},
+	}
+}
+
+func (s *CPUSource) Collect(ctx context.Context) ([]Value, error) {
+	times, err := cpu.TimesWithContext(ctx, false)
+	if err != nil {
+		return nil, fmt.Errorf("get cpu times: %w", err)
+	}
+	if len(times) == 0 {
+		return nil, nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current := times[0]
+	var percent float64
+	if s.lastTime != nil {
+		percent = cpuBusyPercent(*s.lastTime, current)
+	}
+	s.lastTime = &current
+
+	return []Value{{Name: MetricCPUTotal, Value: percent, Unit: UnitPercent}}, nil
+}
+
+// cpuBusyPercent returns the share of non-idle time between two samples,
+// matching the calculation gopsutil uses for cpu.Percent
+func cpuBusyPercent(prev, cur cpu.TimesStat) float64 {
+	prevAll, prevBusy := cpuAllBusy(prev)
+	curAll, curBusy := cpuAllBusy(cur)
+
+	if curBusy <= prevBusy {
+		return 0
+	}
+	if curAll <= prevAll {
+		return 100
+	}
+	percent := (curBusy - prevBusy) / (curAll - prevAll) * 100
+	if percent > 100 {
+		return 100
+	}
+	return percent
+}
+
+func cpuAllBusy(t cpu.TimesStat) (float64, float64) {
+	total := t.Total()
+	if runtime.GOOS == "linux" {
+		// Guest time is already accounted for in User/Nice
+		total -= t.Guest
+		total -= t.GuestNice
+	}
+	return total, total - t.Idle - t.Iowait
+}
+
+// MemorySource reports virtual memory usage
+type MemorySource struct{}
+
+func NewMemorySource() *MemorySource {
+	return &MemorySource{}
+}
+
+func (s *MemorySource) Name() string { return "mem" }
+
+func (s *MemorySource) Schema() []Desc {
+	return []Desc{
+		{Name: MetricMemPercent, Help: "Memory in use", Unit: UnitPercent, Kind: Gauge},
+		{Name: MetricMemUsedGB, Help: "Memory in use", Unit: UnitGigabytes, Kind: Gauge},
+		{Name: MetricMemTotalGB, Help: "Total memory", Unit: UnitGigabytes, Kind: Gauge},
+	}
+}
+
+func (s *MemorySource) Collect(ctx context.Context) ([]Value, error) {
+	vmStat, err := mem.VirtualMemoryWithContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("get memory stats: %w", err)
+	}
+
+	return []Value{
+		{Name: MetricMemPercent, Value: vmStat.UsedPercent, Unit: UnitPercent},
+		{Name: MetricMemUsedGB, Value: float64(vmStat.Used) / 1024 / 1024 / 1024, Unit: UnitGigabytes},
+		{Name: MetricMemTotalGB, Value: float64(vmStat.Total) / 1024 / 1024 / 1024, Unit: UnitGigabytes},
+	}, nil
+}
+
+// NetworkSource reports send/receive throughput since its previous Collect
+type NetworkSource struct {
+	mu            sync.Mutex
+	lastNetStat   *net.IOCountersStat
+	lastCheckTime time.Time
+}
+
+func NewNetworkSource() *NetworkSource {
+	return &NetworkSource{}
+}
+
+func (s *NetworkSource) Name() string { return "net" }
+
+func (s *NetworkSource) Schema() []Desc {
+	return []Desc{
+		{Name: MetricNetSentMB, Help: "Network send rate", Unit: UnitMegabytesPerSec, Kind: Gauge},
+		{Name: MetricNetRecvMB, Help: "Network receive rate", Unit: UnitMegabytesPerSec, Kind: Gauge},
+	}
+}
+
+func (s *NetworkSource) Collect(ctx context.Context) ([]Value, error) {
+	netStats, err := net.IOCountersWithContext(ctx, false)
+	if err != nil {
+		return nil, fmt.Errorf("get network stats: %w", err)
+	}
+	if len(netStats) == 0 {
+		return nil, nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	currentStat := &netStats[0]
+	var sentMB, recvMB float64
+
+	if s.lastNetStat != nil {
+		// Calculate rate since last check
+		timeDiff := time.Since(s.lastCheckTime).Seconds()
+		if timeDiff > 0 {
+			bytesSent := float64(currentStat.BytesSent - s.lastNetStat.BytesSent)
+			bytesRecv := float64(currentStat.BytesRecv - s.lastNetStat.BytesRecv)
+
+			sentMB = (bytesSent / 1024 / 1024) / timeDiff
+			recvMB = (bytesRecv / 1024 / 1024) / timeDiff
+		}
+	}
+
+	s.lastNetStat = currentStat
+	s.lastCheckTime = time.Now()
+
+	return []Value{
+		{Name: MetricNetSentMB, Value: sentMB, Unit: UnitMegabytesPerSec},
+		{Name: MetricNetRecvMB, Value: recvMB, Unit: UnitMegabytesPerSec},
+	}, nil
+}
+
+// DiskSource reports used space for each physical partition as
+// "disk.<mountpoint>", e.g. "disk./" or "disk./home"
+type DiskSource struct{}
+
+func NewDiskSource() *DiskSource {
+	return &DiskSource{}
+}
+
+func (s *DiskSource) Name() string { return "disk" }
+
+func (s *DiskSource) Schema() []Desc {
+	partitions, err := disk.Partitions(false)
+	if err != nil {
+		return nil
+	}
+
+	descs := make([]Desc, 0, len(partitions))
+	for _, p := range partitions {
+		descs = append(descs, Desc{
+			Name: MetricDiskPrefix + p.Mountpoint,
+			Help: "Disk space in use on " + p.Mountpoint,
+			Unit: UnitPercent,
+			Kind: Gauge,
+		})
+	}
+	return descs
+}
+
+func (s *DiskSource) Collect(ctx context.Context) ([]Value, error) {
+	partitions, err := disk.PartitionsWithContext(ctx, false)
+	if err != nil {
+		return nil, fmt.Errorf("list partitions: %w", err)
+	}
+
+	values := make([]Value, 0, len(partitions))
+	for _, p := range partitions {
+		usage, err := disk.UsageWithContext(ctx, p.Mountpoint)
+		if err != nil {
+			// Skip mounts we can't stat (e.g. permission denied)
+			continue
+		}
+		values = append(values, Value{
+			Name:  MetricDiskPrefix + p.Mountpoint,
+			Value: usage.UsedPercent,
+			Unit:  UnitPercent,
+		})
+	}
+	return values, nil
+}
@@ -1,12 +1,9 @@
 package metrics
 
 import (
+	"context"
 	"fmt"
 	"time"
-
-	"github.com/shirou/gopsutil/v3/cpu"
-	"github.com/shirou/gopsutil/v3/mem"
-	"github.com/shirou/gopsutil/v3/net"
 )
 
 type SystemMetrics struct {
@@ -17,62 +14,67 @@ type SystemMetrics struct {
 	NetSentMB     float64
 	NetRecvMB     float64
 	Timestamp     time.Time
+
+	// Values holds every metric from the collector's registry by name,
+	// including the ones mirrored in the fields above
+	Values Snapshot
 }
 
-type Collector struct {
-	lastNetStat   *net.IOCountersStat
-	lastCheckTime time.Time
+// Value returns the named metric, e.g. "cpu.total" or "disk./"
+func (m *SystemMetrics) Value(name string) (float64, bool) {
+	return m.Values.Get(name)
 }
 
-func NewCollector() *Collector {
-	return &Collector{
-		lastCheckTime: time.Now(),
-	}
+// Collector gathers system metrics from a Registry of sources. The
+// built-in cpu, mem, net and disk sources are registered by NewCollector;
+// additional sources can be added through Registry. CPU and network rates
+// are computed from the delta between consecutive calls, so Collect never
+// blocks waiting for a sample window. It is safe for concurrent use.
+type Collector struct {
+	registry *Registry
 }
 
-func (c *Collector) Collect() (*SystemMetrics, error) {
-	metrics := &SystemMetrics{
-		Timestamp: time.Now(),
-	}
+func NewCollector() *Collector {
+	registry := NewRegistry()
+	// Built-in source names are fixed and unique, so this cannot fail
+	_ = RegisterBuiltins(registry)
 
-	// Get CPU usage
-	cpuPercents, err := cpu.Percent(time.Second, false)
-	if err != nil {
-		return nil, fmt.Errorf("get cpu percent: %w", err)
-	}
-	if len(cpuPercents) > 0 {
-		metrics.CPUPercent = cpuPercents[0]
-	}
+	return NewCollectorWithRegistry(registry)
+}
 
-	// Get memory usage
-	vmStat, err := mem.VirtualMemory()
-	if err != nil {
-		return nil, fmt.Errorf("get memory stats: %w", err)
-	}
-	metrics.MemoryPercent = vmStat.UsedPercent
-	metrics.MemoryUsedGB = float64(vmStat.Used) / 1024 / 1024 / 1024
-	metrics.MemoryTotalGB = float64(vmStat.Total) / 1024 / 1024 / 1024
+// NewCollectorWithRegistry creates a collector reading from an existing
+// registry instead of the built-in sources
+func NewCollectorWithRegistry(registry *Registry) *Collector {
+	return &Collector{registry: registry}
+}
 
-	// Get network stats
-	netStats, err := net.IOCounters(false)
-	if err == nil && len(netStats) > 0 {
-		currentStat := &netStats[0]
+// Registry returns the registry the collector reads from
+func (c *Collector) Registry() *Registry {
+	return c.registry
+}
 
-		if c.lastNetStat != nil {
-			// Calculate rate since last check
-			timeDiff := time.Since(c.lastCheckTime).Seconds()
-			if timeDiff > 0 {
-				bytesSent := float64(currentStat.BytesSent - c.lastNetStat.BytesSent)
-				bytesRecv := float64(currentStat.BytesRecv - c.lastNetStat.BytesRecv)
+func (c *Collector) Collect() (*SystemMetrics, error) {
+	return c.CollectContext(context.Background())
+}
 
-				metrics.NetSentMB = (bytesSent / 1024 / 1024) / timeDiff
-				metrics.NetRecvMB = (bytesRecv / 1024 / 1024) / timeDiff
-			}
-		}
+// CollectContext reads every registered source. Errors from individual
+// sources are returned only if nothing could be collected at all.
+func (c *Collector) CollectContext(ctx context.Context) (*SystemMetrics, error) {
+	snap, err := c.registry.Collect(ctx)
+	if err != nil && len(snap) == 0 {
+		return nil, fmt.Errorf("collect metrics: %w", err)
+	}
 
-		c.lastNetStat = currentStat
-		c.lastCheckTime = time.Now()
+	metrics := &SystemMetrics{
+		Timestamp: time.Now(),
+		Values:    snap,
 	}
+	metrics.CPUPercent, _ = snap.Get(MetricCPUTotal)
+	metrics.MemoryPercent, _ = snap.Get(MetricMemPercent)
+	metrics.MemoryUsedGB, _ = snap.Get(MetricMemUsedGB)
+	metrics.MemoryTotalGB, _ = snap.Get(MetricMemTotalGB)
+	metrics.NetSentMB, _ = snap.Get(MetricNetSentMB)
+	metrics.NetRecvMB, _ = snap.Get(MetricNetRecvMB)
 
 	return metrics, nil
 }
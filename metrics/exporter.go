@@ -0,0 +1,151 @@
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Exporter serves the sampler's latest metrics in the Prometheus text
+// exposition format. Additional families (device stats, application
+// state) are contributed through AddCollector.
+type Exporter struct {
+	sampler *Sampler
+
+	mu         sync.RWMutex
+	collectors []func(*ExpositionWriter)
+}
+
+func NewExporter(sampler *Sampler) *Exporter {
+	return &Exporter{sampler: sampler}
+}
+
+// AddCollector registers fn to write extra metric families on each scrape
+func (e *Exporter) AddCollector(fn func(*ExpositionWriter)) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.collectors = append(e.collectors, fn)
+}
+
+func (e *Exporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var buf bytes.Buffer
+	ew := NewExpositionWriter(&buf)
+
+	m, err := e.sampler.Latest()
+	if m != nil {
+		for _, name := range m.Values.Names() {
+			v := m.Values[name]
+			ew.Gauge("divoom_system_metric", "System metric collected by the monitor, by source metric name",
+				v.Value, "name", name, "unit", v.Unit)
+		}
+		ew.Gauge("divoom_system_last_sample_timestamp_seconds", "Unix time of the latest metric sample",
+			float64(m.Timestamp.UnixNano())/1e9)
+	}
+	ew.Gauge("divoom_system_sample_ok", "Whether the latest metric collection succeeded", boolValue(err == nil))
+
+	e.mu.RLock()
+	collectors := e.collectors
+	e.mu.RUnlock()
+	for _, fn := range collectors {
+		fn(ew)
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.Write(buf.Bytes())
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// ExpositionWriter writes metric samples in the Prometheus text format.
+// HELP and TYPE lines are emitted the first time a family is written, so
+// all samples of a family must be written consecutively.
+type ExpositionWriter struct {
+	w    io.Writer
+	seen map[string]bool
+}
+
+func NewExpositionWriter(w io.Writer) *ExpositionWriter {
+	return &ExpositionWriter{w: w, seen: make(map[string]bool)}
+}
+
+// Gauge writes one gauge sample. labels are name/value pairs.
+func (e *ExpositionWriter) Gauge(name, help string, value float64, labels ...string) {
+	e.write(name, help, "gauge", value, labels)
+}
+
+// Counter writes one counter sample. labels are name/value pairs.
+func (e *ExpositionWriter) Counter(name, help string, value float64, labels ...string) {
+	e.write(name, help, "counter", value, labels)
+}
+
+// Histogram writes one histogram: a _bucket sample for each upper bound
+// with the cumulative count at or under it, then +Inf, _sum and _count.
+// labels are name/value pairs.
+func (e *ExpositionWriter) Histogram(name, help string, bounds []float64, buckets []uint64, sum float64, count uint64, labels ...string) {
+	e.header(name, help, "histogram")
+	labels = slices.Clip(labels)
+	for i, bound := range bounds {
+		e.sample(name+"_bucket", float64(buckets[i]), append(labels, "le", formatFloat(bound)))
+	}
+	e.sample(name+"_bucket", float64(count), append(labels, "le", "+Inf"))
+	e.sample(name+"_sum", sum, labels)
+	e.sample(name+"_count", float64(count), labels)
+}
+
+func (e *ExpositionWriter) write(name, help, kind string, value float64, labels []string) {
+	e.header(name, help, kind)
+	e.sample(name, value, labels)
+}
+
+func (e *ExpositionWriter) header(name, help, kind string) {
+	if !e.seen[name] {
+		e.seen[name] = true
+		fmt.Fprintf(e.w, "# HELP %s %s\n", name, escapeHelp(help))
+		fmt.Fprintf(e.w, "# TYPE %s %s\n", name, kind)
+	}
+}
+
+func (e *ExpositionWriter) sample(name string, value float64, labels []string) {
+	io.WriteString(e.w, name)
+	if len(labels) >= 2 {
+		pairs := make([]string, 0, len(labels)/2)
+		for i := 0; i+1 < len(labels); i += 2 {
+			pairs = append(pairs, fmt.Sprintf("%s=\"%s\"", labels[i], escapeLabel(labels[i+1])))
+		}
+		sort.Strings(pairs)
+		io.WriteString(e.w, "{"+strings.Join(pairs, ",")+"}")
+	}
+	io.WriteString(e.w, " "+formatFloat(value)+"\n")
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(s string) string  { return helpEscaper.Replace(s) }
+func escapeLabel(s string) string { return labelEscaper.Replace(s) }
@@ -0,0 +1,46 @@
+package metrics
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestExpositionHistogram(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewExpositionWriter(&buf)
+	bounds := []float64{0.1, 1}
+	w.Histogram("push_seconds", "Push latency", bounds, []uint64{2, 5}, 3.5, 6, "device", "desk")
+	w.Histogram("push_seconds", "Push latency", bounds, []uint64{0, 1}, 0.5, 1, "device", "wall")
+
+	text := buf.String()
+	if n := strings.Count(text, "# TYPE push_seconds histogram\n"); n != 1 {
+		t.Errorf("TYPE line written %d times, want once:\n%s", n, text)
+	}
+
+	series, err := ParsePrometheusText(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]float64)
+	for _, s := range series {
+		got[s.key()] = s.Value
+	}
+	want := map[string]float64{
+		`push_seconds_bucket,device="desk",le="0.1"`:  2,
+		`push_seconds_bucket,device="desk",le="1"`:    5,
+		`push_seconds_bucket,device="desk",le="+Inf"`: 6,
+		`push_seconds_sum,device="desk"`:              3.5,
+		`push_seconds_count,device="desk"`:            6,
+		`push_seconds_bucket,device="wall",le="+Inf"`: 1,
+		`push_seconds_count,device="wall"`:            1,
+	}
+	for key, v := range want {
+		if g, ok := got[key]; !ok || g != v {
+			t.Errorf("%s = %v (present %v), want %v", key, g, ok, v)
+		}
+	}
+	if len(series) != 10 {
+		t.Errorf("got %d samples, want 10:\n%s", len(series), text)
+	}
+}
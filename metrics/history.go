@@ -0,0 +1,378 @@
+package metrics
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Metric names produced by the built-in sources
+const (
+	MetricCPUTotal   = "cpu.total"
+	MetricMemPercent = "mem.used_percent"
+	MetricMemUsedGB  = "mem.used_gb"
+	MetricNetSentMB  = "net.sent_mb"
+	MetricNetRecvMB  = "net.recv_mb"
+)
+
+// DefaultHistorySize is the number of raw samples kept per metric when
+// NewHistory is given a non-positive size
+const DefaultHistorySize = 300
+
+// Sample is a single timestamped metric value
+type Sample struct {
+	Time  time.Time
+	Value float64
+}
+
+// Stats summarizes the samples in a window
+type Stats struct {
+	Count int
+	Min   float64
+	Max   float64
+	Avg   float64
+}
+
+// History is an in-memory time-series store. It keeps the last N raw
+// samples per metric plus downsampled tiers covering the last hour (one
+// bucket per minute) and the last day (one bucket per 15 minutes), so
+// long windows can be summarized without unbounded memory.
+// It is safe for concurrent use.
+type History struct {
+	mu     sync.RWMutex
+	size   int
+	series map[string]*series
+}
+
+func NewHistory(size int) *History {
+	if size <= 0 {
+		size = DefaultHistorySize
+	}
+	return &History{
+		size:   size,
+		series: make(map[string]*series),
+	}
+}
+
+// Record adds every named value in m to the history. Metrics built
+// without a Snapshot fall back to the SystemMetrics fields.
+func (h *History) Record(m *SystemMetrics) {
+	if len(m.Values) > 0 {
+		for name, v := range m.Values {
+			h.Add(name, m.Timestamp, v.Value)
+		}
+		return
+	}
+
+	h.Add(MetricCPUTotal, m.Timestamp, m.CPUPercent)
+	h.Add(MetricMemPercent, m.Timestamp, m.MemoryPercent)
+	h.Add(MetricMemUsedGB, m.Timestamp, m.MemoryUsedGB)
+	h.Add(MetricNetSentMB, m.Timestamp, m.NetSentMB)
+	h.Add(MetricNetRecvMB, m.Timestamp, m.NetRecvMB)
+}
+
+// Add appends a sample for the named metric. Samples are expected in
+// time order; out-of-order samples are kept in the raw tier but only
+// aggregated into the current downsampled bucket.
+func (h *History) Add(name string, t time.Time, value float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	s, ok := h.series[name]
+	if !ok {
+		s = newSeries(h.size)
+		h.series[name] = s
+	}
+	s.add(Sample{Time: t, Value: value})
+}
+
+// Names returns the recorded metric names in sorted order
+func (h *History) Names() []string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	names := make([]string, 0, len(h.series))
+	for name := range h.series {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Latest returns the most recent sample for the named metric
+func (h *History) Latest(name string) (Sample, bool) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	s, ok := h.series[name]
+	if !ok || s.raw.len() == 0 {
+		return Sample{}, false
+	}
+	return s.raw.at(s.raw.len() - 1), true
+}
+
+// Values returns the samples for the named metric within window of its
+// latest sample, oldest first. Windows longer than the raw tier are served
+// from the downsampled tiers, one averaged point per bucket.
+func (h *History) Values(name string, window time.Duration) []Sample {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	s, ok := h.series[name]
+	if !ok {
+		return nil
+	}
+	return s.values(window)
+}
+
+// Stats returns min/max/avg over the window ending at the latest sample
+func (h *History) Stats(name string, window time.Duration) (Stats, bool) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	s, ok := h.series[name]
+	if !ok {
+		return Stats{}, false
+	}
+	return s.stats(window)
+}
+
+// Percentile returns the p-th percentile (0-100) of the values in the
+// window using linear interpolation. For windows served from downsampled
+// tiers the percentile is computed over bucket averages.
+func (h *History) Percentile(name string, window time.Duration, p float64) (float64, bool) {
+	values := h.Values(name, window)
+	if len(values) == 0 {
+		return 0, false
+	}
+
+	sorted := make([]float64, len(values))
+	for i, v := range values {
+		sorted[i] = v.Value
+	}
+	sort.Float64s(sorted)
+
+	return percentile(sorted, p), true
+}
+
+func percentile(sorted []float64, p float64) float64 {
+	if p <= 0 {
+		return sorted[0]
+	}
+	if p >= 100 {
+		return sorted[len(sorted)-1]
+	}
+
+	rank := p / 100 * float64(len(sorted)-1)
+	lo := int(math.Floor(rank))
+	hi := int(math.Ceil(rank))
+	frac := rank - float64(lo)
+	return sorted[lo] + (sorted[hi]-sorted[lo])*frac
+}
+
+// series holds the raw ring and downsampled tiers for one metric
+type series struct {
+	raw   *ring[Sample]
+	tiers []*tier
+}
+
+func newSeries(size int) *series {
+	return &series{
+		raw: newRing[Sample](size),
+		tiers: []*tier{
+			newTier(time.Minute, 60),    // last hour
+			newTier(15*time.Minute, 96), // last 24 hours
+		},
+	}
+}
+
+func (s *series) add(sample Sample) {
+	s.raw.push(sample)
+	for _, t := range s.tiers {
+		t.add(sample)
+	}
+}
+
+func (s *series) end() time.Time {
+	if s.raw.len() == 0 {
+		return time.Time{}
+	}
+	return s.raw.at(s.raw.len() - 1).Time
+}
+
+// rawCovers reports whether the raw ring holds every sample in the window
+func (s *series) rawCovers(window time.Duration) bool {
+	if s.raw.len() < s.raw.cap() {
+		return true
+	}
+	return !s.raw.at(0).Time.After(s.end().Add(-window))
+}
+
+// tierFor returns the finest tier spanning the window, or the coarsest
+// tier if none does
+func (s *series) tierFor(window time.Duration) *tier {
+	for _, t := range s.tiers {
+		if t.span() >= window {
+			return t
+		}
+	}
+	return s.tiers[len(s.tiers)-1]
+}
+
+func (s *series) values(window time.Duration) []Sample {
+	if s.raw.len() == 0 {
+		return nil
+	}
+	cutoff := s.end().Add(-window)
+
+	var out []Sample
+	if s.rawCovers(window) {
+		for i := 0; i < s.raw.len(); i++ {
+			if sample := s.raw.at(i); !sample.Time.Before(cutoff) {
+				out = append(out, sample)
+			}
+		}
+		return out
+	}
+
+	s.tierFor(window).each(cutoff, func(b bucket) {
+		out = append(out, Sample{Time: b.start, Value: b.sum / float64(b.count)})
+	})
+	return out
+}
+
+func (s *series) stats(window time.Duration) (Stats, bool) {
+	if s.raw.len() == 0 {
+		return Stats{}, false
+	}
+	cutoff := s.end().Add(-window)
+
+	var agg bucket
+	if s.rawCovers(window) {
+		for i := 0; i < s.raw.len(); i++ {
+			if sample := s.raw.at(i); !sample.Time.Before(cutoff) {
+				agg.observe(sample.Value)
+			}
+		}
+	} else {
+		s.tierFor(window).each(cutoff, agg.merge)
+	}
+
+	if agg.count == 0 {
+		return Stats{}, false
+	}
+	return Stats{
+		Count: agg.count,
+		Min:   agg.min,
+		Max:   agg.max,
+		Avg:   agg.sum / float64(agg.count),
+	}, true
+}
+
+// bucket aggregates the samples falling in one downsampling interval
+type bucket struct {
+	start time.Time
+	min   float64
+	max   float64
+	sum   float64
+	count int
+}
+
+func (b *bucket) observe(v float64) {
+	if b.count == 0 || v < b.min {
+		b.min = v
+	}
+	if b.count == 0 || v > b.max {
+		b.max = v
+	}
+	b.sum += v
+	b.count++
+}
+
+func (b *bucket) merge(o bucket) {
+	if o.count == 0 {
+		return
+	}
+	if b.count == 0 || o.min < b.min {
+		b.min = o.min
+	}
+	if b.count == 0 || o.max > b.max {
+		b.max = o.max
+	}
+	b.sum += o.sum
+	b.count += o.count
+}
+
+// tier is a ring of fixed-resolution buckets plus the bucket being filled
+type tier struct {
+	resolution time.Duration
+	buckets    *ring[bucket]
+	current    bucket
+}
+
+func newTier(resolution time.Duration, n int) *tier {
+	return &tier{
+		resolution: resolution,
+		buckets:    newRing[bucket](n),
+	}
+}
+
+func (t *tier) span() time.Duration {
+	return t.resolution * time.Duration(t.buckets.cap())
+}
+
+func (t *tier) add(sample Sample) {
+	start := sample.Time.Truncate(t.resolution)
+	if t.current.count > 0 && start.After(t.current.start) {
+		t.buckets.push(t.current)
+		t.current = bucket{}
+	}
+	if t.current.count == 0 {
+		t.current.start = start
+	}
+	t.current.observe(sample.Value)
+}
+
+// each calls fn for every bucket that ends after cutoff, oldest first,
+// including the partially filled current bucket
+func (t *tier) each(cutoff time.Time, fn func(bucket)) {
+	for i := 0; i < t.buckets.len(); i++ {
+		if b := t.buckets.at(i); b.start.Add(t.resolution).After(cutoff) {
+			fn(b)
+		}
+	}
+	if t.current.count > 0 {
+		fn(t.current)
+	}
+}
+
+// ring is a fixed-capacity FIFO that overwrites its oldest entry when full
+type ring[T any] struct {
+	buf   []T
+	start int
+	n     int
+}
+
+func newRing[T any](capacity int) *ring[T] {
+	return &ring[T]{buf: make([]T, capacity)}
+}
+
+func (r *ring[T]) push(v T) {
+	if r.n < len(r.buf) {
+		r.buf[(r.start+r.n)%len(r.buf)] = v
+		r.n++
+		return
+	}
+	r.buf[r.start] = v
+	r.start = (r.start + 1) % len(r.buf)
+}
+
+// at returns the i-th oldest entry
+func (r *ring[T]) at(i int) T {
+	return r.buf[(r.start+i)%len(r.buf)]
+}
+
+func (r *ring[T]) len() int { return r.n }
+
+func (r *ring[T]) cap() int { return len(r.buf) }
@@ -0,0 +1,255 @@
+package metrics
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+var base = time.Date(2026, 5, 4, 12, 0, 0, 0, time.UTC)
+
+// addEvery adds values one second apart starting at base
+func addEvery(h *History, name string, values ...float64) {
+	for i, v := range values {
+		h.Add(name, base.Add(time.Duration(i)*time.Second), v)
+	}
+}
+
+func valuesOf(samples []Sample) []float64 {
+	out := make([]float64, len(samples))
+	for i, s := range samples {
+		out[i] = s.Value
+	}
+	return out
+}
+
+func equalValues(a, b []float64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if math.Abs(a[i]-b[i]) > 1e-9 {
+			return false
+		}
+	}
+	return true
+}
+
+func TestRing(t *testing.T) {
+	tests := []struct {
+		name   string
+		pushes int
+		want   []int
+	}{
+		{"empty", 0, []int{}},
+		{"fewer than capacity", 2, []int{1, 2}},
+		{"exactly capacity", 3, []int{1, 2, 3}},
+		{"one past capacity", 4, []int{2, 3, 4}},
+		{"wrapped twice", 7, []int{5, 6, 7}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newRing[int](3)
+			for i := 1; i <= tt.pushes; i++ {
+				r.push(i)
+			}
+			if r.len() != len(tt.want) || r.cap() != 3 {
+				t.Fatalf("len %d cap %d, want %d and 3", r.len(), r.cap(), len(tt.want))
+			}
+			for i, want := range tt.want {
+				if got := r.at(i); got != want {
+					t.Errorf("at(%d) = %d, want %d", i, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestHistoryRawValues(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []float64
+		window time.Duration
+		want   []float64
+	}{
+		{"fewer than size", []float64{1, 2, 3}, time.Hour, []float64{1, 2, 3}},
+		{"window cuts inclusively", []float64{1, 2, 3}, time.Second, []float64{2, 3}},
+		{"exactly size", []float64{1, 2, 3, 4, 5}, 4 * time.Second, []float64{1, 2, 3, 4, 5}},
+		{"wrapped", []float64{1, 2, 3, 4, 5, 6, 7}, 4 * time.Second, []float64{3, 4, 5, 6, 7}},
+		{"wrapped, short window", []float64{1, 2, 3, 4, 5, 6, 7}, 2 * time.Second, []float64{5, 6, 7}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewHistory(5)
+			addEvery(h, "cpu.total", tt.values...)
+			if got := valuesOf(h.Values("cpu.total", tt.window)); !equalValues(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	h := NewHistory(5)
+	addEvery(h, "cpu.total", 1, 2, 3, 4, 5, 6, 7)
+	if latest, ok := h.Latest("cpu.total"); !ok || latest.Value != 7 || !latest.Time.Equal(base.Add(6*time.Second)) {
+		t.Errorf("Latest = %+v, %v", latest, ok)
+	}
+	// The full ring no longer reaches back 10s, so the minute tier answers
+	// with one averaged point
+	if got := valuesOf(h.Values("cpu.total", 10*time.Second)); !equalValues(got, []float64{4}) {
+		t.Errorf("window past the ring = %v, want the minute average [4]", got)
+	}
+}
+
+func TestHistoryMinuteTier(t *testing.T) {
+	// A one-sample ring sends every longer window to the tiers
+	h := NewHistory(1)
+	for _, s := range []struct {
+		at    time.Duration
+		value float64
+	}{
+		{0, 10},
+		{30 * time.Second, 20},
+		{time.Minute - time.Millisecond, 30},
+		{time.Minute, 100}, // first sample of the next bucket
+		{2*time.Minute + 30*time.Second, 50},
+	} {
+		h.Add("cpu.total", base.Add(s.at), s.value)
+	}
+
+	got := h.Values("cpu.total", 30*time.Minute)
+	if want := []float64{20, 100, 50}; !equalValues(valuesOf(got), want) {
+		t.Fatalf("buckets = %v, want %v", valuesOf(got), want)
+	}
+	for i, start := range []time.Duration{0, time.Minute, 2 * time.Minute} {
+		if !got[i].Time.Equal(base.Add(start)) {
+			t.Errorf("bucket %d starts at %v, want %v", i, got[i].Time, base.Add(start))
+		}
+	}
+
+	// The window ends exactly where the first bucket does, leaving it out
+	if got := valuesOf(h.Values("cpu.total", 90*time.Second)); !equalValues(got, []float64{100, 50}) {
+		t.Errorf("90s window = %v, want [100 50]", got)
+	}
+
+	st, ok := h.Stats("cpu.total", 30*time.Minute)
+	want := Stats{Count: 5, Min: 10, Max: 100, Avg: 42}
+	if !ok || st != want {
+		t.Errorf("Stats = %+v, %v, want %+v", st, ok, want)
+	}
+}
+
+func TestHistoryMinuteTierWraps(t *testing.T) {
+	h := NewHistory(1)
+	for i := 0; i < 70; i++ {
+		h.Add("cpu.total", base.Add(time.Duration(i)*time.Minute), float64(i))
+	}
+
+	// 60 finished buckets (minutes 9-68) plus the current one
+	got := h.Values("cpu.total", time.Hour)
+	if len(got) != 61 {
+		t.Fatalf("got %d buckets, want 61", len(got))
+	}
+	if got[0].Value != 9 || !got[0].Time.Equal(base.Add(9*time.Minute)) {
+		t.Errorf("oldest bucket = %+v, want minute 9", got[0])
+	}
+	if last := got[len(got)-1]; last.Value != 69 {
+		t.Errorf("newest bucket = %+v, want minute 69", last)
+	}
+}
+
+func TestHistoryQuarterHourTier(t *testing.T) {
+	h := NewHistory(1)
+	for _, s := range []struct {
+		at    time.Duration
+		value float64
+	}{
+		{0, 10},
+		{15*time.Minute - time.Second, 20},
+		{15 * time.Minute, 40},
+		{44 * time.Minute, 60},
+		{45 * time.Minute, 80},
+	} {
+		h.Add("cpu.total", base.Add(s.at), s.value)
+	}
+
+	// Longer than the minute tier's hour
+	got := h.Values("cpu.total", 2*time.Hour)
+	if want := []float64{15, 40, 60, 80}; !equalValues(valuesOf(got), want) {
+		t.Errorf("buckets = %v, want %v", valuesOf(got), want)
+	}
+	st, _ := h.Stats("cpu.total", 2*time.Hour)
+	if want := (Stats{Count: 5, Min: 10, Max: 80, Avg: 42}); st != want {
+		t.Errorf("Stats = %+v, want %+v", st, want)
+	}
+}
+
+func TestHistoryStats(t *testing.T) {
+	h := NewHistory(10)
+	addEvery(h, "mem.used_percent", 40, 10, 70, 20)
+
+	tests := []struct {
+		window time.Duration
+		want   Stats
+	}{
+		{time.Minute, Stats{Count: 4, Min: 10, Max: 70, Avg: 35}},
+		{2 * time.Second, Stats{Count: 3, Min: 10, Max: 70, Avg: 100.0 / 3}},
+		{0, Stats{Count: 1, Min: 20, Max: 20, Avg: 20}},
+	}
+	for _, tt := range tests {
+		got, ok := h.Stats("mem.used_percent", tt.window)
+		if !ok || got.Count != tt.want.Count || got.Min != tt.want.Min || got.Max != tt.want.Max || math.Abs(got.Avg-tt.want.Avg) > 1e-9 {
+			t.Errorf("Stats(%v) = %+v, %v, want %+v", tt.window, got, ok, tt.want)
+		}
+	}
+	if _, ok := h.Stats("cpu.total", time.Minute); ok {
+		t.Error("Stats of an unknown metric reported ok")
+	}
+}
+
+func TestHistoryPercentile(t *testing.T) {
+	h := NewHistory(10)
+
+	if _, ok := h.Percentile("cpu.total", time.Minute, 50); ok {
+		t.Error("percentile of an empty window reported ok")
+	}
+
+	h.Add("cpu.total", base, 42)
+	for _, p := range []float64{0, 50, 99, 100} {
+		if got, ok := h.Percentile("cpu.total", time.Minute, p); !ok || got != 42 {
+			t.Errorf("p%g of one sample = %v, %v, want 42", p, got, ok)
+		}
+	}
+
+	h = NewHistory(10)
+	addEvery(h, "cpu.total", 50, 10, 40, 20, 30)
+	for p, want := range map[float64]float64{0: 10, 25: 20, 50: 30, 90: 46, 100: 50, -5: 10, 150: 50} {
+		if got, _ := h.Percentile("cpu.total", time.Minute, p); math.Abs(got-want) > 1e-9 {
+			t.Errorf("p%g = %v, want %v", p, got, want)
+		}
+	}
+}
+
+func TestHistoryRecord(t *testing.T) {
+	h := NewHistory(10)
+	h.Record(&SystemMetrics{
+		Timestamp: base,
+		Values:    Snapshot{"disk./": {Name: "disk./", Value: 61}},
+	})
+	// Without a snapshot the fixed fields are recorded
+	h.Record(&SystemMetrics{Timestamp: base, CPUPercent: 12, MemoryPercent: 34})
+
+	want := []string{"cpu.total", "disk./", "mem.used_gb", "mem.used_percent", "net.recv_mb", "net.sent_mb"}
+	if got := h.Names(); len(got) != len(want) {
+		t.Fatalf("names = %v, want %v", got, want)
+	} else {
+		for i := range want {
+			if got[i] != want[i] {
+				t.Errorf("names = %v, want %v", got, want)
+				break
+			}
+		}
+	}
+	if s, _ := h.Latest("mem.used_percent"); s.Value != 34 {
+		t.Errorf("mem.used_percent = %v, want 34", s.Value)
+	}
+}
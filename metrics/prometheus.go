@@ -0,0 +1,640 @@
+package metrics
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PrometheusSource scrapes a Prometheus text-format endpoint (or reads an
+// exposition file) and evaluates a small subset of PromQL against it:
+//
+//	node_load1
+//	node_load1{instance="x"}
+//	sum(node_filesystem_avail_bytes{fstype!~"tmpfs|overlay"})
+//	sum(rate(http_requests_total{code=~"5.."}[5m]))
+//
+// Supported functions are rate/irate (computed between the two most
+// recent scrapes; any range in brackets is accepted but ignored) and the
+// aggregations sum, avg, min, max and count. Each query produces one value
+// named "<source>.<query>"; queries that select more than one series must
+// be aggregated.
+type PrometheusSource struct {
+	name    string
+	target  string
+	queries []promQuery
+	client  *http.Client
+	now     func() time.Time
+
+	mu   sync.Mutex
+	last *promScrape
+}
+
+type promQuery struct {
+	name string
+	expr promExpr
+}
+
+// NewPrometheusSource creates a source named name that scrapes target,
+// which may be an http(s) URL or a file path. queries maps value names to
+// expressions and is parsed up front so configuration errors surface early.
+func NewPrometheusSource(name, target string, queries map[string]string) (*PrometheusSource, error) {
+	s := &PrometheusSource{
+		name:   name,
+		target: target,
+		client: &http.Client{Timeout: 10 * time.Second},
+		now:    time.Now,
+	}
+
+	names := make([]string, 0, len(queries))
+	for q := range queries {
+		names = append(names, q)
+	}
+	sort.Strings(names)
+
+	for _, q := range names {
+		expr, err := parsePromExpr(queries[q])
+		if err != nil {
+			return nil, fmt.Errorf("query %s: %w", q, err)
+		}
+		s.queries = append(s.queries, promQuery{name: q, expr: expr})
+	}
+	return s, nil
+}
+
+// SetHTTPClient replaces the client used for http(s) targets
+func (s *PrometheusSource) SetHTTPClient(client *http.Client) {
+	s.client = client
+}
+
+func (s *PrometheusSource) Name() string { return s.name }
+
+func (s *PrometheusSource) Schema() []Desc {
+	descs := make([]Desc, 0, len(s.queries))
+	for _, q := range s.queries {
+		descs = append(descs, Desc{
+			Name: s.name + "." + q.name,
+			Help: q.expr.String(),
+			Kind: Gauge,
+		})
+	}
+	return descs
+}
+
+// Collect scrapes the target and evaluates every query. Queries using
+// rate() produce no value until a second scrape is available. A query
+// that fails doesn't hold back the others: their values are returned
+// together with the failures.
+func (s *PrometheusSource) Collect(ctx context.Context) ([]Value, error) {
+	scrape, err := s.scrape(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	prev := s.last
+	s.last = scrape
+	s.mu.Unlock()
+
+	var values []Value
+	var errs []error
+	for _, q := range s.queries {
+		vec, err := q.expr.eval(scrape, prev)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("query %s: %w", q.name, err))
+			continue
+		}
+		switch len(vec) {
+		case 0:
+			// No matching series (yet)
+		case 1:
+			values = append(values, Value{Name: s.name + "." + q.name, Value: vec[0].value})
+		default:
+			errs = append(errs, fmt.Errorf("query %s: matched %d series, wrap it in an aggregation", q.name, len(vec)))
+		}
+	}
+
+	return values, errors.Join(errs...)
+}
+
+func (s *PrometheusSource) scrape(ctx context.Context) (*promScrape, error) {
+	var body io.ReadCloser
+
+	if strings.HasPrefix(s.target, "http://") || strings.HasPrefix(s.target, "https://") {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.target, nil)
+		if err != nil {
+			return nil, fmt.Errorf("create request: %w", err)
+		}
+		req.Header.Set("Accept", "text/plain; version=0.0.4")
+
+		resp, err := s.client.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("scrape %s: %w", s.target, err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, fmt.Errorf("scrape %s: unexpected status code: %d", s.target, resp.StatusCode)
+		}
+		body = resp.Body
+	} else {
+		f, err := os.Open(strings.TrimPrefix(s.target, "file://"))
+		if err != nil {
+			return nil, fmt.Errorf("open exposition file: %w", err)
+		}
+		body = f
+	}
+	defer body.Close()
+
+	series, err := ParsePrometheusText(body)
+	if err != nil {
+		return nil, err
+	}
+	return &promScrape{time: s.now(), series: series}, nil
+}
+
+// PromSeries is one sample line from a text exposition
+type PromSeries struct {
+	Name   string
+	Labels map[string]string
+	Value  float64
+}
+
+// key identifies a series by its name and sorted label set
+func (p PromSeries) key() string {
+	names := make([]string, 0, len(p.Labels))
+	for name := range p.Labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	b.WriteString(p.Name)
+	for _, name := range names {
+		fmt.Fprintf(&b, ",%s=%q", name, p.Labels[name])
+	}
+	return b.String()
+}
+
+// ParsePrometheusText parses the Prometheus text exposition format.
+// Comments, HELP and TYPE lines are skipped; timestamps are ignored.
+func ParsePrometheusText(r io.Reader) ([]PromSeries, error) {
+	var series []PromSeries
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		s, err := parsePromLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNum, err)
+		}
+		series = append(series, s)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read exposition: %w", err)
+	}
+	return series, nil
+}
+
+func parsePromLine(line string) (PromSeries, error) {
+	s := PromSeries{Labels: map[string]string{}}
+
+	end := strings.IndexAny(line, "{ \t")
+	if end <= 0 {
+		return s, fmt.Errorf("missing value in %q", line)
+	}
+	s.Name = line[:end]
+	rest := line[end:]
+
+	if strings.HasPrefix(rest, "{") {
+		labels, n, err := parsePromLabels(rest)
+		if err != nil {
+			return s, err
+		}
+		s.Labels = labels
+		rest = rest[n:]
+	}
+
+	fields := strings.Fields(rest)
+	if len(fields) == 0 {
+		return s, fmt.Errorf("missing value for %s", s.Name)
+	}
+	value, err := parsePromFloat(fields[0])
+	if err != nil {
+		return s, fmt.Errorf("value for %s: %w", s.Name, err)
+	}
+	s.Value = value
+	return s, nil
+}
+
+// parsePromLabels parses `{a="b",c="d"}` at the start of s and returns the
+// labels and the number of bytes consumed
+func parsePromLabels(s string) (map[string]string, int, error) {
+	labels := map[string]string{}
+	i := 1 // skip '{'
+
+	for {
+		for i < len(s) && (s[i] == ' ' || s[i] == ',') {
+			i++
+		}
+		if i >= len(s) {
+			return nil, 0, fmt.Errorf("unterminated label set")
+		}
+		if s[i] == '}' {
+			return labels, i + 1, nil
+		}
+
+		eq := strings.IndexByte(s[i:], '=')
+		if eq < 0 {
+			return nil, 0, fmt.Errorf("label without value")
+		}
+		name := strings.TrimSpace(s[i : i+eq])
+		i += eq + 1
+
+		value, n, err := parsePromQuoted(s[i:])
+		if err != nil {
+			return nil, 0, fmt.Errorf("label %s: %w", name, err)
+		}
+		labels[name] = value
+		i += n
+	}
+}
+
+// parsePromQuoted parses a double-quoted string with \\, \" and \n
+// escapes and returns it with the number of bytes consumed
+func parsePromQuoted(s string) (string, int, error) {
+	if len(s) == 0 || s[0] != '"' {
+		return "", 0, fmt.Errorf("expected quoted string")
+	}
+
+	var b strings.Builder
+	for i := 1; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '"':
+			return b.String(), i + 1, nil
+		case '\\':
+			i++
+			if i >= len(s) {
+				return "", 0, fmt.Errorf("unterminated escape")
+			}
+			switch s[i] {
+			case 'n':
+				b.WriteByte('\n')
+			default:
+				b.WriteByte(s[i])
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", 0, fmt.Errorf("unterminated string")
+}
+
+func parsePromFloat(s string) (float64, error) {
+	switch s {
+	case "+Inf", "Inf":
+		return math.Inf(1), nil
+	case "-Inf":
+		return math.Inf(-1), nil
+	case "NaN":
+		return math.NaN(), nil
+	}
+	return strconv.ParseFloat(s, 64)
+}
+
+type promScrape struct {
+	time   time.Time
+	series []PromSeries
+}
+
+// promSample is one element of an evaluated instant vector
+type promSample struct {
+	labels map[string]string
+	value  float64
+}
+
+type promExpr interface {
+	eval(cur, prev *promScrape) ([]promSample, error)
+	String() string
+}
+
+type promMatcher struct {
+	label string
+	op    string
+	value string
+	re    *regexp.Regexp
+}
+
+func (m promMatcher) matches(labels map[string]string) bool {
+	v := labels[m.label]
+	switch m.op {
+	case "=":
+		return v == m.value
+	case "!=":
+		return v != m.value
+	case "=~":
+		return m.re.MatchString(v)
+	default: // "!~"
+		return !m.re.MatchString(v)
+	}
+}
+
+type promSelector struct {
+	name     string
+	matchers []promMatcher
+}
+
+func (s *promSelector) selectSeries(scrape *promScrape) []PromSeries {
+	var out []PromSeries
+	for _, series := range scrape.series {
+		if series.Name != s.name {
+			continue
+		}
+		ok := true
+		for _, m := range s.matchers {
+			if !m.matches(series.Labels) {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			out = append(out, series)
+		}
+	}
+	return out
+}
+
+func (s *promSelector) eval(cur, _ *promScrape) ([]promSample, error) {
+	var vec []promSample
+	for _, series := range s.selectSeries(cur) {
+		vec = append(vec, promSample{labels: series.Labels, value: series.Value})
+	}
+	return vec, nil
+}
+
+func (s *promSelector) String() string {
+	if len(s.matchers) == 0 {
+		return s.name
+	}
+	parts := make([]string, len(s.matchers))
+	for i, m := range s.matchers {
+		parts[i] = fmt.Sprintf("%s%s%q", m.label, m.op, m.value)
+	}
+	return s.name + "{" + strings.Join(parts, ",") + "}"
+}
+
+// promRate computes the per-second increase of counters between the
+// previous and current scrape, treating a decrease as a counter reset
+type promRate struct {
+	fn       string
+	selector *promSelector
+}
+
+func (r *promRate) eval(cur, prev *promScrape) ([]promSample, error) {
+	if prev == nil {
+		return nil, nil
+	}
+	dt := cur.time.Sub(prev.time).Seconds()
+	if dt <= 0 {
+		return nil, nil
+	}
+
+	before := map[string]float64{}
+	for _, series := range r.selector.selectSeries(prev) {
+		before[series.key()] = series.Value
+	}
+
+	var vec []promSample
+	for _, series := range r.selector.selectSeries(cur) {
+		last, ok := before[series.key()]
+		if !ok {
+			continue
+		}
+		delta := series.Value - last
+		if delta < 0 {
+			delta = series.Value
+		}
+		vec = append(vec, promSample{labels: series.Labels, value: delta / dt})
+	}
+	return vec, nil
+}
+
+func (r *promRate) String() string {
+	return r.fn + "(" + r.selector.String() + ")"
+}
+
+type promAggregate struct {
+	op    string
+	inner promExpr
+}
+
+func (a *promAggregate) eval(cur, prev *promScrape) ([]promSample, error) {
+	vec, err := a.inner.eval(cur, prev)
+	if err != nil || len(vec) == 0 {
+		return nil, err
+	}
+
+	result := vec[0].value
+	switch a.op {
+	case "sum", "avg":
+		result = 0
+		for _, s := range vec {
+			result += s.value
+		}
+		if a.op == "avg" {
+			result /= float64(len(vec))
+		}
+	case "min":
+		for _, s := range vec[1:] {
+			result = math.Min(result, s.value)
+		}
+	case "max":
+		for _, s := range vec[1:] {
+			result = math.Max(result, s.value)
+		}
+	case "count":
+		result = float64(len(vec))
+	}
+	return []promSample{{labels: map[string]string{}, value: result}}, nil
+}
+
+func (a *promAggregate) String() string {
+	return a.op + "(" + a.inner.String() + ")"
+}
+
+var promAggregations = map[string]bool{"sum": true, "avg": true, "min": true, "max": true, "count": true}
+
+// parsePromExpr parses the supported PromQL subset
+func parsePromExpr(s string) (promExpr, error) {
+	p := &promParser{s: s}
+	expr, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	if p.pos != len(p.s) {
+		return nil, fmt.Errorf("unexpected %q at offset %d", p.s[p.pos:], p.pos)
+	}
+	return expr, nil
+}
+
+type promParser struct {
+	s   string
+	pos int
+}
+
+func (p *promParser) skipSpace() {
+	for p.pos < len(p.s) && (p.s[p.pos] == ' ' || p.s[p.pos] == '\t') {
+		p.pos++
+	}
+}
+
+func (p *promParser) peek() byte {
+	p.skipSpace()
+	if p.pos >= len(p.s) {
+		return 0
+	}
+	return p.s[p.pos]
+}
+
+func (p *promParser) expect(c byte) error {
+	if p.peek() != c {
+		return fmt.Errorf("expected %q at offset %d", c, p.pos)
+	}
+	p.pos++
+	return nil
+}
+
+func (p *promParser) ident() string {
+	p.skipSpace()
+	start := p.pos
+	for p.pos < len(p.s) {
+		c := p.s[p.pos]
+		if c == '_' || c == ':' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || p.pos > start && c >= '0' && c <= '9' {
+			p.pos++
+			continue
+		}
+		break
+	}
+	return p.s[start:p.pos]
+}
+
+func (p *promParser) parseExpr() (promExpr, error) {
+	name := p.ident()
+	if name == "" {
+		return nil, fmt.Errorf("expected metric name at offset %d", p.pos)
+	}
+
+	if p.peek() != '(' {
+		return p.parseSelector(name)
+	}
+	p.pos++
+
+	switch {
+	case promAggregations[name]:
+		inner, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(')'); err != nil {
+			return nil, err
+		}
+		return &promAggregate{op: name, inner: inner}, nil
+
+	case name == "rate" || name == "irate":
+		selector, err := p.parseSelector(p.ident())
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() == '[' {
+			// Range is ignored: rates are always taken across two scrapes
+			end := strings.IndexByte(p.s[p.pos:], ']')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated range at offset %d", p.pos)
+			}
+			p.pos += end + 1
+		}
+		if err := p.expect(')'); err != nil {
+			return nil, err
+		}
+		return &promRate{fn: name, selector: selector}, nil
+
+	default:
+		return nil, fmt.Errorf("unsupported function %s", name)
+	}
+}
+
+func (p *promParser) parseSelector(name string) (*promSelector, error) {
+	if name == "" {
+		return nil, fmt.Errorf("expected metric name at offset %d", p.pos)
+	}
+	sel := &promSelector{name: name}
+	if p.peek() != '{' {
+		return sel, nil
+	}
+	p.pos++
+
+	for {
+		if p.peek() == '}' {
+			p.pos++
+			return sel, nil
+		}
+
+		label := p.ident()
+		if label == "" {
+			return nil, fmt.Errorf("expected label name at offset %d", p.pos)
+		}
+
+		p.skipSpace()
+		var op string
+		for _, candidate := range []string{"=~", "!~", "!=", "="} {
+			if strings.HasPrefix(p.s[p.pos:], candidate) {
+				op = candidate
+				break
+			}
+		}
+		if op == "" {
+			return nil, fmt.Errorf("expected matcher operator at offset %d", p.pos)
+		}
+		p.pos += len(op)
+		p.skipSpace()
+
+		value, n, err := parsePromQuoted(p.s[p.pos:])
+		if err != nil {
+			return nil, fmt.Errorf("label %s: %w", label, err)
+		}
+		p.pos += n
+
+		m := promMatcher{label: label, op: op, value: value}
+		if op == "=~" || op == "!~" {
+			// PromQL regexes are fully anchored
+			re, err := regexp.Compile("^(?:" + value + ")$")
+			if err != nil {
+				return nil, fmt.Errorf("label %s: %w", label, err)
+			}
+			m.re = re
+		}
+		sel.matchers = append(sel.matchers, m)
+
+		if p.peek() == ',' {
+			p.pos++
+		}
+	}
+}
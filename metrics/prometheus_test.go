@@ -0,0 +1,245 @@
+package metrics
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// exposition serves fixture text that a test can replace between scrapes
+type exposition struct {
+	mu   sync.Mutex
+	text string
+}
+
+func (e *exposition) set(text string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.text = text
+}
+
+func (e *exposition) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	w.Write([]byte(e.text))
+}
+
+// newTestSource serves text from an httptest server and returns a source
+// scraping it, with a clock the test advances by hand
+func newTestSource(t *testing.T, text string, queries map[string]string) (*PrometheusSource, *exposition, *time.Time) {
+	t.Helper()
+
+	exp := &exposition{text: text}
+	srv := httptest.NewServer(exp)
+	t.Cleanup(srv.Close)
+
+	s, err := NewPrometheusSource("prom", srv.URL+"/metrics", queries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.SetHTTPClient(srv.Client())
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return now }
+	return s, exp, &now
+}
+
+func collect(t *testing.T, s *PrometheusSource) (map[string]float64, error) {
+	t.Helper()
+	values, err := s.Collect(context.Background())
+	got := make(map[string]float64, len(values))
+	for _, v := range values {
+		got[v.Name] = v.Value
+	}
+	return got, err
+}
+
+const nodeExporterFixture = `# HELP node_load1 1m load average.
+# TYPE node_load1 gauge
+node_load1 0.42
+# HELP node_filesystem_avail_bytes Filesystem space available to non-root users in bytes.
+# TYPE node_filesystem_avail_bytes gauge
+node_filesystem_avail_bytes{device="/dev/sda1",fstype="ext4",mountpoint="/"} 1000
+node_filesystem_avail_bytes{device="/dev/sdb1",fstype="xfs",mountpoint="/data"} 3000
+node_filesystem_avail_bytes{device="tmpfs",fstype="tmpfs",mountpoint="/run"} 50
+node_filesystem_avail_bytes{device="overlay",fstype="overlay",mountpoint="/var/lib/docker"} 70
+node_hwmon_temp_celsius{chip="platform_coretemp_0",sensor="temp1"} 48 1767268800000
+node_hwmon_temp_celsius{chip="platform_coretemp_0",sensor="temp2"} 52.5
+node_info{label="with \"quotes\", a comma and a \\ backslash"} 1
+`
+
+func TestPrometheusSelectors(t *testing.T) {
+	queries := map[string]string{
+		"load":          `node_load1`,
+		"root":          `node_filesystem_avail_bytes{mountpoint="/"}`,
+		"real_disks":    `sum(node_filesystem_avail_bytes{fstype!~"tmpfs|overlay"})`,
+		"not_tmpfs":     `count(node_filesystem_avail_bytes{fstype!="tmpfs"})`,
+		"sd_devices":    `avg(node_filesystem_avail_bytes{device=~"/dev/sd.+"})`,
+		"anchored":      `count(node_filesystem_avail_bytes{fstype=~"x"})`,
+		"two_matchers":  `node_filesystem_avail_bytes{fstype=~"ext4|xfs", mountpoint!="/"}`,
+		"temp_min":      `min(node_hwmon_temp_celsius)`,
+		"temp_max":      `max(node_hwmon_temp_celsius{chip="platform_coretemp_0"})`,
+		"escaped_label": `node_info{label="with \"quotes\", a comma and a \\ backslash"}`,
+		"missing":       `node_does_not_exist`,
+	}
+	s, _, _ := newTestSource(t, nodeExporterFixture, queries)
+
+	got, err := collect(t, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]float64{
+		"prom.load":          0.42,
+		"prom.root":          1000,
+		"prom.real_disks":    4000,
+		"prom.not_tmpfs":     3,
+		"prom.sd_devices":    2000,
+		"prom.two_matchers":  3000,
+		"prom.temp_min":      48,
+		"prom.temp_max":      52.5,
+		"prom.escaped_label": 1,
+	}
+	for name, v := range want {
+		if g, ok := got[name]; !ok || g != v {
+			t.Errorf("%s = %v (present %v), want %v", name, g, ok, v)
+		}
+	}
+	// A regex must match the whole label value, and a count of nothing
+	// is no value rather than zero
+	for _, name := range []string{"prom.anchored", "prom.missing"} {
+		if v, ok := got[name]; ok {
+			t.Errorf("%s = %v, want no value", name, v)
+		}
+	}
+	if len(s.Schema()) != len(queries) {
+		t.Errorf("schema has %d entries, want %d", len(s.Schema()), len(queries))
+	}
+}
+
+func TestPrometheusRate(t *testing.T) {
+	queries := map[string]string{
+		"errors":   `sum(rate(http_requests_total{code=~"5.."}[5m]))`,
+		"requests": `sum(irate(http_requests_total[1m]))`,
+		"ok":       `rate(http_requests_total{code="200"}[5m])`,
+	}
+	s, exp, now := newTestSource(t, `
+http_requests_total{code="200"} 1000
+http_requests_total{code="500"} 10
+http_requests_total{code="503"} 5
+`, queries)
+
+	// rate() needs two scrapes
+	got, err := collect(t, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 0 {
+		t.Errorf("first scrape = %v, want no values", got)
+	}
+
+	*now = now.Add(10 * time.Second)
+	exp.set(`
+http_requests_total{code="200"} 1500
+http_requests_total{code="500"} 30
+http_requests_total{code="503"} 15
+`)
+	got, err = collect(t, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]float64{"prom.errors": 3, "prom.requests": 53, "prom.ok": 50}
+	for name, v := range want {
+		if math.Abs(got[name]-v) > 1e-9 {
+			t.Errorf("%s = %v, want %v", name, got[name], v)
+		}
+	}
+
+	// The 200 counter restarts from zero: its increase is the new value,
+	// not a negative rate. A series that only appears now has no rate yet.
+	*now = now.Add(5 * time.Second)
+	exp.set(`
+http_requests_total{code="200"} 100
+http_requests_total{code="500"} 40
+http_requests_total{code="503"} 15
+http_requests_total{code="502"} 7
+`)
+	got, err = collect(t, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = map[string]float64{"prom.errors": 2, "prom.requests": 22, "prom.ok": 20}
+	for name, v := range want {
+		if math.Abs(got[name]-v) > 1e-9 {
+			t.Errorf("after reset %s = %v, want %v", name, got[name], v)
+		}
+	}
+}
+
+func TestPrometheusPartialFailure(t *testing.T) {
+	s, _, _ := newTestSource(t, nodeExporterFixture, map[string]string{
+		"load": `node_load1`,
+		"bad":  `node_filesystem_avail_bytes`,
+		"disk": `sum(node_filesystem_avail_bytes)`,
+	})
+
+	got, err := collect(t, s)
+	if err == nil || !strings.Contains(err.Error(), "query bad: matched 4 series") {
+		t.Errorf("err = %v, want the unaggregated query reported", err)
+	}
+	if got["prom.load"] != 0.42 || got["prom.disk"] != 4120 {
+		t.Errorf("values = %v, want load and disk despite the failing query", got)
+	}
+
+	// The registry keeps them too, and so does the collector
+	registry := NewRegistry()
+	if err := registry.Register(s); err != nil {
+		t.Fatal(err)
+	}
+	snap, err := registry.Collect(context.Background())
+	if err == nil {
+		t.Error("registry dropped the query error")
+	}
+	if v, ok := snap.Get("prom.load"); !ok || v != 0.42 {
+		t.Errorf("registry prom.load = %v (present %v), want 0.42", v, ok)
+	}
+	m, err := NewCollectorWithRegistry(registry).Collect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := m.Value("prom.disk"); !ok || v != 4120 {
+		t.Errorf("collector prom.disk = %v (present %v), want 4120", v, ok)
+	}
+}
+
+func TestPrometheusScrapeErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/broken":
+			w.Write([]byte("node_load1{instance=\"x 1\n"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	for _, path := range []string{"/missing", "/broken"} {
+		s, err := NewPrometheusSource("prom", srv.URL+path, map[string]string{"load": "node_load1"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if values, err := s.Collect(context.Background()); err == nil || len(values) != 0 {
+			t.Errorf("%s: values = %v, err = %v, want an error and no values", path, values, err)
+		}
+	}
+
+	for _, q := range []string{`rate(x)[`, `topk(3, x)`, `x{a~"b"}`, `x{a=~"("}`, `sum(x`} {
+		if _, err := NewPrometheusSource("prom", srv.URL, map[string]string{"q": q}); err == nil {
+			t.Errorf("query %q parsed, want an error", q)
+		}
+	}
+}
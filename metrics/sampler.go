@@ -0,0 +1,126 @@
+package metrics
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrNoSample is returned by Latest before the first sample completes
+var ErrNoSample = errors.New("no sample collected yet")
+
+// Sampler runs a Collector in a background goroutine at a fixed rate and
+// keeps the most recent result. Readers get the latest snapshot without
+// waiting, so any number of displays can share one Sampler.
+type Sampler struct {
+	collector *Collector
+	interval  time.Duration
+
+	mu        sync.RWMutex
+	latest    *SystemMetrics
+	err       error
+	listeners []func(*SystemMetrics)
+
+	stop      chan struct{}
+	done      chan struct{}
+	startOnce sync.Once
+	stopOnce  sync.Once
+}
+
+func NewSampler(collector *Collector, interval time.Duration) *Sampler {
+	if interval <= 0 {
+		interval = time.Second
+	}
+	return &Sampler{
+		collector: collector,
+		interval:  interval,
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+}
+
+// OnSample registers fn to be called from the sampler goroutine after each
+// successful sample. Callbacks must not block.
+func (s *Sampler) OnSample(fn func(*SystemMetrics)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.listeners = append(s.listeners, fn)
+}
+
+// Start samples in the background until Stop is called. It returns right
+// away; the first sample is taken one interval later, which gives rate
+// sources such as cpu a full window for their first reading instead of
+// the moment since they were created. Until then Latest returns
+// ErrNoSample.
+func (s *Sampler) Start() {
+	s.startOnce.Do(func() {
+		go s.run()
+	})
+}
+
+// Stop ends background sampling and waits for the goroutine to exit. A
+// Sampler that was never started can be stopped too, and won't start
+// afterwards.
+func (s *Sampler) Stop() {
+	s.stopOnce.Do(func() {
+		close(s.stop)
+	})
+	s.startOnce.Do(func() {
+		close(s.done)
+	})
+	<-s.done
+}
+
+// Latest returns a copy of the most recent good sample. When the most
+// recent collection failed its error is returned too, along with the
+// older sample if there is one, so callers can choose to show stale
+// metrics.
+func (s *Sampler) Latest() (*SystemMetrics, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.latest == nil {
+		if s.err != nil {
+			return nil, s.err
+		}
+		return nil, ErrNoSample
+	}
+	m := *s.latest
+	return &m, s.err
+}
+
+func (s *Sampler) run() {
+	defer close(s.done)
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.sample()
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+func (s *Sampler) sample() {
+	m, err := s.collector.Collect()
+
+	s.mu.Lock()
+	s.err = err
+	if err == nil {
+		s.latest = m
+	}
+	listeners := s.listeners
+	s.mu.Unlock()
+
+	if err != nil {
+		return
+	}
+	for _, fn := range listeners {
+		m := *m
+		fn(&m)
+	}
+}
@@ -0,0 +1,129 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// stampSource reports when each Collect ran, failing while fail is set
+type stampSource struct {
+	mu    sync.Mutex
+	calls []time.Time
+	fail  bool
+}
+
+func (s *stampSource) Name() string { return "stamp" }
+
+func (s *stampSource) Schema() []Desc {
+	return []Desc{{Name: "stamp.calls", Kind: Counter}}
+}
+
+func (s *stampSource) Collect(ctx context.Context) ([]Value, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls = append(s.calls, time.Now())
+	if s.fail {
+		return nil, errors.New("counters unavailable")
+	}
+	return []Value{{Name: "stamp.calls", Value: float64(len(s.calls))}}, nil
+}
+
+func newStampSampler(t *testing.T, interval time.Duration) (*Sampler, *stampSource) {
+	t.Helper()
+	src := &stampSource{}
+	r := NewRegistry()
+	if err := r.Register(src); err != nil {
+		t.Fatal(err)
+	}
+	return NewSampler(NewCollectorWithRegistry(r), interval), src
+}
+
+func TestSamplerStopWithoutStart(t *testing.T) {
+	s, src := newStampSampler(t, 10*time.Millisecond)
+
+	stopped := make(chan struct{})
+	go func() {
+		s.Stop()
+		s.Stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("Stop blocked on a sampler that was never started")
+	}
+
+	s.Start()
+	if n := len(src.calls); n != 0 {
+		t.Errorf("stopped sampler collected %d time(s)", n)
+	}
+}
+
+func TestSamplerFirstSampleWaitsOneInterval(t *testing.T) {
+	const interval = 50 * time.Millisecond
+	s, src := newStampSampler(t, interval)
+	sampled := make(chan struct{}, 1)
+	s.OnSample(func(*SystemMetrics) {
+		select {
+		case sampled <- struct{}{}:
+		default:
+		}
+	})
+
+	started := time.Now()
+	s.Start()
+	defer s.Stop()
+
+	if took := time.Since(started); took >= interval {
+		t.Errorf("Start blocked for %v", took)
+	}
+	if _, err := s.Latest(); err != ErrNoSample {
+		t.Errorf("Latest right after Start = %v, want ErrNoSample", err)
+	}
+
+	select {
+	case <-sampled:
+	case <-time.After(time.Second):
+		t.Fatal("no sample after Start")
+	}
+	if _, err := s.Latest(); err != nil {
+		t.Fatalf("Latest after the first sample: %v", err)
+	}
+	src.mu.Lock()
+	first := src.calls[0]
+	src.mu.Unlock()
+	if wait := first.Sub(started); wait < interval {
+		t.Errorf("first sample after %v, want at least %v", wait, interval)
+	}
+}
+
+func TestSamplerKeepsLastGoodSample(t *testing.T) {
+	s, src := newStampSampler(t, time.Hour)
+
+	src.fail = true
+	s.sample()
+	if m, err := s.Latest(); m != nil || err == nil || errors.Is(err, ErrNoSample) {
+		t.Fatalf("Latest after only a failure = %v, %v, want the collection error", m, err)
+	}
+
+	src.fail = false
+	s.sample()
+	src.fail = true
+	s.sample()
+	m, err := s.Latest()
+	if err == nil {
+		t.Error("failed collection not reported")
+	}
+	if m == nil || m.Values["stamp.calls"].Value != 2 {
+		t.Fatalf("Latest after a failure = %+v, want the sample before it", m)
+	}
+
+	src.fail = false
+	s.sample()
+	if m, err := s.Latest(); err != nil || m.Values["stamp.calls"].Value != 4 {
+		t.Errorf("Latest after recovering = %+v, %v, want the new sample", m, err)
+	}
+}
@@ -0,0 +1,150 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Kind describes how a metric value behaves over time
+type Kind int
+
+const (
+	// Gauge values can go up and down (e.g. CPU percent)
+	Gauge Kind = iota
+	// Counter values only increase (e.g. total bytes sent)
+	Counter
+)
+
+func (k Kind) String() string {
+	switch k {
+	case Counter:
+		return "counter"
+	default:
+		return "gauge"
+	}
+}
+
+// Desc describes one metric a Source produces
+type Desc struct {
+	Name string
+	Help string
+	Unit string
+	Kind Kind
+}
+
+// Value is a single named reading produced by a Source
+type Value struct {
+	Name  string
+	Value float64
+	Unit  string
+}
+
+// Source produces a set of named metric values. Names are dotted and
+// prefixed with the source name, e.g. "cpu.total" or "disk./".
+type Source interface {
+	// Name identifies the source in a Registry
+	Name() string
+	// Schema lists the metrics Collect may return
+	Schema() []Desc
+	// Collect reads the current values. A partial failure may return the
+	// values that could be read along with the error.
+	Collect(ctx context.Context) ([]Value, error)
+}
+
+// Snapshot maps metric names to their values at one point in time.
+// Snapshots are shared between readers and must not be modified.
+type Snapshot map[string]Value
+
+// Get returns the value of the named metric
+func (s Snapshot) Get(name string) (float64, bool) {
+	v, ok := s[name]
+	return v.Value, ok
+}
+
+// Names returns the metric names in sorted order
+func (s Snapshot) Names() []string {
+	names := make([]string, 0, len(s))
+	for name := range s {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Registry holds the set of sources that make up a collection pass.
+// It is safe for concurrent use.
+type Registry struct {
+	mu      sync.RWMutex
+	sources []Source
+}
+
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// Register adds a source. Source names must be unique.
+func (r *Registry) Register(s Source) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, existing := range r.sources {
+		if existing.Name() == s.Name() {
+			return fmt.Errorf("source %q already registered", s.Name())
+		}
+	}
+	r.sources = append(r.sources, s)
+	return nil
+}
+
+// Unregister removes the named source and reports whether it was present
+func (r *Registry) Unregister(name string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, s := range r.sources {
+		if s.Name() == name {
+			r.sources = append(r.sources[:i], r.sources[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Sources returns the registered sources in registration order
+func (r *Registry) Sources() []Source {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return append([]Source(nil), r.sources...)
+}
+
+// Schema returns the descriptions of every metric in the registry
+func (r *Registry) Schema() []Desc {
+	var descs []Desc
+	for _, s := range r.Sources() {
+		descs = append(descs, s.Schema()...)
+	}
+	return descs
+}
+
+// Collect reads every source. A failing source does not prevent the
+// others from being collected; its error is included in the returned
+// error alongside the values that were gathered.
+func (r *Registry) Collect(ctx context.Context) (Snapshot, error) {
+	snap := make(Snapshot)
+	var errs []error
+
+	for _, s := range r.Sources() {
+		values, err := s.Collect(ctx)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("source %s: %w", s.Name(), err))
+		}
+		for _, v := range values {
+			snap[v.Name] = v
+		}
+	}
+
+	return snap, errors.Join(errs...)
+}
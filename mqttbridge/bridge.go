@@ -0,0 +1,296 @@
+// Package mqttbridge connects a running monitor to an MQTT broker. It
+// accepts commands (brightness, page, message, image, alert ack) and
+// metric values on topics under a prefix, publishes the monitor state,
+// and announces itself using Home Assistant MQTT discovery.
+package mqttbridge
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+
+	"divoom-monitor/control"
+)
+
+// Config describes the broker connection and topic layout
+type Config struct {
+	// Broker is the broker URL, e.g. tcp://localhost:1883
+	Broker   string
+	ClientID string
+	Username string
+	Password string
+
+	// Prefix is the root of the command and state topics (default "divoom")
+	Prefix string
+	// DiscoveryPrefix is the Home Assistant discovery root (default
+	// "homeassistant"); set Discovery to false to skip announcements
+	DiscoveryPrefix string
+	Discovery       bool
+	// NodeID identifies this panel in discovery topics (default "pixoo64")
+	NodeID string
+
+	// StateInterval is how often state is republished (default 30s)
+	StateInterval time.Duration
+
+	// Configure, if set, can adjust the client options before connecting,
+	// e.g. to dial an in-process broker
+	Configure func(*mqtt.ClientOptions)
+}
+
+func (c *Config) setDefaults() {
+	if c.ClientID == "" {
+		c.ClientID = "divoom-monitor"
+	}
+	if c.Prefix == "" {
+		c.Prefix = "divoom"
+	}
+	if c.DiscoveryPrefix == "" {
+		c.DiscoveryPrefix = "homeassistant"
+	}
+	if c.NodeID == "" {
+		c.NodeID = "pixoo64"
+	}
+	if c.StateInterval <= 0 {
+		c.StateInterval = 30 * time.Second
+	}
+}
+
+// Bridge relays between the broker and a control.Display
+type Bridge struct {
+	cfg     Config
+	display control.Display
+	source  *Source
+	client  mqtt.Client
+
+	// ready is closed once the first connection has subscribed
+	ready     chan struct{}
+	readyOnce sync.Once
+
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// New creates a bridge. Metric values received on <prefix>/metric/<name>
+// are stored in source, which should be registered with the collector.
+func New(cfg Config, display control.Display, source *Source) *Bridge {
+	cfg.setDefaults()
+	return &Bridge{
+		cfg:     cfg,
+		display: display,
+		source:  source,
+		ready:   make(chan struct{}),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+}
+
+func (b *Bridge) topic(parts ...string) string {
+	return b.cfg.Prefix + "/" + strings.Join(parts, "/")
+}
+
+// Start connects to the broker and returns once the command topics are
+// subscribed. Subscriptions and discovery are (re)sent on every connect,
+// so they survive broker restarts.
+func (b *Bridge) Start() error {
+	opts := mqtt.NewClientOptions().
+		AddBroker(b.cfg.Broker).
+		SetClientID(b.cfg.ClientID).
+		SetUsername(b.cfg.Username).
+		SetPassword(b.cfg.Password).
+		SetAutoReconnect(true).
+		SetWill(b.topic("availability"), "offline", 1, true).
+		SetOnConnectHandler(b.onConnect).
+		SetConnectionLostHandler(func(_ mqtt.Client, err error) {
+			log.Printf("MQTT connection lost: %v", err)
+		})
+	if b.cfg.Configure != nil {
+		b.cfg.Configure(opts)
+	}
+
+	b.client = mqtt.NewClient(opts)
+	token := b.client.Connect()
+	if !token.WaitTimeout(10 * time.Second) {
+		return fmt.Errorf("connect to %s: timed out", b.cfg.Broker)
+	}
+	if err := token.Error(); err != nil {
+		return fmt.Errorf("connect to %s: %w", b.cfg.Broker, err)
+	}
+
+	select {
+	case <-b.ready:
+	case <-time.After(10 * time.Second):
+		b.client.Disconnect(0)
+		return fmt.Errorf("subscribe on %s: timed out", b.cfg.Broker)
+	}
+
+	go b.run()
+	return nil
+}
+
+// Stop publishes offline availability and disconnects
+func (b *Bridge) Stop() {
+	b.stopOnce.Do(func() {
+		close(b.stop)
+		<-b.done
+		b.publish(b.topic("availability"), true, "offline")
+		b.client.Disconnect(250)
+	})
+}
+
+func (b *Bridge) run() {
+	defer close(b.done)
+
+	ticker := time.NewTicker(b.cfg.StateInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			b.PublishState()
+		case <-b.stop:
+			return
+		}
+	}
+}
+
+func (b *Bridge) onConnect(client mqtt.Client) {
+	log.Printf("MQTT connected to %s", b.cfg.Broker)
+
+	subscriptions := map[string]mqtt.MessageHandler{
+		b.topic("brightness", "set"): b.handleBrightness,
+		b.topic("page", "set"):       b.handlePage,
+		b.topic("message", "set"):    b.handleMessage,
+		b.topic("image", "set"):      b.handleImage,
+		b.topic("ack", "set"):        b.handleAck,
+		b.topic("metric", "+"):       b.handleMetric,
+	}
+	for topic, handler := range subscriptions {
+		if token := client.Subscribe(topic, 1, handler); token.WaitTimeout(5*time.Second) && token.Error() != nil {
+			log.Printf("MQTT subscribe %s: %v", topic, token.Error())
+		}
+	}
+	b.readyOnce.Do(func() { close(b.ready) })
+
+	b.publish(b.topic("availability"), true, "online")
+	if b.cfg.Discovery {
+		b.publishDiscovery()
+	}
+	b.PublishState()
+}
+
+// PublishState publishes the full state as JSON plus the brightness and
+// page as plain retained values
+func (b *Bridge) PublishState() {
+	state := b.display.State()
+
+	data, err := json.Marshal(state)
+	if err != nil {
+		log.Printf("MQTT marshal state: %v", err)
+		return
+	}
+	b.publish(b.topic("state"), true, data)
+	b.publish(b.topic("brightness", "state"), true, strconv.Itoa(state.Brightness))
+	b.publish(b.topic("page", "state"), true, state.Page)
+}
+
+func (b *Bridge) publish(topic string, retained bool, payload interface{}) {
+	if b.client == nil || !b.client.IsConnected() {
+		return
+	}
+	token := b.client.Publish(topic, 1, retained, payload)
+	if token.WaitTimeout(5*time.Second) && token.Error() != nil {
+		log.Printf("MQTT publish %s: %v", topic, token.Error())
+	}
+}
+
+// Handlers run on paho's router goroutine; state is published from a new
+// goroutine because publishing and waiting inside a handler can deadlock.
+func (b *Bridge) stateChanged() {
+	go b.PublishState()
+}
+
+func (b *Bridge) handleBrightness(_ mqtt.Client, m mqtt.Message) {
+	brightness, err := strconv.Atoi(strings.TrimSpace(string(m.Payload())))
+	if err != nil || brightness < 0 || brightness > 100 {
+		log.Printf("MQTT %s: brightness must be between 0 and 100", m.Topic())
+		return
+	}
+	if err := b.display.SetBrightness(brightness); err != nil {
+		log.Printf("MQTT %s: %v", m.Topic(), err)
+		return
+	}
+	b.stateChanged()
+}
+
+func (b *Bridge) handlePage(_ mqtt.Client, m mqtt.Message) {
+	if err := b.display.SetPage(strings.TrimSpace(string(m.Payload()))); err != nil {
+		log.Printf("MQTT %s: %v", m.Topic(), err)
+		return
+	}
+	b.stateChanged()
+}
+
+// handleMessage accepts either plain text or a JSON object with the same
+// fields as the control API's POST /message
+func (b *Bridge) handleMessage(_ mqtt.Client, m mqtt.Message) {
+	payload := bytes.TrimSpace(m.Payload())
+
+	req := control.MessageRequest{Text: string(payload)}
+	if len(payload) > 0 && payload[0] == '{' {
+		req = control.MessageRequest{}
+		if err := json.Unmarshal(payload, &req); err != nil {
+			log.Printf("MQTT %s: decode message: %v", m.Topic(), err)
+			return
+		}
+	}
+
+	msg, err := req.Message()
+	if err != nil {
+		log.Printf("MQTT %s: %v", m.Topic(), err)
+		return
+	}
+	if err := b.display.ShowMessage(msg); err != nil {
+		log.Printf("MQTT %s: %v", m.Topic(), err)
+		return
+	}
+	b.stateChanged()
+}
+
+// handleImage accepts a raw 64x64 PNG, JPEG or GIF shown for 30 seconds
+func (b *Bridge) handleImage(_ mqtt.Client, m mqtt.Message) {
+	img, err := control.DecodeImage(bytes.NewReader(m.Payload()))
+	if err != nil {
+		log.Printf("MQTT %s: %v", m.Topic(), err)
+		return
+	}
+	if err := b.display.ShowImage(img, 30*time.Second); err != nil {
+		log.Printf("MQTT %s: %v", m.Topic(), err)
+		return
+	}
+	b.stateChanged()
+}
+
+func (b *Bridge) handleAck(_ mqtt.Client, m mqtt.Message) {
+	log.Printf("MQTT acknowledged %d alert(s)", b.display.Acknowledge())
+	b.stateChanged()
+}
+
+func (b *Bridge) handleMetric(_ mqtt.Client, m mqtt.Message) {
+	name := m.Topic()[strings.LastIndex(m.Topic(), "/")+1:]
+	value, err := strconv.ParseFloat(strings.TrimSpace(string(m.Payload())), 64)
+	if err != nil {
+		log.Printf("MQTT %s: invalid metric value %q", m.Topic(), m.Payload())
+		return
+	}
+	if b.source != nil {
+		b.source.Set(name, value)
+	}
+}
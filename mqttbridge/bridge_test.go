@@ -0,0 +1,325 @@
+package mqttbridge
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"image"
+	"io"
+	"log/slog"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	mochi "github.com/mochi-mqtt/server/v2"
+	"github.com/mochi-mqtt/server/v2/hooks/auth"
+	"github.com/mochi-mqtt/server/v2/listeners"
+	"github.com/mochi-mqtt/server/v2/packets"
+
+	"divoom-monitor/control"
+	"divoom-monitor/notify"
+)
+
+// fakeDisplay records what the bridge asks of the monitor
+type fakeDisplay struct {
+	mu         sync.Mutex
+	page       string
+	brightness int
+	messages   []notify.Message
+	acks       int
+}
+
+func (d *fakeDisplay) SetPage(name string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if name != "dashboard" && name != "text" {
+		return fmt.Errorf("unknown page %q", name)
+	}
+	d.page = name
+	return nil
+}
+
+func (d *fakeDisplay) ShowMessage(msg notify.Message) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.messages = append(d.messages, msg)
+	return nil
+}
+
+func (d *fakeDisplay) ClearMessage(key string) bool { return false }
+
+func (d *fakeDisplay) ShowImage(img image.Image, _ time.Duration) error { return nil }
+
+func (d *fakeDisplay) SetBrightness(brightness int) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.brightness = brightness
+	return nil
+}
+
+func (d *fakeDisplay) Acknowledge() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.acks++
+	return 1
+}
+
+func (d *fakeDisplay) State() control.State {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return control.State{
+		Page:       d.page,
+		Pages:      []string{"dashboard", "text"},
+		Brightness: d.brightness,
+		Metrics:    map[string]float64{"cpu.total": 12.5},
+	}
+}
+
+func (d *fakeDisplay) Frame() image.Image { return nil }
+
+// broker is an in-process MQTT broker that remembers the last payload
+// published on every topic
+type broker struct {
+	server *mochi.Server
+	url    string
+
+	mu       sync.Mutex
+	received map[string][]byte
+}
+
+func startBroker(t *testing.T) *broker {
+	t.Helper()
+
+	server := mochi.New(&mochi.Options{
+		InlineClient: true,
+		Logger:       slog.New(slog.NewTextHandler(io.Discard, nil)),
+	})
+	if err := server.AddHook(new(auth.AllowHook), nil); err != nil {
+		t.Fatal(err)
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := server.AddListener(listeners.NewNet("test", ln)); err != nil {
+		t.Fatal(err)
+	}
+	if err := server.Serve(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { server.Close() })
+
+	b := &broker{
+		server:   server,
+		url:      "tcp://" + ln.Addr().String(),
+		received: make(map[string][]byte),
+	}
+	err = server.Subscribe("#", 1, func(_ *mochi.Client, _ packets.Subscription, pk packets.Packet) {
+		b.mu.Lock()
+		b.received[pk.TopicName] = append([]byte(nil), pk.Payload...)
+		b.mu.Unlock()
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// waitFor polls until cond holds, failing the test after a few seconds
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// payload returns the last payload published on topic
+func (b *broker) payload(topic string) (string, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	p, ok := b.received[topic]
+	return string(p), ok
+}
+
+func (b *broker) publish(t *testing.T, topic, payload string) {
+	t.Helper()
+	if err := b.server.Publish(topic, []byte(payload), false, 1); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func startBridge(t *testing.T, display control.Display, source *Source) *broker {
+	t.Helper()
+	b := startBroker(t)
+	bridge := New(Config{Broker: b.url, ClientID: "test", Discovery: true}, display, source)
+	if err := bridge.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(bridge.Stop)
+	return b
+}
+
+func TestBridgeCommands(t *testing.T) {
+	display := &fakeDisplay{page: "dashboard", brightness: 50}
+	b := startBridge(t, display, nil)
+
+	b.publish(t, "divoom/brightness/set", "80")
+	b.publish(t, "divoom/page/set", "text")
+	b.publish(t, "divoom/message/set", "Build passed")
+	b.publish(t, "divoom/message/set", `{"text": "Deploy", "key": "deploy", "priority": "high"}`)
+	b.publish(t, "divoom/ack/set", "")
+
+	waitFor(t, "commands", func() bool {
+		display.mu.Lock()
+		defer display.mu.Unlock()
+		return display.brightness == 80 && display.page == "text" && len(display.messages) == 2 && display.acks == 1
+	})
+
+	display.mu.Lock()
+	first, second := display.messages[0], display.messages[1]
+	display.mu.Unlock()
+	if first.Text != "Build passed" {
+		t.Errorf("plain message text = %q, want %q", first.Text, "Build passed")
+	}
+	if second.Text != "Deploy" || second.Key != "deploy" || second.Priority != notify.High {
+		t.Errorf("JSON message = %+v, want text Deploy, key deploy, high priority", second)
+	}
+
+	// Commands republish the state
+	waitFor(t, "state", func() bool {
+		page, _ := b.payload("divoom/page/state")
+		brightness, _ := b.payload("divoom/brightness/state")
+		return page == "text" && brightness == "80"
+	})
+}
+
+func TestBridgeRejectsInvalidCommands(t *testing.T) {
+	display := &fakeDisplay{page: "dashboard", brightness: 50}
+	b := startBridge(t, display, nil)
+
+	b.publish(t, "divoom/brightness/set", "150")
+	b.publish(t, "divoom/brightness/set", "bright")
+	b.publish(t, "divoom/page/set", "nope")
+	b.publish(t, "divoom/message/set", `{"text": ""}`)
+	// A valid command after the invalid ones shows they were all handled
+	b.publish(t, "divoom/brightness/set", "60")
+
+	waitFor(t, "brightness", func() bool {
+		display.mu.Lock()
+		defer display.mu.Unlock()
+		return display.brightness == 60
+	})
+
+	display.mu.Lock()
+	defer display.mu.Unlock()
+	if display.page != "dashboard" {
+		t.Errorf("page = %q, want dashboard", display.page)
+	}
+	if len(display.messages) != 0 {
+		t.Errorf("got %d messages, want none", len(display.messages))
+	}
+}
+
+func TestBridgeMetricIngestion(t *testing.T) {
+	source := NewSource("mqtt", 0)
+	b := startBridge(t, &fakeDisplay{page: "dashboard"}, source)
+
+	b.publish(t, "divoom/metric/room_temp", "21.5")
+	b.publish(t, "divoom/metric/humidity", " 40 ")
+	b.publish(t, "divoom/metric/bad", "warm")
+
+	var got map[string]float64
+	waitFor(t, "metrics", func() bool {
+		values, err := source.Collect(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = make(map[string]float64)
+		for _, v := range values {
+			got[v.Name] = v.Value
+		}
+		return len(got) == 2
+	})
+	if got["mqtt.room_temp"] != 21.5 || got["mqtt.humidity"] != 40 {
+		t.Errorf("metrics = %v, want mqtt.room_temp=21.5 and mqtt.humidity=40", got)
+	}
+
+	// A later value replaces the earlier one, and an invalid value is
+	// never stored
+	b.publish(t, "divoom/metric/room_temp", "22")
+	waitFor(t, "updated metric", func() bool {
+		values, _ := source.Collect(context.Background())
+		for _, v := range values {
+			if v.Name == "mqtt.room_temp" {
+				return v.Value == 22
+			}
+		}
+		return false
+	})
+	for _, d := range source.Schema() {
+		if d.Name == "mqtt.bad" {
+			t.Errorf("invalid value was stored as %s", d.Name)
+		}
+	}
+}
+
+func TestBridgePublishesDiscoveryAndState(t *testing.T) {
+	b := startBridge(t, &fakeDisplay{page: "dashboard", brightness: 70}, nil)
+
+	topics := []string{
+		"homeassistant/number/pixoo64/brightness/config",
+		"homeassistant/select/pixoo64/page/config",
+		"homeassistant/text/pixoo64/message/config",
+		"homeassistant/button/pixoo64/ack/config",
+		"homeassistant/sensor/pixoo64/cpu_total/config",
+		"homeassistant/sensor/pixoo64/disk_root/config",
+		"divoom/state",
+	}
+	waitFor(t, "discovery", func() bool {
+		for _, topic := range topics {
+			if _, ok := b.payload(topic); !ok {
+				return false
+			}
+		}
+		return true
+	})
+
+	if availability, _ := b.payload("divoom/availability"); availability != "online" {
+		t.Errorf("availability = %q, want online", availability)
+	}
+
+	data, _ := b.payload("homeassistant/select/pixoo64/page/config")
+	var page map[string]interface{}
+	if err := json.Unmarshal([]byte(data), &page); err != nil {
+		t.Fatalf("page config: %v", err)
+	}
+	if page["command_topic"] != "divoom/page/set" || page["state_topic"] != "divoom/page/state" {
+		t.Errorf("page config topics = %v / %v", page["command_topic"], page["state_topic"])
+	}
+	if options, _ := page["options"].([]interface{}); len(options) != 2 {
+		t.Errorf("page options = %v, want dashboard and text", page["options"])
+	}
+
+	data, _ = b.payload("homeassistant/sensor/pixoo64/cpu_total/config")
+	if !strings.Contains(data, `value_json.metrics['cpu.total']`) {
+		t.Errorf("cpu sensor config %s does not read cpu.total from the state", data)
+	}
+
+	data, _ = b.payload("divoom/state")
+	var state control.State
+	if err := json.Unmarshal([]byte(data), &state); err != nil {
+		t.Fatalf("state: %v", err)
+	}
+	if state.Page != "dashboard" || state.Brightness != 70 || state.Metrics["cpu.total"] != 12.5 {
+		t.Errorf("state = %+v", state)
+	}
+	if brightness, _ := b.payload("divoom/brightness/state"); brightness != "70" {
+		t.Errorf("brightness state = %q, want 70", brightness)
+	}
+}
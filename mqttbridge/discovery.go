@@ -0,0 +1,97 @@
+package mqttbridge
+
+import (
+	"encoding/json"
+	"log"
+	"strings"
+)
+
+// discoveredSensors are the metrics announced as Home Assistant sensors
+var discoveredSensors = []struct {
+	metric string
+	name   string
+	unit   string
+}{
+	{"cpu.total", "CPU", "%"},
+	{"mem.used_percent", "Memory", "%"},
+	{"disk./", "Disk", "%"},
+	{"net.recv_mb", "Network Down", "MB/s"},
+	{"net.sent_mb", "Network Up", "MB/s"},
+}
+
+// publishDiscovery announces the panel's entities following the Home
+// Assistant MQTT discovery conventions:
+// <discovery_prefix>/<component>/<node_id>/<object_id>/config
+func (b *Bridge) publishDiscovery() {
+	device := map[string]interface{}{
+		"identifiers":  []string{b.cfg.NodeID},
+		"name":         "Pixoo 64 (" + b.cfg.NodeID + ")",
+		"manufacturer": "Divoom",
+		"model":        "Pixoo 64",
+	}
+	availability := b.topic("availability")
+
+	entity := func(objectID, name string) map[string]interface{} {
+		return map[string]interface{}{
+			"name":               name,
+			"unique_id":          b.cfg.NodeID + "_" + objectID,
+			"device":             device,
+			"availability_topic": availability,
+		}
+	}
+
+	brightness := entity("brightness", "Brightness")
+	brightness["command_topic"] = b.topic("brightness", "set")
+	brightness["state_topic"] = b.topic("brightness", "state")
+	brightness["min"] = 0
+	brightness["max"] = 100
+	brightness["unit_of_measurement"] = "%"
+	b.publishConfig("number", "brightness", brightness)
+
+	page := entity("page", "Page")
+	page["command_topic"] = b.topic("page", "set")
+	page["state_topic"] = b.topic("page", "state")
+	page["options"] = b.display.State().Pages
+	b.publishConfig("select", "page", page)
+
+	message := entity("message", "Message")
+	message["command_topic"] = b.topic("message", "set")
+	message["mode"] = "text"
+	b.publishConfig("text", "message", message)
+
+	ack := entity("ack", "Acknowledge Alerts")
+	ack["command_topic"] = b.topic("ack", "set")
+	b.publishConfig("button", "ack", ack)
+
+	for _, s := range discoveredSensors {
+		objectID := sanitizeObjectID(s.metric)
+		sensor := entity(objectID, s.name)
+		sensor["state_topic"] = b.topic("state")
+		sensor["value_template"] = "{{ value_json.metrics['" + s.metric + "'] | round(1) }}"
+		sensor["unit_of_measurement"] = s.unit
+		sensor["state_class"] = "measurement"
+		b.publishConfig("sensor", objectID, sensor)
+	}
+}
+
+func (b *Bridge) publishConfig(component, objectID string, config map[string]interface{}) {
+	data, err := json.Marshal(config)
+	if err != nil {
+		log.Printf("MQTT marshal discovery for %s: %v", objectID, err)
+		return
+	}
+	topic := strings.Join([]string{b.cfg.DiscoveryPrefix, component, b.cfg.NodeID, objectID, "config"}, "/")
+	b.publish(topic, true, data)
+}
+
+// sanitizeObjectID maps a metric name to the [a-zA-Z0-9_] set allowed in
+// discovery topics, e.g. "disk./" -> "disk_root"
+func sanitizeObjectID(metric string) string {
+	if strings.HasSuffix(metric, "/") {
+		metric += "root"
+	}
+	parts := strings.FieldsFunc(metric, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9')
+	})
+	return strings.Join(parts, "_")
+}
@@ -0,0 +1,73 @@
+package mqttbridge
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"divoom-monitor/metrics"
+)
+
+// Source is a metrics.Source holding values received over MQTT. Values
+// are reported as "<name>.<metric>" and dropped once they are older than
+// the source's max age, so a silent publisher doesn't leave a stale
+// reading on the panel.
+type Source struct {
+	name   string
+	maxAge time.Duration
+
+	mu     sync.Mutex
+	values map[string]ingested
+}
+
+type ingested struct {
+	value float64
+	at    time.Time
+}
+
+// NewSource creates a source named name. A non-positive maxAge keeps
+// values forever.
+func NewSource(name string, maxAge time.Duration) *Source {
+	return &Source{name: name, maxAge: maxAge, values: make(map[string]ingested)}
+}
+
+// Set records the latest value of metric
+func (s *Source) Set(metric string, value float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.values[metric] = ingested{value: value, at: time.Now()}
+}
+
+func (s *Source) Name() string { return s.name }
+
+func (s *Source) Schema() []metrics.Desc {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	descs := make([]metrics.Desc, 0, len(s.values))
+	for metric := range s.values {
+		descs = append(descs, metrics.Desc{
+			Name: s.name + "." + metric,
+			Help: "Value received over MQTT",
+			Kind: metrics.Gauge,
+		})
+	}
+	sort.Slice(descs, func(i, j int) bool { return descs[i].Name < descs[j].Name })
+	return descs
+}
+
+func (s *Source) Collect(ctx context.Context) ([]metrics.Value, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	values := make([]metrics.Value, 0, len(s.values))
+	for metric, v := range s.values {
+		if s.maxAge > 0 && time.Since(v.at) > s.maxAge {
+			delete(s.values, metric)
+			continue
+		}
+		values = append(values, metrics.Value{Name: s.name + "." + metric, Value: v.value})
+	}
+	return values, nil
+}
@@ -0,0 +1,220 @@
+// Package notify implements the notification queue that competes with the
+// dashboard for the panel. Producers push messages with a priority and a
+// time-to-live; the renderer pulls them in priority order and interleaves
+// them with its normal pages.
+package notify
+
+import (
+	"fmt"
+	"image/color"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultTTL is used for messages pushed without a TTL
+const DefaultTTL = time.Minute
+
+// Priority orders messages competing for the screen
+type Priority int
+
+const (
+	Low Priority = iota
+	Normal
+	// High messages are shown immediately and take the screen until they
+	// expire or are removed
+	High
+)
+
+func (p Priority) String() string {
+	switch p {
+	case Low:
+		return "low"
+	case High:
+		return "high"
+	default:
+		return "normal"
+	}
+}
+
+// ParsePriority parses "low", "normal" or "high". An empty string is Normal.
+func ParsePriority(s string) (Priority, error) {
+	switch strings.ToLower(s) {
+	case "low":
+		return Low, nil
+	case "", "normal":
+		return Normal, nil
+	case "high":
+		return High, nil
+	default:
+		return Normal, fmt.Errorf("unknown priority %q", s)
+	}
+}
+
+// Message is one notification
+type Message struct {
+	// Key identifies repeats of the same notification. Pushing a message
+	// with an existing key refreshes it instead of queueing a duplicate.
+	// Defaults to Text.
+	Key  string
+	Text string
+	// Scroll is an optional second line that scrolls along the bottom of
+	// the panel, for details too long to fit (e.g. a branch name)
+	Scroll   string
+	Icon     string
+	Color    color.RGBA
+	Priority Priority
+	TTL      time.Duration
+
+	// Set by the queue
+	Created time.Time
+	Expires time.Time
+	// Count is how many times the message has been pushed
+	Count int
+}
+
+// Queue holds pending notifications. It is safe for concurrent use.
+type Queue struct {
+	mu       sync.Mutex
+	messages []*Message
+	// last is the key most recently returned by Next, for round-robin
+	last string
+}
+
+func NewQueue() *Queue {
+	return &Queue{}
+}
+
+// Push queues msg, or refreshes the queued message with the same key:
+// its text, color, icon and expiry are updated, its priority is raised if
+// the repeat is more urgent, and its count is incremented.
+func (q *Queue) Push(msg Message) Message {
+	now := time.Now()
+	if msg.Key == "" {
+		msg.Key = msg.Text
+	}
+	if msg.TTL <= 0 {
+		msg.TTL = DefaultTTL
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	var queued *Message
+	for _, m := range q.messages {
+		if m.Key == msg.Key {
+			queued = m
+			break
+		}
+	}
+
+	if queued == nil {
+		msg.Created = now
+		msg.Count = 1
+		queued = &msg
+		q.messages = append(q.messages, queued)
+	} else {
+		queued.Text = msg.Text
+		queued.Scroll = msg.Scroll
+		queued.Icon = msg.Icon
+		queued.Color = msg.Color
+		queued.TTL = msg.TTL
+		if msg.Priority > queued.Priority {
+			queued.Priority = msg.Priority
+		}
+		queued.Count++
+	}
+	queued.Expires = now.Add(queued.TTL)
+	return *queued
+}
+
+// Remove drops the message with key and reports whether it was queued
+func (q *Queue) Remove(key string) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for i, m := range q.messages {
+		if m.Key == key {
+			q.messages = append(q.messages[:i], q.messages[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Pending returns the unexpired messages, most urgent first and oldest
+// first within a priority
+func (q *Queue) Pending(now time.Time) []Message {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.prune(now)
+	out := make([]Message, len(q.messages))
+	for i, m := range q.sorted() {
+		out[i] = *m
+	}
+	return out
+}
+
+// Top returns the highest priority among pending messages
+func (q *Queue) Top(now time.Time) (Priority, bool) {
+	pending := q.Pending(now)
+	if len(pending) == 0 {
+		return Low, false
+	}
+	return pending[0].Priority, true
+}
+
+// Next returns the message to show now. It cycles round-robin through the
+// pending messages of the highest priority present, so equally urgent
+// messages share the screen.
+func (q *Queue) Next(now time.Time) (Message, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.prune(now)
+	sorted := q.sorted()
+	if len(sorted) == 0 {
+		return Message{}, false
+	}
+
+	top := sorted[0].Priority
+	var candidates []*Message
+	for _, m := range sorted {
+		if m.Priority == top {
+			candidates = append(candidates, m)
+		}
+	}
+
+	next := candidates[0]
+	for i, m := range candidates {
+		if m.Key == q.last {
+			next = candidates[(i+1)%len(candidates)]
+			break
+		}
+	}
+	q.last = next.Key
+	return *next, true
+}
+
+func (q *Queue) prune(now time.Time) {
+	kept := q.messages[:0]
+	for _, m := range q.messages {
+		if now.Before(m.Expires) {
+			kept = append(kept, m)
+		}
+	}
+	q.messages = kept
+}
+
+func (q *Queue) sorted() []*Message {
+	sorted := append([]*Message(nil), q.messages...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Priority != sorted[j].Priority {
+			return sorted[i].Priority > sorted[j].Priority
+		}
+		return sorted[i].Created.Before(sorted[j].Created)
+	})
+	return sorted
+}
@@ -0,0 +1,105 @@
+package notify
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"sort"
+
+	"divoom-monitor/pixoo"
+)
+
+// icons are 8x8 bitmaps, one byte per row, most significant bit leftmost
+var icons = map[string][8]byte{
+	"info":    {0x18, 0x18, 0x00, 0x38, 0x18, 0x18, 0x18, 0x3C},
+	"warning": {0x18, 0x18, 0x3C, 0x24, 0x66, 0x42, 0xDB, 0xFF},
+	"error":   {0x3C, 0x7E, 0xFF, 0x81, 0x81, 0xFF, 0x7E, 0x3C},
+	"check":   {0x00, 0x01, 0x03, 0x06, 0x8C, 0xD8, 0x70, 0x20},
+	"cross":   {0xC3, 0x66, 0x3C, 0x18, 0x18, 0x3C, 0x66, 0xC3},
+	"bell":    {0x18, 0x3C, 0x3C, 0x3C, 0x7E, 0xFF, 0x00, 0x18},
+}
+
+// Icons returns the names of the built-in icons
+func Icons() []string {
+	names := make([]string, 0, len(icons))
+	for name := range icons {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ValidIcon reports whether name is empty or a built-in icon
+func ValidIcon(name string) bool {
+	_, ok := icons[name]
+	return name == "" || ok
+}
+
+// ScrollY is the row where a message's Scroll line is drawn
+const ScrollY = 55
+
+// Render draws msg on a black 64x64 image: the icon (if any) centered at
+// the top, then the wrapped text. Repeated messages show their count.
+// The Scroll line is drawn statically (truncated) along the bottom; the
+// device animates it with a text overlay.
+func Render(msg Message) *image.RGBA {
+	text := msg.Text
+	if msg.Count > 1 {
+		text = fmt.Sprintf("%s x%d", text, msg.Count)
+	}
+
+	bitmap, hasIcon := icons[msg.Icon]
+	if !hasIcon && msg.Scroll == "" {
+		return pixoo.RenderText(text, msg.Color)
+	}
+
+	img := pixoo.CreateImage()
+	pixoo.FillRect(img, 0, 0, 64, 64, color.RGBA{0, 0, 0, 255})
+
+	bottom := 64
+	if msg.Scroll != "" {
+		bottom = ScrollY - 1
+	}
+
+	if hasIcon {
+		drawIcon(img, bitmap, msg.Color)
+		drawLines(img, text, 22, bottom, false, color.RGBA{255, 255, 255, 255})
+	} else {
+		drawLines(img, text, 0, bottom, true, msg.Color)
+	}
+
+	if msg.Scroll != "" {
+		pixoo.DrawTextOnImage(img, pixoo.Truncate(msg.Scroll, pixoo.CharsPerLine), 2, ScrollY, msg.Color)
+	}
+	return img
+}
+
+// drawIcon draws a 2x scaled 8x8 icon, 16x16 at the top center
+func drawIcon(img *image.RGBA, bitmap [8]byte, c color.Color) {
+	for row, bits := range bitmap {
+		for col := 0; col < 8; col++ {
+			if bits&(0x80>>col) != 0 {
+				pixoo.FillRect(img, 24+col*2, 3+row*2, 26+col*2, 5+row*2, c)
+			}
+		}
+	}
+}
+
+// drawLines draws text wrapped and horizontally centered between rows top
+// and bottom, optionally centering the block vertically too
+func drawLines(img *image.RGBA, text string, top, bottom int, middle bool, c color.Color) {
+	lines := pixoo.WrapText(text)
+	if max := (bottom - top) / pixoo.LineHeight; len(lines) > max {
+		lines = lines[:max]
+	}
+
+	y := top
+	if middle {
+		y += (bottom - top - len(lines)*pixoo.LineHeight) / 2
+	}
+	for _, line := range lines {
+		x := (64 - pixoo.TextWidth(line) + 1) / 2
+		pixoo.DrawTextOnImage(img, line, x, y, c)
+		y += pixoo.LineHeight
+	}
+}
@@ -0,0 +1,363 @@
+// Package output writes frames to image files instead of (or as well as)
+// a panel, for designing layouts, screenshots and testing without a
+// device.
+package output
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/draw"
+	"image/gif"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"divoom-monitor/pixoo"
+)
+
+// MaxGIFFrames caps how many frames an animated GIF records; later
+// frames are dropped
+const MaxGIFFrames = 3000
+
+// Options controls how frames are drawn into files
+type Options struct {
+	// Scale is the size in pixels of one panel pixel (1 for 64x64)
+	Scale int
+	// LED draws each pixel as a round dot on black, like the panel looks
+	// up close. It needs a Scale of at least 3.
+	LED bool
+}
+
+// ledOff is the color of an unlit LED, so the dot grid stays visible
+var ledOff = color.RGBA{24, 24, 24, 255}
+
+// Upscale draws a 64x64 image at opts.Scale, optionally as LED dots
+func Upscale(img image.Image, opts Options) *image.RGBA {
+	scale := max(opts.Scale, 1)
+	src := img.Bounds()
+	out := image.NewRGBA(image.Rect(0, 0, src.Dx()*scale, src.Dy()*scale))
+	mask := dotMask(scale, opts.LED)
+
+	for y := 0; y < src.Dy(); y++ {
+		for x := 0; x < src.Dx(); x++ {
+			c := color.RGBAModel.Convert(img.At(src.Min.X+x, src.Min.Y+y)).(color.RGBA)
+			c.A = 255
+			if opts.LED && c.R == 0 && c.G == 0 && c.B == 0 {
+				c = ledOff
+			}
+			for dy := 0; dy < scale; dy++ {
+				for dx := 0; dx < scale; dx++ {
+					if mask[dy*scale+dx] {
+						out.SetRGBA(x*scale+dx, y*scale+dy, c)
+					} else {
+						out.SetRGBA(x*scale+dx, y*scale+dy, color.RGBA{0, 0, 0, 255})
+					}
+				}
+			}
+		}
+	}
+	return out
+}
+
+// dotMask marks which pixels of a scale x scale cell are lit: all of
+// them, or a disc when simulating LEDs
+func dotMask(scale int, led bool) []bool {
+	mask := make([]bool, scale*scale)
+	center := float64(scale) / 2
+	radius := float64(scale) * 0.42
+	for y := 0; y < scale; y++ {
+		for x := 0; x < scale; x++ {
+			dx, dy := float64(x)+0.5-center, float64(y)+0.5-center
+			mask[y*scale+x] = !led || dx*dx+dy*dy <= radius*radius
+		}
+	}
+	return mask
+}
+
+// format is how a Writer stores frames
+type format int
+
+const (
+	// formatPNG overwrites one PNG with the latest frame
+	formatPNG format = iota
+	// formatSequence writes numbered PNGs
+	formatSequence
+	// formatGIF collects frames into an animated GIF written on Close
+	formatGIF
+)
+
+// Writer saves frames to files. It implements pixoo.PixooClient, so it
+// can take the place of a panel. The target decides the format:
+//
+//	preview.png            overwritten with the latest frame
+//	frames/frame-%04d.png  one numbered PNG per frame
+//	frames/                the same, as frame-00001.png, frame-00002.png...
+//	demo.gif               an animated GIF, written when the Writer is closed
+type Writer struct {
+	path   string
+	format format
+	opts   Options
+
+	mu     sync.Mutex
+	count  int
+	frames []*image.RGBA
+	times  []time.Time
+	closed bool
+}
+
+// Target is where -output sends frames
+type Target interface {
+	pixoo.PixooClient
+	io.Closer
+	// Frames returns how many frames have been written
+	Frames() int
+}
+
+// New opens an -output target: TerminalTarget draws in the terminal,
+// anything else is a file path passed to Open
+func New(target string, opts Options) (Target, error) {
+	if target == TerminalTarget {
+		return NewTerminal(os.Stdout, DetectColorMode()), nil
+	}
+	return Open(target, opts)
+}
+
+// Open prepares a writer for path; see Writer for the accepted forms
+func Open(path string, opts Options) (*Writer, error) {
+	if opts.Scale < 1 {
+		opts.Scale = 1
+	}
+	if opts.LED && opts.Scale < 3 {
+		return nil, fmt.Errorf("LED dots need a scale of at least 3, got %d", opts.Scale)
+	}
+
+	w := &Writer{path: path, opts: opts}
+	ext := strings.ToLower(filepath.Ext(path))
+	switch {
+	case ext == ".gif":
+		w.format = formatGIF
+	case ext == ".png" && strings.Contains(path, "%"):
+		w.format = formatSequence
+	case ext == ".png":
+		w.format = formatPNG
+	case strings.HasSuffix(path, "/") || isDir(path):
+		w.format = formatSequence
+		w.path = filepath.Join(path, "frame-%05d.png")
+	default:
+		return nil, fmt.Errorf("output %q must be a .png, .gif or directory", path)
+	}
+
+	if dir := filepath.Dir(w.path); dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, fmt.Errorf("create output directory: %w", err)
+		}
+	}
+	return w, nil
+}
+
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+// Frames returns how many frames have been written or recorded
+func (w *Writer) Frames() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.count
+}
+
+// DrawImage writes img, a 64x64 frame
+func (w *Writer) DrawImage(img image.Image) error {
+	return w.DrawImageContext(context.Background(), img)
+}
+
+// DrawImageContext writes img; files are local, so ctx is only checked
+// before starting
+func (w *Writer) DrawImageContext(ctx context.Context, img image.Image) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	b := img.Bounds()
+	if b.Dx() != 64 || b.Dy() != 64 {
+		return pixoo.Permanent(fmt.Errorf("image must be 64x64 pixels"))
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return errors.New("output closed")
+	}
+
+	switch w.format {
+	case formatGIF:
+		if len(w.frames) >= MaxGIFFrames {
+			return nil
+		}
+		// Keep a copy at panel size; callers reuse their images
+		frame := pixoo.CreateImage()
+		for y := 0; y < 64; y++ {
+			for x := 0; x < 64; x++ {
+				frame.Set(x, y, img.At(b.Min.X+x, b.Min.Y+y))
+			}
+		}
+		w.frames = append(w.frames, frame)
+		w.times = append(w.times, time.Now())
+	case formatSequence:
+		if err := writePNG(fmt.Sprintf(w.path, w.count+1), Upscale(img, w.opts)); err != nil {
+			return err
+		}
+	default:
+		if err := writePNG(w.path, Upscale(img, w.opts)); err != nil {
+			return err
+		}
+	}
+	w.count++
+	return nil
+}
+
+// writePNG writes img through a temporary file, so a viewer watching
+// path never sees a half-written frame
+func writePNG(path string, img image.Image) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".frame-*.png")
+	if err != nil {
+		return fmt.Errorf("create frame file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := png.Encode(tmp, img); err != nil {
+		tmp.Close()
+		return fmt.Errorf("encode png: %w", err)
+	}
+	// CreateTemp makes the file private; frames are meant to be shared
+	if err := tmp.Chmod(0o644); err != nil {
+		tmp.Close()
+		return fmt.Errorf("write frame file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("write frame file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("write frame file: %w", err)
+	}
+	return nil
+}
+
+// DrawText renders text with the built-in font and writes it as a frame
+func (w *Writer) DrawText(text string, r, g, b uint8) error {
+	return w.DrawTextContext(context.Background(), text, r, g, b)
+}
+
+// DrawTextContext is DrawText bounded by ctx
+func (w *Writer) DrawTextContext(ctx context.Context, text string, r, g, b uint8) error {
+	return w.DrawImageContext(ctx, pixoo.RenderText(text, color.RGBA{r, g, b, 255}))
+}
+
+// ClearScreen writes a black frame
+func (w *Writer) ClearScreen() error {
+	return w.ClearScreenContext(context.Background())
+}
+
+// ClearScreenContext is ClearScreen bounded by ctx
+func (w *Writer) ClearScreenContext(ctx context.Context) error {
+	img := pixoo.CreateImage()
+	pixoo.FillRect(img, 0, 0, 64, 64, color.RGBA{0, 0, 0, 255})
+	return w.DrawImageContext(ctx, img)
+}
+
+// SetBrightness is accepted and ignored; files are always at full
+// brightness
+func (w *Writer) SetBrightness(brightness int) error {
+	return nil
+}
+
+// SetBrightnessContext is SetBrightness bounded by ctx
+func (w *Writer) SetBrightnessContext(ctx context.Context, brightness int) error {
+	return nil
+}
+
+// Close writes the GIF, if that is the format. Frames drawn afterwards
+// are rejected.
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	if w.format != formatGIF || len(w.frames) == 0 {
+		return nil
+	}
+
+	anim := &gif.GIF{LoopCount: 0}
+	for i, frame := range w.frames {
+		anim.Image = append(anim.Image, paletted(Upscale(frame, w.opts)))
+		anim.Delay = append(anim.Delay, w.delay(i))
+	}
+
+	f, err := os.Create(w.path)
+	if err != nil {
+		return fmt.Errorf("create gif: %w", err)
+	}
+	if err := gif.EncodeAll(f, anim); err != nil {
+		f.Close()
+		return fmt.Errorf("encode gif: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("write gif: %w", err)
+	}
+	return nil
+}
+
+// delay returns how long frame i was on screen, in 100ths of a second.
+// The last frame repeats the delay before it.
+func (w *Writer) delay(i int) int {
+	if len(w.times) < 2 {
+		return 100
+	}
+	if i == len(w.times)-1 {
+		i--
+	}
+	return max(int(w.times[i+1].Sub(w.times[i])/(10*time.Millisecond)), 2)
+}
+
+// paletted converts img for GIF encoding. Panel frames rarely use more
+// than 256 colors, so they keep their exact colors; busier frames fall
+// back to dithering with the Plan 9 palette.
+func paletted(img *image.RGBA) *image.Paletted {
+	b := img.Bounds()
+	index := make(map[color.RGBA]uint8)
+	var pal color.Palette
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			c := img.RGBAAt(x, y)
+			if _, ok := index[c]; ok {
+				continue
+			}
+			if len(pal) == 256 {
+				out := image.NewPaletted(b, palette.Plan9)
+				draw.FloydSteinberg.Draw(out, b, img, b.Min)
+				return out
+			}
+			index[c] = uint8(len(pal))
+			pal = append(pal, c)
+		}
+	}
+
+	out := image.NewPaletted(b, pal)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			out.SetColorIndex(x, y, index[img.RGBAAt(x, y)])
+		}
+	}
+	return out
+}
@@ -0,0 +1,178 @@
+package output
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"divoom-monitor/pixoo"
+)
+
+// testFrame fills a frame with c and marks the top left pixel white
+func testFrame(c color.RGBA) *image.RGBA {
+	img := pixoo.CreateImage()
+	pixoo.FillRect(img, 0, 0, 64, 64, c)
+	img.Set(0, 0, color.RGBA{255, 255, 255, 255})
+	return img
+}
+
+func decodePNG(t *testing.T, path string) image.Image {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	img, err := png.Decode(f)
+	if err != nil {
+		t.Fatalf("decode %s: %v", path, err)
+	}
+	return img
+}
+
+func rgba(c color.Color) color.RGBA {
+	return color.RGBAModel.Convert(c).(color.RGBA)
+}
+
+func TestWriterPNG(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out", "preview.png")
+	w, err := Open(path, Options{Scale: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	red, blue := color.RGBA{255, 0, 0, 255}, color.RGBA{0, 0, 255, 255}
+	for _, c := range []color.RGBA{red, blue} {
+		if err := w.DrawImage(testFrame(c)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The file holds the latest frame, upscaled
+	img := decodePNG(t, path)
+	if b := img.Bounds(); b.Dx() != 128 || b.Dy() != 128 {
+		t.Fatalf("png is %v, want 128x128", b)
+	}
+	for _, p := range []struct {
+		x, y int
+		want color.RGBA
+	}{
+		{0, 0, color.RGBA{255, 255, 255, 255}},
+		{1, 1, color.RGBA{255, 255, 255, 255}},
+		{2, 0, blue},
+		{127, 127, blue},
+	} {
+		if got := rgba(img.At(p.x, p.y)); got != p.want {
+			t.Errorf("pixel %d,%d = %v, want %v", p.x, p.y, got, p.want)
+		}
+	}
+	if w.Frames() != 2 {
+		t.Errorf("Frames() = %d, want 2", w.Frames())
+	}
+	if err := w.DrawImage(image.NewRGBA(image.Rect(0, 0, 32, 32))); !pixoo.IsPermanent(err) {
+		t.Errorf("a 32x32 frame gave %v, want a permanent error", err)
+	}
+}
+
+func TestWriterSequence(t *testing.T) {
+	dir := t.TempDir() + "/frames/"
+	w, err := Open(dir, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	colors := []color.RGBA{{255, 0, 0, 255}, {0, 255, 0, 255}, {0, 0, 255, 255}}
+	for _, c := range colors {
+		if err := w.DrawImage(testFrame(c)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	w.Close()
+
+	for i, want := range colors {
+		img := decodePNG(t, filepath.Join(dir, fmt.Sprintf("frame-%05d.png", i+1)))
+		if got := rgba(img.At(10, 10)); got != want {
+			t.Errorf("frame %d = %v, want %v", i+1, got, want)
+		}
+	}
+}
+
+func TestWriterGIF(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "demo.gif")
+	w, err := Open(path, Options{Scale: 4, LED: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	colors := []color.RGBA{{255, 0, 0, 255}, {0, 255, 0, 255}, {0, 0, 0, 255}}
+	frame := pixoo.CreateImage()
+	for _, c := range colors {
+		// The writer keeps a copy, so reusing the image is fine
+		pixoo.FillRect(frame, 0, 0, 64, 64, c)
+		if err := w.DrawImage(frame); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatal("gif written before Close")
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.DrawImage(frame); err == nil {
+		t.Error("drawing after Close succeeded")
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	anim, err := gif.DecodeAll(f)
+	if err != nil {
+		t.Fatalf("decode gif: %v", err)
+	}
+	if len(anim.Image) != len(colors) || len(anim.Delay) != len(colors) {
+		t.Fatalf("gif has %d frames and %d delays, want %d", len(anim.Image), len(anim.Delay), len(colors))
+	}
+	for i, want := range colors {
+		img := anim.Image[i]
+		if b := img.Bounds(); b.Dx() != 256 || b.Dy() != 256 {
+			t.Fatalf("frame %d is %v, want 256x256", i, b)
+		}
+		if want == (color.RGBA{0, 0, 0, 255}) {
+			want = ledOff
+		}
+		// The middle of a dot has the pixel's color, the corner is black
+		if got := rgba(img.At(2, 2)); got != want {
+			t.Errorf("frame %d dot = %v, want %v", i, got, want)
+		}
+		if got := rgba(img.At(0, 0)); got != (color.RGBA{0, 0, 0, 255}) {
+			t.Errorf("frame %d gap between dots = %v, want black", i, got)
+		}
+		if anim.Delay[i] < 2 {
+			t.Errorf("frame %d delay %d, want at least 2", i, anim.Delay[i])
+		}
+	}
+}
+
+func TestOpenErrors(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		path string
+		opts Options
+	}{
+		{filepath.Join(dir, "out.jpg"), Options{}},
+		{filepath.Join(dir, "out.png"), Options{Scale: 2, LED: true}},
+	}
+	for _, tt := range tests {
+		if _, err := Open(tt.path, tt.opts); err == nil {
+			t.Errorf("Open(%q, %+v) succeeded", tt.path, tt.opts)
+		}
+	}
+}
@@ -0,0 +1,227 @@
+package output
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"divoom-monitor/pixoo"
+)
+
+// TerminalTarget is the -output value that draws frames in the terminal
+const TerminalTarget = "term"
+
+// ColorMode is how many colors the terminal can show
+type ColorMode int
+
+const (
+	// TrueColor uses 24-bit colors, shown exactly
+	TrueColor ColorMode = iota
+	// Color256 maps colors to the xterm 256-color palette
+	Color256
+)
+
+// DetectColorMode picks TrueColor if $COLORTERM advertises it, as most
+// modern terminals and SSH sessions forwarding it do
+func DetectColorMode() ColorMode {
+	switch strings.ToLower(os.Getenv("COLORTERM")) {
+	case "truecolor", "24bit":
+		return TrueColor
+	}
+	return Color256
+}
+
+// Terminal draws frames with half-block characters: each of the 32 rows
+// shows two panel rows, the upper pixel as the foreground of '▀' and the
+// lower one as the background. The frame stays in the top rows of the
+// terminal while anything else printed (e.g. log lines) scrolls below it.
+// It implements pixoo.PixooClient.
+type Terminal struct {
+	w    io.Writer
+	mode ColorMode
+
+	mu      sync.Mutex
+	started bool
+	closed  bool
+	count   int
+}
+
+// NewTerminal draws on w, typically os.Stdout
+func NewTerminal(w io.Writer, mode ColorMode) *Terminal {
+	return &Terminal{w: w, mode: mode}
+}
+
+// frameRows is how many terminal rows a frame takes
+const frameRows = 32
+
+// Frames returns how many frames have been drawn
+func (t *Terminal) Frames() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.count
+}
+
+// DrawImage draws a 64x64 frame
+func (t *Terminal) DrawImage(img image.Image) error {
+	return t.DrawImageContext(context.Background(), img)
+}
+
+// DrawImageContext draws a 64x64 frame; ctx is only checked before
+// starting
+func (t *Terminal) DrawImageContext(ctx context.Context, img image.Image) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	b := img.Bounds()
+	if b.Dx() != 64 || b.Dy() != 64 {
+		return pixoo.Permanent(fmt.Errorf("image must be 64x64 pixels"))
+	}
+
+	var buf bytes.Buffer
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return fmt.Errorf("terminal closed")
+	}
+	if !t.started {
+		// Clear, hide the cursor and keep the frame out of the region
+		// that scrolls, leaving the cursor just below the frame
+		fmt.Fprintf(&buf, "\x1b[2J\x1b[?25l\x1b[%d;r\x1b[%d;1H", frameRows+2, frameRows+2)
+		t.started = true
+	}
+
+	// Save the cursor, draw from the top left, then put it back
+	buf.WriteString("\x1b7\x1b[H")
+	var fg, bg string
+	for row := 0; row < frameRows; row++ {
+		fg, bg = "", ""
+		for x := 0; x < 64; x++ {
+			top := rgbAt(img, b.Min.X+x, b.Min.Y+row*2)
+			bottom := rgbAt(img, b.Min.X+x, b.Min.Y+row*2+1)
+			if s := t.color(38, top); s != fg {
+				buf.WriteString(s)
+				fg = s
+			}
+			if s := t.color(48, bottom); s != bg {
+				buf.WriteString(s)
+				bg = s
+			}
+			buf.WriteString("▀")
+		}
+		buf.WriteString("\x1b[0m\r\n")
+	}
+	buf.WriteString("\x1b8")
+
+	if _, err := t.w.Write(buf.Bytes()); err != nil {
+		return fmt.Errorf("write frame: %w", err)
+	}
+	t.count++
+	return nil
+}
+
+func rgbAt(img image.Image, x, y int) color.RGBA {
+	return color.RGBAModel.Convert(img.At(x, y)).(color.RGBA)
+}
+
+// color returns the escape sequence selecting c as the foreground (38)
+// or background (48) color
+func (t *Terminal) color(layer int, c color.RGBA) string {
+	if t.mode == TrueColor {
+		return fmt.Sprintf("\x1b[%d;2;%d;%d;%dm", layer, c.R, c.G, c.B)
+	}
+	return fmt.Sprintf("\x1b[%d;5;%dm", layer, ansi256(c))
+}
+
+// cubeLevels are the channel values of the 6x6x6 color cube in the xterm
+// 256-color palette
+var cubeLevels = [6]int{0, 95, 135, 175, 215, 255}
+
+// ansi256 returns the palette index closest to c: either a color cube
+// entry (16-231) or a grey (232-255)
+func ansi256(c color.RGBA) int {
+	cube := func(v uint8) int {
+		best := 0
+		for i, level := range cubeLevels {
+			if abs(int(v)-level) < abs(int(v)-cubeLevels[best]) {
+				best = i
+			}
+		}
+		return best
+	}
+	r, g, b := cube(c.R), cube(c.G), cube(c.B)
+	cubeIndex := 16 + 36*r + 6*g + b
+	cubeDist := sq(int(c.R)-cubeLevels[r]) + sq(int(c.G)-cubeLevels[g]) + sq(int(c.B)-cubeLevels[b])
+
+	avg := (int(c.R) + int(c.G) + int(c.B)) / 3
+	grey := min(max((avg-8+5)/10, 0), 23)
+	level := 8 + grey*10
+	greyDist := sq(int(c.R)-level) + sq(int(c.G)-level) + sq(int(c.B)-level)
+
+	if greyDist < cubeDist {
+		return 232 + grey
+	}
+	return cubeIndex
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+func sq(v int) int { return v * v }
+
+// DrawText renders text with the built-in font and draws it as a frame
+func (t *Terminal) DrawText(text string, r, g, b uint8) error {
+	return t.DrawTextContext(context.Background(), text, r, g, b)
+}
+
+// DrawTextContext is DrawText bounded by ctx
+func (t *Terminal) DrawTextContext(ctx context.Context, text string, r, g, b uint8) error {
+	return t.DrawImageContext(ctx, pixoo.RenderText(text, color.RGBA{r, g, b, 255}))
+}
+
+// ClearScreen draws a black frame
+func (t *Terminal) ClearScreen() error {
+	return t.ClearScreenContext(context.Background())
+}
+
+// ClearScreenContext is ClearScreen bounded by ctx
+func (t *Terminal) ClearScreenContext(ctx context.Context) error {
+	img := pixoo.CreateImage()
+	pixoo.FillRect(img, 0, 0, 64, 64, color.RGBA{0, 0, 0, 255})
+	return t.DrawImageContext(ctx, img)
+}
+
+// SetBrightness is accepted and ignored
+func (t *Terminal) SetBrightness(brightness int) error {
+	return nil
+}
+
+// SetBrightnessContext is SetBrightness bounded by ctx
+func (t *Terminal) SetBrightnessContext(ctx context.Context, brightness int) error {
+	return nil
+}
+
+// Close restores the terminal: the whole screen scrolls again and the
+// cursor is visible. The last frame stays on screen.
+func (t *Terminal) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.closed || !t.started {
+		t.closed = true
+		return nil
+	}
+	t.closed = true
+	// Resetting the scroll region homes the cursor, so save it around that
+	_, err := io.WriteString(t.w, "\x1b[0m\x1b7\x1b[r\x1b8\x1b[?25h")
+	return err
+}
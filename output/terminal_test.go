@@ -0,0 +1,131 @@
+package output
+
+import (
+	"bytes"
+	"image/color"
+	"strings"
+	"testing"
+
+	"divoom-monitor/pixoo"
+)
+
+func TestAnsi256(t *testing.T) {
+	tests := []struct {
+		c    color.RGBA
+		want int
+	}{
+		// Cube colors, exact and nearest
+		{color.RGBA{0, 0, 0, 255}, 16},
+		{color.RGBA{255, 255, 255, 255}, 231},
+		{color.RGBA{255, 0, 0, 255}, 196},
+		{color.RGBA{0, 0, 255, 255}, 21},
+		{color.RGBA{95, 135, 175, 255}, 67},
+		{color.RGBA{250, 100, 10, 255}, 16 + 36*5 + 6*1 + 0},
+		// Grays between the cube levels land on the gray ramp
+		{color.RGBA{8, 8, 8, 255}, 232},
+		{color.RGBA{100, 100, 100, 255}, 241},
+		{color.RGBA{128, 128, 128, 255}, 244},
+		{color.RGBA{238, 238, 238, 255}, 255},
+		// A gray that sits on a cube level stays in the cube
+		{color.RGBA{95, 95, 95, 255}, 59},
+	}
+	for _, tt := range tests {
+		if got := ansi256(tt.c); got != tt.want {
+			t.Errorf("ansi256(%v) = %d, want %d", tt.c, got, tt.want)
+		}
+	}
+}
+
+// frameRowsOf returns the rows of one drawn frame with the cursor
+// handling around it removed
+func frameRowsOf(t *testing.T, out string) []string {
+	t.Helper()
+	start := strings.Index(out, "\x1b7\x1b[H")
+	end := strings.LastIndex(out, "\x1b8")
+	if start < 0 || end < start {
+		t.Fatalf("frame is not wrapped in cursor save and restore: %q", out)
+	}
+	rows := strings.Split(out[start+len("\x1b7\x1b[H"):end], "\r\n")
+	if last := rows[len(rows)-1]; last != "" {
+		t.Fatalf("frame does not end with a newline: %q", last)
+	}
+	return rows[:len(rows)-1]
+}
+
+func TestTerminalDraw(t *testing.T) {
+	// Even panel rows red, odd rows blue, and one gray pixel at 1,0
+	img := pixoo.CreateImage()
+	for y := 0; y < 64; y++ {
+		c := color.RGBA{255, 0, 0, 255}
+		if y%2 == 1 {
+			c = color.RGBA{0, 0, 255, 255}
+		}
+		pixoo.FillRect(img, 0, y, 64, y+1, c)
+	}
+	img.Set(1, 0, color.RGBA{128, 128, 128, 255})
+
+	tests := []struct {
+		name            string
+		mode            ColorMode
+		red, blue, gray string
+	}{
+		{"truecolor", TrueColor, "\x1b[38;2;255;0;0m", "\x1b[48;2;0;0;255m", "\x1b[38;2;128;128;128m"},
+		{"256 colors", Color256, "\x1b[38;5;196m", "\x1b[48;5;21m", "\x1b[38;5;244m"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			term := NewTerminal(&buf, tt.mode)
+			if err := term.DrawImage(img); err != nil {
+				t.Fatal(err)
+			}
+			out := buf.String()
+			if !strings.HasPrefix(out, "\x1b[2J\x1b[?25l\x1b[34;r") {
+				t.Errorf("first frame does not clear and set the scroll region: %q", out[:min(len(out), 20)])
+			}
+
+			rows := frameRowsOf(t, out)
+			if len(rows) != 32 {
+				t.Fatalf("got %d rows, want 32", len(rows))
+			}
+			for i, row := range rows {
+				if n := strings.Count(row, "▀"); n != 64 {
+					t.Errorf("row %d has %d half blocks, want 64", i, n)
+				}
+				if !strings.HasSuffix(row, "▀\x1b[0m") {
+					t.Errorf("row %d does not reset its colors", i)
+				}
+			}
+
+			// Colors are only repeated when they change
+			want := tt.red + tt.blue + "▀" + tt.gray + "▀" + tt.red + strings.Repeat("▀", 62) + "\x1b[0m"
+			if rows[0] != want {
+				t.Errorf("row 0 = %q\nwant %q", rows[0], want)
+			}
+			if want := tt.red + tt.blue + strings.Repeat("▀", 64) + "\x1b[0m"; rows[31] != want {
+				t.Errorf("row 31 = %q\nwant %q", rows[31], want)
+			}
+
+			// Later frames redraw in place
+			buf.Reset()
+			if err := term.DrawImage(img); err != nil {
+				t.Fatal(err)
+			}
+			if strings.Contains(buf.String(), "\x1b[2J") || len(frameRowsOf(t, buf.String())) != 32 {
+				t.Errorf("second frame = %q", buf.String())
+			}
+			if term.Frames() != 2 {
+				t.Errorf("Frames() = %d, want 2", term.Frames())
+			}
+
+			buf.Reset()
+			term.Close()
+			if !strings.Contains(buf.String(), "\x1b[r") || !strings.HasSuffix(buf.String(), "\x1b[?25h") {
+				t.Errorf("Close did not restore the terminal: %q", buf.String())
+			}
+			if err := term.DrawImage(img); err == nil {
+				t.Error("drawing after Close succeeded")
+			}
+		})
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"divoom-monitor/media"
+	"divoom-monitor/output"
+	"divoom-monitor/pixoo"
+	"divoom-monitor/sim"
+)
+
+func main() {
+	host := flag.String("host", "", "Pixoo 64 device IP address, \"auto\" to discover it, or \"sim\" to show it in a browser")
+	cloudURL := flag.String("cloud-url", pixoo.DefaultCloudURL, "Divoom cloud API used by -host auto (empty to only scan the LAN)")
+	simAddr := flag.String("sim-addr", sim.DefaultAddr, "Address the -host sim simulator is served on")
+	outputPath := flag.String("output", "", "Write the picture to a .png, a numbered .png pattern or directory, an animated .gif, or \"term\" instead of a device")
+	outputScale := flag.Int("output-scale", 8, "Pixel size of -output frames")
+	outputLED := flag.Bool("output-led", false, "Draw -output pixels as LED dots")
+	fit := flag.String("fit", "contain", "How to fit the picture to the square panel: contain, cover or stretch")
+	pixelated := flag.Bool("pixelated", false, "Scale with nearest-neighbor sampling, for pixel art and sprites")
+	colors := flag.Int("colors", 0, "Reduce to a palette of this many colors with dithering (2-256, 0 keeps full color)")
+	brightness := flag.Int("brightness", -1, "Screen brightness (0-100, -1 leaves it unchanged)")
+	timeout := flag.Duration("timeout", 30*time.Second, "How long to wait for a download")
+	flag.Parse()
+
+	if flag.NArg() != 1 || (*host == "") == (*outputPath == "") {
+		fmt.Println("Usage: ./pixoo-image [options] <file or URL>")
+		fmt.Println("\nOne of -host or -output is required. PNG, JPEG, WebP and GIF are supported;")
+		fmt.Println("animated GIFs keep their frame timings.")
+		fmt.Println("\nExamples:")
+		fmt.Println("  ./pixoo-image -host 192.168.1.140 photo.jpg")
+		fmt.Println("  ./pixoo-image -host 192.168.1.140 -pixelated sprite.gif")
+		fmt.Println("  ./pixoo-image -host sim -fit cover https://example.com/banner.webp")
+		fmt.Println("  ./pixoo-image -output term -colors 16 photo.png")
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	fitMode, err := media.ParseFit(*fit)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	loadCtx, cancel := context.WithTimeout(ctx, *timeout)
+	frames, err := media.Load(loadCtx, flag.Arg(0), media.Options{Fit: fitMode, Pixelated: *pixelated, Colors: *colors})
+	cancel()
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	if len(frames) > 1 {
+		var total time.Duration
+		for _, f := range frames {
+			total += f.Delay
+		}
+		log.Printf("Loaded %s: %d frames, %.1fs per loop", flag.Arg(0), len(frames), total.Seconds())
+	} else {
+		log.Printf("Loaded %s", flag.Arg(0))
+	}
+
+	if *host == pixoo.AutoHost {
+		found, err := pixoo.ResolveHost(ctx, *host, *cloudURL)
+		if err != nil {
+			log.Fatalf("Error: %v (pass -host <ip> instead)", err)
+		}
+		log.Printf("Found Pixoo at %s", found)
+		*host = found
+	}
+
+	// Devices loop animations by themselves; the simulator and the
+	// terminal are sent frames until Ctrl+C, and files get one loop
+	var client pixoo.PixooClient
+	wait := false
+	switch {
+	case *host == sim.Host:
+		simulator, err := sim.Start(*simAddr)
+		if err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		defer simulator.Close()
+		log.Printf("Serving panel simulator on %s", simulator.URL())
+		client, wait = simulator, true
+	case *host != "":
+		c := pixoo.NewClient(*host)
+		defer c.Close()
+		// Show the picture on the Custom channel
+		if err := c.SetChannelContext(ctx, 3); err != nil {
+			log.Printf("Warning: failed to set channel: %v", err)
+		}
+		client = c
+	default:
+		out, err := output.New(*outputPath, output.Options{Scale: *outputScale, LED: *outputLED})
+		if err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		defer func() {
+			if err := out.Close(); err != nil {
+				log.Printf("Error writing %s: %v", *outputPath, err)
+			}
+		}()
+		client, wait = out, *outputPath == output.TerminalTarget
+	}
+
+	if *brightness >= 0 {
+		if err := client.SetBrightnessContext(ctx, *brightness); err != nil {
+			log.Printf("Warning: failed to set brightness: %v", err)
+		}
+	}
+
+	if err := media.Show(ctx, client, frames, wait); err != nil && ctx.Err() == nil {
+		log.Fatalf("Error: %v", err)
+	}
+	if wait && ctx.Err() == nil {
+		log.Println("Press Ctrl+C to exit")
+		<-ctx.Done()
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"slices"
+	"syscall"
+
+	"divoom-monitor/output"
+	"divoom-monitor/pixoo"
+	"divoom-monitor/sim"
+)
+
+func main() {
+	host := flag.String("host", "", "Pixoo 64 device IP address to replay to, or \"sim\" to show it in a browser")
+	simAddr := flag.String("sim-addr", sim.DefaultAddr, "Address the -host sim simulator is served on")
+	outputPath := flag.String("output", "", "Replay to a .png, a numbered .png pattern or directory, an animated .gif, or \"term\" instead of a device")
+	outputScale := flag.Int("output-scale", 8, "Pixel size of -output frames")
+	outputLED := flag.Bool("output-led", false, "Draw -output pixels as LED dots")
+	speed := flag.Float64("speed", 1, "Replay speed relative to the recording (0 sends commands back to back)")
+	from := flag.String("from", "", "Only replay commands recorded for this device host")
+	loop := flag.Bool("loop", false, "Start over when the recording ends")
+	flag.Parse()
+
+	if flag.NArg() != 1 || (*host == "") == (*outputPath == "") {
+		fmt.Println("Usage: ./pixoo-replay [options] <recording.jsonl>")
+		fmt.Println("\nOne of -host or -output is required.")
+		fmt.Println("\nExamples:")
+		fmt.Println("  ./pixoo-replay -host 192.168.1.140 session.jsonl")
+		fmt.Println("  ./pixoo-replay -host sim -speed 4 session.jsonl")
+		fmt.Println("  ./pixoo-replay -output replay.gif -speed 0 session.jsonl")
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	cmds, err := pixoo.OpenRecording(flag.Arg(0))
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	if !slices.ContainsFunc(cmds, func(rc pixoo.RecordedCommand) bool { return *from == "" || rc.Host == *from }) {
+		log.Fatalf("Error: no commands to replay in %s", flag.Arg(0))
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var client pixoo.PixooClient
+	switch {
+	case *host == sim.Host:
+		simulator, err := sim.Start(*simAddr)
+		if err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		defer simulator.Close()
+		log.Printf("Serving panel simulator on %s", simulator.URL())
+		client = simulator
+	case *host != "":
+		// Send exactly what was recorded: recorded retries are replayed
+		// as they happened, so the client adds none of its own
+		c := pixoo.NewClient(*host)
+		defer c.Close()
+		c.SetRetryPolicy(pixoo.RetryPolicy{})
+		c.SetBreaker(pixoo.BreakerConfig{})
+		client = c
+	default:
+		out, err := output.New(*outputPath, output.Options{Scale: *outputScale, LED: *outputLED})
+		if err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		defer func() {
+			if err := out.Close(); err != nil {
+				log.Printf("Error writing %s: %v", *outputPath, err)
+			}
+		}()
+		client = out
+	}
+
+	var sent, failed int
+	opts := pixoo.ReplayOptions{
+		Speed: *speed,
+		Host:  *from,
+		OnCommand: func(rc pixoo.RecordedCommand, err error) {
+			sent++
+			if err != nil && ctx.Err() == nil {
+				failed++
+				log.Printf("%s: %v", rc.Name(), err)
+			}
+		},
+	}
+
+	pace := fmt.Sprintf("%gx speed", *speed)
+	if *speed <= 0 {
+		pace = "full speed"
+	}
+	log.Printf("Replaying %d command(s) from %s at %s", len(cmds), flag.Arg(0), pace)
+	for {
+		if err := pixoo.Replay(ctx, client, cmds, opts); err != nil {
+			log.Printf("Replay stopped: %v", err)
+			break
+		}
+		if !*loop {
+			break
+		}
+	}
+	log.Printf("Replayed %d command(s), %d failed", sent, failed)
+
+	// Leave the last frame up for viewers until interrupted
+	if *host == sim.Host && ctx.Err() == nil {
+		log.Println("Press Ctrl+C to exit")
+		<-ctx.Done()
+	}
+}
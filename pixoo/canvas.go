@@ -0,0 +1,97 @@
+package pixoo
+
+import (
+	"image"
+	"image/color"
+)
+
+// Canvas is a 64x64 frame stored as packed RGB888, the layout the device
+// expects. Clients upload it without converting pixels, and SetRGB and
+// RGBAt avoid the color.Color boxing of image.RGBA. It implements
+// draw.Image, so the drawing helpers and image/draw work on it too.
+type Canvas struct {
+	// Pix holds the pixels row by row as R,G,B,R,G,B,...
+	Pix []byte
+}
+
+// NewCanvas creates a black canvas
+func NewCanvas() *Canvas {
+	return &Canvas{Pix: make([]byte, 64*64*3)}
+}
+
+// ColorModel implements image.Image
+func (c *Canvas) ColorModel() color.Model { return color.RGBAModel }
+
+// Bounds implements image.Image
+func (c *Canvas) Bounds() image.Rectangle { return image.Rect(0, 0, 64, 64) }
+
+// At implements image.Image
+func (c *Canvas) At(x, y int) color.Color {
+	r, g, b := c.RGBAt(x, y)
+	return color.RGBA{r, g, b, 255}
+}
+
+// Set implements draw.Image. Alpha is ignored; the panel has none.
+func (c *Canvas) Set(x, y int, col color.Color) {
+	r, g, b, _ := col.RGBA()
+	c.SetRGB(x, y, uint8(r>>8), uint8(g>>8), uint8(b>>8))
+}
+
+// SetRGB sets one pixel; coordinates outside the canvas are ignored
+func (c *Canvas) SetRGB(x, y int, r, g, b uint8) {
+	if uint(x) >= 64 || uint(y) >= 64 {
+		return
+	}
+	i := (y*64 + x) * 3
+	c.Pix[i], c.Pix[i+1], c.Pix[i+2] = r, g, b
+}
+
+// RGBAt returns one pixel, or black outside the canvas
+func (c *Canvas) RGBAt(x, y int) (r, g, b uint8) {
+	if uint(x) >= 64 || uint(y) >= 64 {
+		return 0, 0, 0
+	}
+	i := (y*64 + x) * 3
+	return c.Pix[i], c.Pix[i+1], c.Pix[i+2]
+}
+
+// Fill sets every pixel to one color
+func (c *Canvas) Fill(r, g, b uint8) {
+	for i := 0; i < len(c.Pix); i += 3 {
+		c.Pix[i], c.Pix[i+1], c.Pix[i+2] = r, g, b
+	}
+}
+
+// Clear sets every pixel to black
+func (c *Canvas) Clear() {
+	clear(c.Pix)
+}
+
+// CopyFrom replaces the canvas contents with src
+func (c *Canvas) CopyFrom(src *Canvas) {
+	copy(c.Pix, src.Pix)
+}
+
+// DoubleBuffer pairs two canvases: frames are drawn on the back buffer
+// while the front buffer holds the last complete frame, so readers never
+// see a half-drawn frame.
+type DoubleBuffer struct {
+	front, back *Canvas
+}
+
+// NewDoubleBuffer creates two black canvases
+func NewDoubleBuffer() *DoubleBuffer {
+	return &DoubleBuffer{front: NewCanvas(), back: NewCanvas()}
+}
+
+// Back returns the canvas to draw the next frame on
+func (d *DoubleBuffer) Back() *Canvas { return d.back }
+
+// Front returns the last complete frame
+func (d *DoubleBuffer) Front() *Canvas { return d.front }
+
+// Swap makes the back buffer the front one. The new back buffer still
+// holds the frame before, so redraw it fully or Clear it first.
+func (d *DoubleBuffer) Swap() {
+	d.front, d.back = d.back, d.front
+}
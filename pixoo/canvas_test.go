@@ -0,0 +1,124 @@
+package pixoo
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+)
+
+func TestCanvasSetAt(t *testing.T) {
+	tests := []struct {
+		name string
+		x, y int
+		set  color.Color
+		want color.RGBA
+	}{
+		{"origin", 0, 0, color.RGBA{255, 0, 0, 255}, color.RGBA{255, 0, 0, 255}},
+		{"last pixel", 63, 63, color.RGBA{1, 2, 3, 255}, color.RGBA{1, 2, 3, 255}},
+		{"row boundary", 0, 1, color.RGBA{10, 20, 30, 255}, color.RGBA{10, 20, 30, 255}},
+		{"gray", 5, 7, color.Gray{200}, color.RGBA{200, 200, 200, 255}},
+		// Premultiplied half-transparent red lands as dark red, opaque
+		{"alpha ignored", 9, 9, color.RGBA{128, 0, 0, 128}, color.RGBA{128, 0, 0, 255}},
+		{"left of canvas", -1, 0, color.White, color.RGBA{0, 0, 0, 255}},
+		{"below canvas", 0, 64, color.White, color.RGBA{0, 0, 0, 255}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewCanvas()
+			c.Set(tt.x, tt.y, tt.set)
+			if got := c.At(tt.x, tt.y); got != tt.want {
+				t.Errorf("At(%d, %d) = %v, want %v", tt.x, tt.y, got, tt.want)
+			}
+			if tt.want == (color.RGBA{0, 0, 0, 255}) && !bytes.Equal(c.Pix, NewCanvas().Pix) {
+				t.Error("setting outside the canvas changed a pixel")
+			}
+		})
+	}
+
+	c := NewCanvas()
+	c.SetRGB(3, 2, 7, 8, 9)
+	if i := (2*64 + 3) * 3; c.Pix[i] != 7 || c.Pix[i+1] != 8 || c.Pix[i+2] != 9 {
+		t.Errorf("SetRGB(3, 2) wrote %v at offset %d", c.Pix[i:i+3], i)
+	}
+	if r, g, b := c.RGBAt(3, 2); r != 7 || g != 8 || b != 9 {
+		t.Errorf("RGBAt(3, 2) = %d %d %d", r, g, b)
+	}
+}
+
+func TestCanvasFillClearCopy(t *testing.T) {
+	c := NewCanvas()
+	c.Fill(1, 2, 3)
+	if r, g, b := c.RGBAt(63, 0); r != 1 || g != 2 || b != 3 {
+		t.Errorf("after Fill RGBAt = %d %d %d", r, g, b)
+	}
+
+	dst := NewCanvas()
+	dst.CopyFrom(c)
+	c.Clear()
+	if r, g, b := dst.RGBAt(10, 10); r != 1 || g != 2 || b != 3 {
+		t.Error("CopyFrom shares pixels with its source")
+	}
+	if !bytes.Equal(c.Pix, NewCanvas().Pix) {
+		t.Error("Clear left pixels set")
+	}
+}
+
+func TestDoubleBufferSwap(t *testing.T) {
+	db := NewDoubleBuffer()
+	front, back := db.Front(), db.Back()
+	if front == back {
+		t.Fatal("front and back are the same canvas")
+	}
+
+	back.Fill(0, 255, 0)
+	db.Swap()
+	if db.Front() != back || db.Back() != front {
+		t.Fatal("Swap did not exchange the buffers")
+	}
+	if r, g, b := db.Front().RGBAt(0, 0); r != 0 || g != 255 || b != 0 {
+		t.Errorf("front after Swap = %d %d %d, want the frame drawn on the back", r, g, b)
+	}
+
+	// Drawing the next frame leaves the front alone
+	db.Back().Fill(0, 0, 255)
+	if r, g, b := db.Front().RGBAt(0, 0); r != 0 || g != 255 || b != 0 {
+		t.Errorf("front changed to %d %d %d while drawing the back", r, g, b)
+	}
+	db.Swap()
+	if db.Front() != front || db.Back() != back {
+		t.Error("second Swap did not restore the buffers")
+	}
+}
+
+func TestRGBBytesCanvasMatchesRGBA(t *testing.T) {
+	c := NewCanvas()
+	img := image.NewRGBA(image.Rect(0, 0, 64, 64))
+	for y := 0; y < 64; y++ {
+		for x := 0; x < 64; x++ {
+			col := color.RGBA{uint8(x * 4), uint8(y * 4), uint8(x ^ y), 255}
+			c.Set(x, y, col)
+			img.Set(x, y, col)
+		}
+	}
+
+	want := rgbBytes(img)
+	if got := rgbBytes(c); !bytes.Equal(got, want) {
+		t.Error("rgbBytes of a Canvas differs from that of the same *image.RGBA")
+	}
+
+	// An image whose bounds don't start at the origin converts the same
+	shifted := image.NewRGBA(image.Rect(10, 20, 74, 84))
+	draw.Draw(shifted, shifted.Bounds(), img, image.Point{}, draw.Src)
+	if got := rgbBytes(shifted); !bytes.Equal(got, want) {
+		t.Error("rgbBytes of an offset image differs")
+	}
+
+	// Drawing onto a Canvas through image/draw gives the same pixels
+	drawn := NewCanvas()
+	draw.Draw(drawn, drawn.Bounds(), img, image.Point{}, draw.Src)
+	if !bytes.Equal(drawn.Pix, want) {
+		t.Error("draw.Draw onto a Canvas differs from rgbBytes")
+	}
+}
@@ -2,12 +2,17 @@ package pixoo
 
 import (
 	"bytes"
+	"context"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"image"
 	"image/color"
+	"image/draw"
+	"io"
 	"net/http"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -18,101 +23,327 @@ const (
 type Client struct {
 	host       string
 	httpClient *http.Client
+	transport  *transport
+
+	// ctx bounds every call; Close cancels it
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// frames skips uploads of the frame already on screen
+	frames frameDedup
+
+	// recorder, if set, logs every request sent
+	recorder atomic.Pointer[Recorder]
+
+	// mu guards the settings re-applied when the device comes back
+	mu         sync.Mutex
+	brightness int
+	channel    int
 }
 
 func NewClient(host string) *Client {
+	ctx, cancel := context.WithCancel(context.Background())
 	// Use default transport - custom transports can cause permission issues on macOS
-	return &Client{
+	c := &Client{
 		host: host,
 		httpClient: &http.Client{
 			Timeout: 30 * time.Second, // Increased timeout for image uploads
 		},
+		ctx:        ctx,
+		cancel:     cancel,
+		brightness: -1,
+		channel:    -1,
 	}
+	c.transport = newTransport(c.send)
+	c.transport.onReconnect = c.reapply
+	return c
+}
+
+// Stats returns push latency and failure counts per command
+func (c *Client) Stats() Stats {
+	return c.transport.stats.snapshot()
+}
+
+// SetRetryPolicy changes how failed commands are retried
+func (c *Client) SetRetryPolicy(p RetryPolicy) {
+	c.transport.setRetryPolicy(p)
+}
+
+// SetBreaker changes when the client stops contacting an offline device
+func (c *Client) SetBreaker(cfg BreakerConfig) {
+	c.transport.setBreaker(cfg)
 }
 
-func (c *Client) post(command interface{}) error {
+// SetRefreshInterval makes DrawImage re-send an unchanged frame once
+// interval has passed since it was last sent. Until then, and always
+// when interval is 0, identical frames are skipped.
+func (c *Client) SetRefreshInterval(interval time.Duration) {
+	c.frames.setRefresh(interval)
+}
+
+// SetRecorder logs every request sent from now on to r, retries
+// included; nil stops recording. The recorder is not closed by Close.
+func (c *Client) SetRecorder(r *Recorder) {
+	c.recorder.Store(r)
+}
+
+// Close cancels in-flight commands and retries; later calls fail
+func (c *Client) Close() error {
+	c.cancel()
+	return nil
+}
+
+// post sends command, giving up when ctx is done or the client is closed
+func (c *Client) post(ctx context.Context, command interface{}) error {
+	ctx, cancel := withClose(ctx, c.ctx)
+	defer cancel()
+	_, err := c.transport.do(ctx, command)
+	return err
+}
+
+// SendRaw posts a command given as JSON, e.g. from a recording, and
+// returns the device's response
+func (c *Client) SendRaw(command json.RawMessage) ([]byte, error) {
+	return c.SendRawContext(context.Background(), command)
+}
+
+// SendRawContext is SendRaw bounded by ctx. The command may change the
+// screen, so the next DrawImage is always sent.
+func (c *Client) SendRawContext(ctx context.Context, command json.RawMessage) ([]byte, error) {
+	if !json.Valid(command) {
+		return nil, Permanent(fmt.Errorf("command is not valid JSON"))
+	}
+	c.frames.reset()
+	ctx, cancel := withClose(ctx, c.ctx)
+	defer cancel()
+	return c.transport.do(ctx, command)
+}
+
+func (c *Client) send(ctx context.Context, data []byte) (body []byte, err error) {
+	if rec := c.recorder.Load(); rec != nil {
+		start := time.Now()
+		defer func() {
+			rec.record(c.host, start, data, time.Since(start), err)
+		}()
+	}
+
 	url := fmt.Sprintf("http://%s/post", c.host)
 
-	data, err := json.Marshal(command)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
 	if err != nil {
-		return fmt.Errorf("marshal command: %w", err)
+		return nil, permanentError{fmt.Errorf("create request: %w", err)}
 	}
+	req.Header.Set("Content-Type", "application/json")
 
-	resp, err := c.httpClient.Post(url, "application/json", bytes.NewReader(data))
+	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return fmt.Errorf("post request: %w", err)
+		return nil, fmt.Errorf("post request: %w", err)
 	}
 	defer resp.Body.Close()
 
+	body, err = io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("read response: %w", err)
+	}
+
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+		err := fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+		if resp.StatusCode < 500 {
+			return nil, permanentError{err}
+		}
+		return nil, err
 	}
 
-	return nil
+	return body, nil
 }
 
-// SetBrightness sets the screen brightness (0-100)
-func (c *Client) SetBrightness(brightness int) error {
-	if brightness < 0 || brightness > 100 {
-		return fmt.Errorf("brightness must be between 0 and 100")
+// reapply restores the brightness and channel set through this client,
+// which the device forgets when it reboots
+func (c *Client) reapply(ctx context.Context) {
+	c.frames.reset()
+
+	c.mu.Lock()
+	brightness, channel := c.brightness, c.channel
+	c.mu.Unlock()
+
+	if brightness >= 0 {
+		c.transport.do(ctx, brightnessCommand(brightness))
+	}
+	if channel >= 0 {
+		c.transport.do(ctx, channelCommand(channel))
 	}
+}
 
-	command := map[string]interface{}{
-		"Command": "Channel/SetBrightness",
+// CheckReboot asks the device for its settings and re-applies brightness
+// and channel if they no longer match what this client set, which
+// happens when the device rebooted (e.g. after a power cut) without us
+// noticing an outage. It reports whether anything was re-applied.
+func (c *Client) CheckReboot() (bool, error) {
+	return c.CheckRebootContext(context.Background())
+}
+
+// CheckRebootContext is CheckReboot bounded by ctx
+func (c *Client) CheckRebootContext(ctx context.Context) (bool, error) {
+	ctx, cancel := withClose(ctx, c.ctx)
+	defer cancel()
+
+	c.mu.Lock()
+	brightness, channel := c.brightness, c.channel
+	c.mu.Unlock()
+	if brightness < 0 && channel < 0 {
+		return false, nil
+	}
+
+	var conf struct {
+		Brightness  *int `json:"Brightness"`
+		SelectIndex *int `json:"SelectIndex"`
+	}
+	if brightness >= 0 {
+		body, err := c.transport.do(ctx, map[string]interface{}{"Command": "Channel/GetAllConf"})
+		if err != nil {
+			return false, fmt.Errorf("get config: %w", err)
+		}
+		json.Unmarshal(body, &conf)
+	}
+	if channel >= 0 {
+		body, err := c.transport.do(ctx, map[string]interface{}{"Command": "Channel/GetIndex"})
+		if err != nil {
+			return false, fmt.Errorf("get channel: %w", err)
+		}
+		json.Unmarshal(body, &conf)
+	}
+
+	changed := brightness >= 0 && conf.Brightness != nil && *conf.Brightness != brightness ||
+		channel >= 0 && conf.SelectIndex != nil && *conf.SelectIndex != channel
+	if changed {
+		c.reapply(ctx)
+	}
+	return changed, nil
+}
+
+func brightnessCommand(brightness int) map[string]interface{} {
+	return map[string]interface{}{
+		"Command":    "Channel/SetBrightness",
 		"Brightness": brightness,
 	}
+}
 
-	return c.post(command)
+func channelCommand(channel int) map[string]interface{} {
+	return map[string]interface{}{
+		"Command":     "Channel/SetIndex",
+		"SelectIndex": channel,
+	}
+}
+
+// buzzerCommand checks the buzzer cycle times and builds the command
+func buzzerCommand(activeMs, offMs, totalMs int) (map[string]interface{}, error) {
+	if activeMs < 0 || offMs < 0 || totalMs <= 0 {
+		return nil, Permanent(fmt.Errorf("buzzer times must be non-negative with a positive total"))
+	}
+	return map[string]interface{}{
+		"Command":           "Device/PlayBuzzer",
+		"ActiveTimeInCycle": activeMs,
+		"OffTimeInCycle":    offMs,
+		"PlayTotalTime":     totalMs,
+	}, nil
+}
+
+// SetBrightness sets the screen brightness (0-100)
+func (c *Client) SetBrightness(brightness int) error {
+	return c.SetBrightnessContext(context.Background(), brightness)
+}
+
+// SetBrightnessContext is SetBrightness bounded by ctx
+func (c *Client) SetBrightnessContext(ctx context.Context, brightness int) error {
+	if brightness < 0 || brightness > 100 {
+		return Permanent(fmt.Errorf("brightness must be between 0 and 100"))
+	}
+
+	c.mu.Lock()
+	c.brightness = brightness
+	c.mu.Unlock()
+	return c.post(ctx, brightnessCommand(brightness))
 }
 
 // SetChannel switches to a specific channel
 // 0 = Faces/Clock, 1 = Cloud Channel, 2 = Visualizer, 3 = Custom
 func (c *Client) SetChannel(channel int) error {
-	command := map[string]interface{}{
-		"Command":     "Channel/SetIndex",
-		"SelectIndex": channel,
+	return c.SetChannelContext(context.Background(), channel)
+}
+
+// SetChannelContext is SetChannel bounded by ctx
+func (c *Client) SetChannelContext(ctx context.Context, channel int) error {
+	c.mu.Lock()
+	c.channel = channel
+	c.mu.Unlock()
+	c.frames.reset()
+	return c.post(ctx, channelCommand(channel))
+}
+
+// PlayBuzzer sounds the built-in buzzer. It beeps for activeMs, pauses
+// for offMs and repeats that cycle until totalMs has elapsed.
+func (c *Client) PlayBuzzer(activeMs, offMs, totalMs int) error {
+	return c.PlayBuzzerContext(context.Background(), activeMs, offMs, totalMs)
+}
+
+// PlayBuzzerContext is PlayBuzzer bounded by ctx
+func (c *Client) PlayBuzzerContext(ctx context.Context, activeMs, offMs, totalMs int) error {
+	command, err := buzzerCommand(activeMs, offMs, totalMs)
+	if err != nil {
+		return err
 	}
-	return c.post(command)
+	return c.post(ctx, command)
 }
 
 // ClearScreen clears the display
 func (c *Client) ClearScreen() error {
+	return c.ClearScreenContext(context.Background())
+}
+
+// ClearScreenContext is ClearScreen bounded by ctx
+func (c *Client) ClearScreenContext(ctx context.Context) error {
+	c.frames.reset()
 	command := map[string]interface{}{
 		"Command": "Draw/ResetHttpGifId",
 	}
-	return c.post(command)
+	return c.post(ctx, command)
 }
 
 // DrawImage sends a 64x64 image to the display
 // The image is converted to base64-encoded RGB format expected by Pixoo
 func (c *Client) DrawImage(img image.Image) error {
+	return c.DrawImageContext(context.Background(), img)
+}
+
+// DrawImageContext is DrawImage bounded by ctx, which covers both the
+// reset and the upload
+func (c *Client) DrawImageContext(ctx context.Context, img image.Image) error {
 	bounds := img.Bounds()
 	if bounds.Dx() != 64 || bounds.Dy() != 64 {
-		return fmt.Errorf("image must be 64x64 pixels")
+		return Permanent(fmt.Errorf("image must be 64x64 pixels"))
+	}
+
+	// Convert image to RGB bytes (R,G,B,R,G,B,...)
+	// 64x64 pixels * 3 bytes = 12,288 bytes
+	pixelBytes := rgbBytes(img)
+
+	// Nothing to do if the device already shows this frame
+	unchanged, sum := c.frames.unchanged(pixelBytes)
+	if unchanged {
+		c.transport.stats.frameSkipped()
+		return nil
 	}
+	// Whatever happens next, the screen may no longer match the last frame
+	c.frames.reset()
 
 	// Reset GIF state first
 	resetCmd := map[string]interface{}{
 		"Command": "Draw/ResetHttpGifId",
 	}
-	if err := c.post(resetCmd); err != nil {
+	if err := c.post(ctx, resetCmd); err != nil {
 		return fmt.Errorf("reset gif: %w", err)
 	}
 
-	// Convert image to RGB bytes (R,G,B,R,G,B,...)
-	// 64x64 pixels * 3 bytes = 12,288 bytes
-	pixelBytes := make([]byte, 64*64*3)
-	idx := 0
-	for y := 0; y < 64; y++ {
-		for x := 0; x < 64; x++ {
-			r, g, b, _ := img.At(x, y).RGBA()
-			pixelBytes[idx] = byte(r >> 8)     // R
-			pixelBytes[idx+1] = byte(g >> 8)   // G
-			pixelBytes[idx+2] = byte(b >> 8)   // B
-			idx += 3
-		}
-	}
-
 	// Base64 encode the pixel data
 	encodedData := base64.StdEncoding.EncodeToString(pixelBytes)
 
@@ -126,11 +357,73 @@ func (c *Client) DrawImage(img image.Image) error {
 		"PicData":   encodedData,
 	}
 
-	return c.post(command)
+	if err := c.post(ctx, command); err != nil {
+		return err
+	}
+	c.frames.sent(sum)
+	c.transport.stats.frameSent()
+	return nil
+}
+
+// MaxAnimationFrames is the longest animation DrawAnimation uploads; the
+// device accepts fewer than 60 frames
+const MaxAnimationFrames = 59
+
+// DrawAnimation uploads 64x64 frames that the device then plays in a loop
+// by itself, showing each for speed
+func (c *Client) DrawAnimation(frames []image.Image, speed time.Duration) error {
+	return c.DrawAnimationContext(context.Background(), frames, speed)
+}
+
+// DrawAnimationContext is DrawAnimation bounded by ctx, which covers the
+// whole upload
+func (c *Client) DrawAnimationContext(ctx context.Context, frames []image.Image, speed time.Duration) error {
+	if len(frames) == 0 || len(frames) > MaxAnimationFrames {
+		return Permanent(fmt.Errorf("animation must have 1 to %d frames, got %d", MaxAnimationFrames, len(frames)))
+	}
+	for _, img := range frames {
+		if b := img.Bounds(); b.Dx() != 64 || b.Dy() != 64 {
+			return Permanent(fmt.Errorf("image must be 64x64 pixels"))
+		}
+	}
+
+	// The screen no longer matches any single frame
+	c.frames.reset()
+
+	resetCmd := map[string]interface{}{
+		"Command": "Draw/ResetHttpGifId",
+	}
+	if err := c.post(ctx, resetCmd); err != nil {
+		return fmt.Errorf("reset gif: %w", err)
+	}
+
+	// Frames are sent one per request; the device starts playing once
+	// it has all PicNum of them
+	for i, img := range frames {
+		command := map[string]interface{}{
+			"Command":   "Draw/SendHttpGif",
+			"PicID":     1,
+			"PicNum":    len(frames),
+			"PicOffset": i,
+			"PicWidth":  64,
+			"PicSpeed":  max(int(speed/time.Millisecond), 1),
+			"PicData":   base64.StdEncoding.EncodeToString(rgbBytes(img)),
+		}
+		if err := c.post(ctx, command); err != nil {
+			return fmt.Errorf("send frame %d: %w", i, err)
+		}
+	}
+	c.transport.stats.frameSent()
+	return nil
 }
 
 // DrawText displays text on the screen
 func (c *Client) DrawText(text string, r, g, b uint8) error {
+	return c.DrawTextContext(context.Background(), text, r, g, b)
+}
+
+// DrawTextContext is DrawText bounded by ctx
+func (c *Client) DrawTextContext(ctx context.Context, text string, r, g, b uint8) error {
 	command := map[string]interface{}{
 		"Command":    "Draw/SendHttpText",
 		"TextId":     1,
@@ -145,7 +438,47 @@ func (c *Client) DrawText(text string, r, g, b uint8) error {
 		"align":      2,  // 2 = center
 	}
 
-	return c.post(command)
+	return c.post(ctx, command)
+}
+
+// DrawScrollingText overlays a line of text that scrolls right-to-left
+// across the current image at row y. speed is the delay between scroll
+// steps in milliseconds (lower is faster). Overlays stay until ClearText.
+func (c *Client) DrawScrollingText(text string, y int, r, g, b uint8, speed int) error {
+	return c.DrawScrollingTextContext(context.Background(), text, y, r, g, b, speed)
+}
+
+// DrawScrollingTextContext is DrawScrollingText bounded by ctx
+func (c *Client) DrawScrollingTextContext(ctx context.Context, text string, y int, r, g, b uint8, speed int) error {
+	command := map[string]interface{}{
+		"Command":    "Draw/SendHttpText",
+		"TextId":     2,
+		"x":          0,
+		"y":          y,
+		"dir":        0, // 0 = left scroll
+		"font":       2,
+		"TextWidth":  64,
+		"speed":      speed,
+		"TextString": text,
+		"color":      fmt.Sprintf("#%02x%02x%02x", r, g, b),
+		"align":      1, // 1 = left
+	}
+
+	return c.post(ctx, command)
+}
+
+// ClearText removes all text overlays drawn with DrawText or
+// DrawScrollingText
+func (c *Client) ClearText() error {
+	return c.ClearTextContext(context.Background())
+}
+
+// ClearTextContext is ClearText bounded by ctx
+func (c *Client) ClearTextContext(ctx context.Context) error {
+	command := map[string]interface{}{
+		"Command": "Draw/ClearHttpText",
+	}
+	return c.post(ctx, command)
 }
 
 // CreateImage creates a blank 64x64 image
@@ -153,33 +486,36 @@ func CreateImage() *image.RGBA {
 	return image.NewRGBA(image.Rect(0, 0, 64, 64))
 }
 
-// FillRect fills a rectangle on the image
-func FillRect(img *image.RGBA, x1, y1, x2, y2 int, c color.Color) {
-	for y := y1; y < y2 && y < 64; y++ {
-		for x := x1; x < x2 && x < 64; x++ {
+// FillRect fills a rectangle on the image, clipped to its bounds
+func FillRect(img draw.Image, x1, y1, x2, y2 int, c color.Color) {
+	b := img.Bounds()
+	for y := max(y1, b.Min.Y); y < y2 && y < b.Max.Y; y++ {
+		for x := max(x1, b.Min.X); x < x2 && x < b.Max.X; x++ {
 			img.Set(x, y, c)
 		}
 	}
 }
 
 // DrawTextOnImage draws simple text on image (basic 5x7 font)
-func DrawTextOnImage(img *image.RGBA, text string, x, y int, c color.Color) {
+func DrawTextOnImage(img draw.Image, text string, x, y int, c color.Color) {
 	// For now, just a placeholder - you can implement a bitmap font or use a library
 	// This is a simple implementation that draws text at given position
-	for i, ch := range text {
-		drawChar(img, ch, x+i*6, y, c)
+	for _, ch := range text {
+		drawChar(img, ch, x, y, c)
+		x += CharWidth
 	}
 }
 
-func drawChar(img *image.RGBA, ch rune, x, y int, c color.Color) {
+func drawChar(img draw.Image, ch rune, x, y int, c color.Color) {
 	// Simple 5x7 pixel font for basic characters (0-9, A-Z, symbols)
 	// This is a basic implementation - you can expand this
 	patterns := getCharPattern(ch)
+	bounds := img.Bounds()
 
 	for row := 0; row < len(patterns); row++ {
 		for col := 0; col < 5; col++ {
 			if patterns[row]&(1<<(4-col)) != 0 {
-				if x+col < 64 && y+row < 64 && x+col >= 0 && y+row >= 0 {
+				if image.Pt(x+col, y+row).In(bounds) {
 					img.Set(x+col, y+row, c)
 				}
 			}
@@ -188,7 +524,11 @@ func drawChar(img *image.RGBA, ch rune, x, y int, c color.Color) {
 }
 
 func getCharPattern(ch rune) []byte {
-	// 5x7 bitmap font patterns
+	// 5x7 bitmap font patterns; lowercase letters use the uppercase glyphs
+	if ch >= 'a' && ch <= 'z' {
+		ch -= 'a' - 'A'
+	}
+
 	switch ch {
 	case '0':
 		return []byte{0x0E, 0x11, 0x13, 0x15, 0x19, 0x11, 0x0E}
@@ -216,20 +556,82 @@ func getCharPattern(ch rune) []byte {
 		return []byte{0x00, 0x00, 0x0C, 0x0C, 0x00, 0x0C, 0x0C}
 	case ' ':
 		return []byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}
+	case '.':
+		return []byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x0C, 0x0C}
+	case ',':
+		return []byte{0x00, 0x00, 0x00, 0x00, 0x0C, 0x04, 0x08}
+	case '/':
+		return []byte{0x00, 0x01, 0x02, 0x04, 0x08, 0x10, 0x00}
+	case '-':
+		return []byte{0x00, 0x00, 0x00, 0x1F, 0x00, 0x00, 0x00}
+	case '+':
+		return []byte{0x00, 0x04, 0x04, 0x1F, 0x04, 0x04, 0x00}
+	case '_':
+		return []byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x1F}
+	case '=':
+		return []byte{0x00, 0x00, 0x1F, 0x00, 0x1F, 0x00, 0x00}
+	case '<':
+		return []byte{0x02, 0x04, 0x08, 0x10, 0x08, 0x04, 0x02}
+	case '>':
+		return []byte{0x08, 0x04, 0x02, 0x01, 0x02, 0x04, 0x08}
+	case '!':
+		return []byte{0x04, 0x04, 0x04, 0x04, 0x00, 0x00, 0x04}
+	case '?':
+		return []byte{0x0E, 0x11, 0x01, 0x02, 0x04, 0x00, 0x04}
+	case '#':
+		return []byte{0x0A, 0x0A, 0x1F, 0x0A, 0x1F, 0x0A, 0x0A}
+	case 'A':
+		return []byte{0x0E, 0x11, 0x11, 0x11, 0x1F, 0x11, 0x11}
+	case 'B':
+		return []byte{0x1E, 0x11, 0x11, 0x1E, 0x11, 0x11, 0x1E}
 	case 'C':
 		return []byte{0x0E, 0x11, 0x10, 0x10, 0x10, 0x11, 0x0E}
-	case 'P':
-		return []byte{0x1E, 0x11, 0x11, 0x1E, 0x10, 0x10, 0x10}
-	case 'U':
-		return []byte{0x11, 0x11, 0x11, 0x11, 0x11, 0x11, 0x0E}
-	case 'M':
-		return []byte{0x11, 0x1B, 0x15, 0x15, 0x11, 0x11, 0x11}
+	case 'D':
+		return []byte{0x1C, 0x12, 0x11, 0x11, 0x11, 0x12, 0x1C}
 	case 'E':
 		return []byte{0x1F, 0x10, 0x10, 0x1E, 0x10, 0x10, 0x1F}
+	case 'F':
+		return []byte{0x1F, 0x10, 0x10, 0x1E, 0x10, 0x10, 0x10}
 	case 'G':
 		return []byte{0x0E, 0x11, 0x10, 0x17, 0x11, 0x11, 0x0F}
-	case 'B':
-		return []byte{0x1E, 0x11, 0x11, 0x1E, 0x11, 0x11, 0x1E}
+	case 'H':
+		return []byte{0x11, 0x11, 0x11, 0x1F, 0x11, 0x11, 0x11}
+	case 'I':
+		return []byte{0x0E, 0x04, 0x04, 0x04, 0x04, 0x04, 0x0E}
+	case 'J':
+		return []byte{0x07, 0x02, 0x02, 0x02, 0x02, 0x12, 0x0C}
+	case 'K':
+		return []byte{0x11, 0x12, 0x14, 0x18, 0x14, 0x12, 0x11}
+	case 'L':
+		return []byte{0x10, 0x10, 0x10, 0x10, 0x10, 0x10, 0x1F}
+	case 'M':
+		return []byte{0x11, 0x1B, 0x15, 0x15, 0x11, 0x11, 0x11}
+	case 'N':
+		return []byte{0x11, 0x11, 0x19, 0x15, 0x13, 0x11, 0x11}
+	case 'O':
+		return []byte{0x0E, 0x11, 0x11, 0x11, 0x11, 0x11, 0x0E}
+	case 'P':
+		return []byte{0x1E, 0x11, 0x11, 0x1E, 0x10, 0x10, 0x10}
+	case 'Q':
+		return []byte{0x0E, 0x11, 0x11, 0x11, 0x15, 0x12, 0x0D}
+	case 'R':
+		return []byte{0x1E, 0x11, 0x11, 0x1E, 0x14, 0x12, 0x11}
+	case 'S':
+		return []byte{0x0F, 0x10, 0x10, 0x0E, 0x01, 0x01, 0x1E}
+	case 'T':
+		return []byte{0x1F, 0x04, 0x04, 0x04, 0x04, 0x04, 0x04}
+	case 'U':
+		return []byte{0x11, 0x11, 0x11, 0x11, 0x11, 0x11, 0x0E}
+	case 'V':
+		return []byte{0x11, 0x11, 0x11, 0x11, 0x11, 0x0A, 0x04}
+	case 'W':
+		return []byte{0x11, 0x11, 0x11, 0x15, 0x15, 0x15, 0x0A}
+	case 'X':
+		return []byte{0x11, 0x11, 0x0A, 0x04, 0x0A, 0x11, 0x11}
+	case 'Y':
+		return []byte{0x11, 0x11, 0x11, 0x0A, 0x04, 0x04, 0x04}
+	case 'Z':
+		return []byte{0x1F, 0x01, 0x02, 0x04, 0x08, 0x10, 0x1F}
 	default:
 		return []byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}
 	}
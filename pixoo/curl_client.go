@@ -2,76 +2,159 @@ package pixoo
 
 import (
 	"bytes"
-	"encoding/json"
+	"context"
 	"fmt"
 	"image"
 	"os/exec"
+	"strconv"
+	"sync/atomic"
+	"time"
 )
 
 // CurlClient uses curl command to bypass macOS security restrictions
 type CurlClient struct {
-	host string
+	host      string
+	transport *transport
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// brightness is re-applied when the device comes back; -1 if unset
+	brightness atomic.Int64
+
+	frames frameDedup
 }
 
 func NewCurlClient(host string) *CurlClient {
-	return &CurlClient{host: host}
+	ctx, cancel := context.WithCancel(context.Background())
+	c := &CurlClient{host: host, ctx: ctx, cancel: cancel}
+	c.brightness.Store(-1)
+	c.transport = newTransport(c.send)
+	c.transport.onReconnect = func(ctx context.Context) {
+		c.frames.reset()
+		if b := c.brightness.Load(); b >= 0 {
+			c.transport.do(ctx, brightnessCommand(int(b)))
+		}
+	}
+	return c
 }
 
-func (c *CurlClient) post(command interface{}) error {
-	url := fmt.Sprintf("http://%s/post", c.host)
+// Stats returns push latency and failure counts per command
+func (c *CurlClient) Stats() Stats {
+	return c.transport.stats.snapshot()
+}
 
-	data, err := json.Marshal(command)
-	if err != nil {
-		return fmt.Errorf("marshal command: %w", err)
-	}
+// SetRetryPolicy changes how failed commands are retried
+func (c *CurlClient) SetRetryPolicy(p RetryPolicy) {
+	c.transport.setRetryPolicy(p)
+}
+
+// SetBreaker changes when the client stops contacting an offline device
+func (c *CurlClient) SetBreaker(cfg BreakerConfig) {
+	c.transport.setBreaker(cfg)
+}
+
+// SetRefreshInterval makes DrawImage re-send an unchanged frame once
+// interval has passed; see Client.SetRefreshInterval
+func (c *CurlClient) SetRefreshInterval(interval time.Duration) {
+	c.frames.setRefresh(interval)
+}
+
+// Close kills running curl processes and cancels retries
+func (c *CurlClient) Close() error {
+	c.cancel()
+	return nil
+}
+
+// post sends command, killing curl when ctx is done or the client is
+// closed
+func (c *CurlClient) post(ctx context.Context, command interface{}) error {
+	ctx, cancel := withClose(ctx, c.ctx)
+	defer cancel()
+	_, err := c.transport.do(ctx, command)
+	return err
+}
+
+func (c *CurlClient) send(ctx context.Context, data []byte) ([]byte, error) {
+	url := fmt.Sprintf("http://%s/post", c.host)
 
 	// Use curl command which is already trusted by macOS
-	cmd := exec.Command("curl", "-s", "-X", "POST", url,
+	cmd := exec.CommandContext(ctx, "curl", "-s", "-X", "POST", url,
 		"-H", "Content-Type: application/json",
 		"-d", string(data),
-		"-m", "5")
+		"-m", curlTimeout(ctx, 5*time.Second))
 
 	output, err := cmd.CombinedOutput()
 	if err != nil {
-		return fmt.Errorf("curl command: %w (output: %s)", err, string(output))
+		return nil, fmt.Errorf("curl command: %w (output: %s)", err, string(output))
 	}
 
-	return nil
+	return output, nil
 }
 
 func (c *CurlClient) SetBrightness(brightness int) error {
+	return c.SetBrightnessContext(context.Background(), brightness)
+}
+
+// SetBrightnessContext is SetBrightness bounded by ctx
+func (c *CurlClient) SetBrightnessContext(ctx context.Context, brightness int) error {
 	if brightness < 0 || brightness > 100 {
-		return fmt.Errorf("brightness must be between 0 and 100")
+		return Permanent(fmt.Errorf("brightness must be between 0 and 100"))
 	}
 
-	command := map[string]interface{}{
-		"Command":    "Channel/SetBrightness",
-		"Brightness": brightness,
-	}
+	c.brightness.Store(int64(brightness))
+	return c.post(ctx, brightnessCommand(brightness))
+}
 
-	return c.post(command)
+func (c *CurlClient) PlayBuzzer(activeMs, offMs, totalMs int) error {
+	return c.PlayBuzzerContext(context.Background(), activeMs, offMs, totalMs)
+}
+
+// PlayBuzzerContext is PlayBuzzer bounded by ctx
+func (c *CurlClient) PlayBuzzerContext(ctx context.Context, activeMs, offMs, totalMs int) error {
+	command, err := buzzerCommand(activeMs, offMs, totalMs)
+	if err != nil {
+		return err
+	}
+	return c.post(ctx, command)
 }
 
 func (c *CurlClient) ClearScreen() error {
+	return c.ClearScreenContext(context.Background())
+}
+
+// ClearScreenContext is ClearScreen bounded by ctx
+func (c *CurlClient) ClearScreenContext(ctx context.Context) error {
+	c.frames.reset()
 	command := map[string]interface{}{
 		"Command": "Draw/ResetHttpGifId",
 	}
-	return c.post(command)
+	return c.post(ctx, command)
 }
 
 func (c *CurlClient) DrawImage(img image.Image) error {
+	return c.DrawImageContext(context.Background(), img)
+}
+
+// DrawImageContext is DrawImage bounded by ctx
+func (c *CurlClient) DrawImageContext(ctx context.Context, img image.Image) error {
 	bounds := img.Bounds()
 	if bounds.Dx() != 64 || bounds.Dy() != 64 {
-		return fmt.Errorf("image must be 64x64 pixels")
+		return Permanent(fmt.Errorf("image must be 64x64 pixels"))
+	}
+
+	rgb := rgbBytes(img)
+	unchanged, sum := c.frames.unchanged(rgb)
+	if unchanged {
+		c.transport.stats.frameSkipped()
+		return nil
 	}
+	c.frames.reset()
 
 	// Convert image to RGB data
 	pixels := make([]int, 64*64)
-	for y := 0; y < 64; y++ {
-		for x := 0; x < 64; x++ {
-			r, g, b, _ := img.At(x, y).RGBA()
-			pixels[y*64+x] = int((r>>8)<<16 | (g>>8)<<8 | (b >> 8))
-		}
+	for i := range pixels {
+		pixels[i] = int(rgb[i*3])<<16 | int(rgb[i*3+1])<<8 | int(rgb[i*3+2])
 	}
 
 	command := map[string]interface{}{
@@ -84,28 +167,39 @@ func (c *CurlClient) DrawImage(img image.Image) error {
 		"PicData":   pixels,
 	}
 
-	// For large payloads, write to temp file
-	data, err := json.Marshal(command)
-	if err != nil {
-		return fmt.Errorf("marshal command: %w", err)
+	ctx, cancel := withClose(ctx, c.ctx)
+	defer cancel()
+	if _, err := c.transport.doWith(ctx, command, c.sendBinary); err != nil {
+		return err
 	}
+	c.frames.sent(sum)
+	c.transport.stats.frameSent()
+	return nil
+}
 
+// sendBinary posts large payloads through curl's stdin rather than argv
+func (c *CurlClient) sendBinary(ctx context.Context, data []byte) ([]byte, error) {
 	url := fmt.Sprintf("http://%s/post", c.host)
-	cmd := exec.Command("curl", "-s", "-X", "POST", url,
+	cmd := exec.CommandContext(ctx, "curl", "-s", "-X", "POST", url,
 		"-H", "Content-Type: application/json",
 		"--data-binary", "@-",
-		"-m", "10")
+		"-m", curlTimeout(ctx, 10*time.Second))
 
 	cmd.Stdin = bytes.NewReader(data)
 	output, err := cmd.CombinedOutput()
 	if err != nil {
-		return fmt.Errorf("curl command: %w (output: %s)", err, string(output))
+		return nil, fmt.Errorf("curl command: %w (output: %s)", err, string(output))
 	}
 
-	return nil
+	return output, nil
 }
 
 func (c *CurlClient) DrawText(text string, r, g, b uint8) error {
+	return c.DrawTextContext(context.Background(), text, r, g, b)
+}
+
+// DrawTextContext is DrawText bounded by ctx
+func (c *CurlClient) DrawTextContext(ctx context.Context, text string, r, g, b uint8) error {
 	command := map[string]interface{}{
 		"Command":    "Draw/SendHttpText",
 		"TextId":     1,
@@ -120,5 +214,17 @@ func (c *CurlClient) DrawText(text string, r, g, b uint8) error {
 		"align":      1,
 	}
 
-	return c.post(command)
+	return c.post(ctx, command)
+}
+
+// curlTimeout returns curl's -m value: def, or less if ctx expires sooner.
+// exec.CommandContext kills curl on cancellation either way; this just
+// lets curl report the timeout itself.
+func curlTimeout(ctx context.Context, def time.Duration) string {
+	if deadline, ok := ctx.Deadline(); ok {
+		if left := time.Until(deadline); left < def {
+			def = max(left, time.Millisecond)
+		}
+	}
+	return strconv.FormatFloat(def.Seconds(), 'f', 3, 64)
 }
@@ -0,0 +1,76 @@
+package pixoo
+
+import (
+	"hash/fnv"
+	"image"
+	"sync"
+	"time"
+)
+
+// rgbBytes converts a 64x64 image to the R,G,B,R,G,B,... layout the
+// device expects. A Canvas already is in that layout and is returned
+// as is, without copying.
+func rgbBytes(img image.Image) []byte {
+	if c, ok := img.(*Canvas); ok {
+		return c.Pix
+	}
+
+	min := img.Bounds().Min
+	pix := make([]byte, 64*64*3)
+	idx := 0
+	for y := 0; y < 64; y++ {
+		for x := 0; x < 64; x++ {
+			r, g, b, _ := img.At(min.X+x, min.Y+y).RGBA()
+			pix[idx] = byte(r >> 8)
+			pix[idx+1] = byte(g >> 8)
+			pix[idx+2] = byte(b >> 8)
+			idx += 3
+		}
+	}
+	return pix
+}
+
+// frameDedup remembers the last frame a client sent so identical frames
+// can be skipped. Anything else that changes the screen must call reset.
+type frameDedup struct {
+	mu      sync.Mutex
+	hash    uint64
+	valid   bool
+	sentAt  time.Time
+	refresh time.Duration
+}
+
+// unchanged reports whether pix is the frame already on screen and the
+// refresh interval, if any, has not passed yet
+func (d *frameDedup) unchanged(pix []byte) (bool, uint64) {
+	h := fnv.New64a()
+	h.Write(pix)
+	sum := h.Sum64()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if !d.valid || d.hash != sum {
+		return false, sum
+	}
+	return d.refresh <= 0 || time.Since(d.sentAt) < d.refresh, sum
+}
+
+// sent records the frame now on screen
+func (d *frameDedup) sent(sum uint64) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.hash, d.valid, d.sentAt = sum, true, time.Now()
+}
+
+// reset forgets the last frame so the next one is always sent
+func (d *frameDedup) reset() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.valid = false
+}
+
+func (d *frameDedup) setRefresh(interval time.Duration) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.refresh = interval
+}
@@ -0,0 +1,113 @@
+package pixoo
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestFrameDedup(t *testing.T) {
+	a, b := NewCanvas().Pix, NewCanvas().Pix
+	b[0] = 1
+
+	var d frameDedup
+	if unchanged, _ := d.unchanged(a); unchanged {
+		t.Error("first frame reported unchanged")
+	}
+	_, sum := d.unchanged(a)
+	d.sent(sum)
+	if unchanged, _ := d.unchanged(a); !unchanged {
+		t.Error("identical frame not skipped")
+	}
+	if unchanged, _ := d.unchanged(b); unchanged {
+		t.Error("different frame skipped")
+	}
+
+	d.reset()
+	if unchanged, _ := d.unchanged(a); unchanged {
+		t.Error("identical frame skipped after reset")
+	}
+
+	d.sent(sum)
+	d.setRefresh(20 * time.Millisecond)
+	if unchanged, _ := d.unchanged(a); !unchanged {
+		t.Error("identical frame not skipped within the refresh interval")
+	}
+	time.Sleep(30 * time.Millisecond)
+	if unchanged, _ := d.unchanged(a); unchanged {
+		t.Error("identical frame skipped after the refresh interval")
+	}
+}
+
+// uploads counts the frames a device received
+func (d *fakeDevice) uploads() int {
+	n := 0
+	for _, name := range d.commands() {
+		if name == "Draw/SendHttpGif" {
+			n++
+		}
+	}
+	return n
+}
+
+func TestClientSkipsUnchangedFrames(t *testing.T) {
+	dev := newFakeDevice(t)
+	c := dev.client(t, 0, BreakerConfig{})
+	frame := NewCanvas()
+	frame.Fill(10, 20, 30)
+
+	draw := func(want int) {
+		t.Helper()
+		if err := c.DrawImage(frame); err != nil {
+			t.Fatalf("DrawImage: %v", err)
+		}
+		if got := dev.uploads(); got != want {
+			t.Fatalf("device got %d frames, want %d", got, want)
+		}
+	}
+
+	draw(1)
+	draw(1)
+	if st := c.Stats(); st.FramesSent != 1 || st.FramesSkipped != 1 {
+		t.Errorf("stats sent %d skipped %d, want 1 and 1", st.FramesSent, st.FramesSkipped)
+	}
+
+	frame.SetRGB(0, 0, 255, 255, 255)
+	draw(2)
+
+	// Commands that replace the image reset the dedup; text overlays
+	// don't, the frame stays underneath
+	if err := c.DrawText("hi", 255, 255, 255); err != nil {
+		t.Fatal(err)
+	}
+	draw(2)
+	if err := c.ClearScreen(); err != nil {
+		t.Fatal(err)
+	}
+	draw(3)
+	if err := c.SetChannel(3); err != nil {
+		t.Fatal(err)
+	}
+	draw(4)
+	if _, err := c.SendRaw(json.RawMessage(`{"Command":"Channel/GetIndex"}`)); err != nil {
+		t.Fatal(err)
+	}
+	draw(5)
+
+	// A failed upload leaves the screen unknown, so even the frame sent
+	// before it goes out again
+	frame.SetRGB(1, 1, 255, 255, 255)
+	dev.status.Store(500)
+	if err := c.DrawImage(frame); err == nil {
+		t.Fatal("DrawImage succeeded against a failing device")
+	}
+	dev.status.Store(0)
+	frame.SetRGB(1, 1, 10, 20, 30)
+	n := dev.uploads()
+	draw(n + 1)
+
+	c.SetRefreshInterval(20 * time.Millisecond)
+	draw(n + 1)
+	time.Sleep(30 * time.Millisecond)
+	draw(n + 2)
+}
@@ -0,0 +1,313 @@
+package pixoo
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// DefaultCloudURL is the Divoom cloud API that lists devices on the
+// caller's LAN
+const DefaultCloudURL = "https://app.divoom-gz.com"
+
+// AutoHost is the -host value that asks for discovery
+const AutoHost = "auto"
+
+// Device is a Pixoo found on the network
+type Device struct {
+	Name string
+	ID   int
+	MAC  string
+	// Host is the address to pass to NewClient (ip or ip:port)
+	Host string
+}
+
+// CloudClient asks the Divoom cloud which devices share our public IP
+type CloudClient struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+// NewCloudClient creates a client for the cloud API at baseURL, e.g.
+// DefaultCloudURL or a local stand-in
+func NewCloudClient(baseURL string) *CloudClient {
+	return &CloudClient{
+		baseURL:    baseURL,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// cloudResponse is the Device/ReturnSameLANDevice response
+type cloudResponse struct {
+	ReturnCode    int    `json:"ReturnCode"`
+	ReturnMessage string `json:"ReturnMessage"`
+	DeviceList    []struct {
+		DeviceName      string `json:"DeviceName"`
+		DeviceId        int    `json:"DeviceId"`
+		DevicePrivateIP string `json:"DevicePrivateIP"`
+		DeviceMac       string `json:"DeviceMac"`
+	} `json:"DeviceList"`
+}
+
+// LANDevices lists the devices the cloud has seen behind our public IP
+func (c *CloudClient) LANDevices(ctx context.Context) ([]Device, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/Device/ReturnSameLANDevice", bytes.NewReader([]byte("{}")))
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("post request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	var body cloudResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("decode response: %w", err)
+	}
+	if body.ReturnCode != 0 {
+		return nil, fmt.Errorf("cloud error %d: %s", body.ReturnCode, body.ReturnMessage)
+	}
+
+	devices := make([]Device, 0, len(body.DeviceList))
+	for _, d := range body.DeviceList {
+		devices = append(devices, Device{
+			Name: d.DeviceName,
+			ID:   d.DeviceId,
+			MAC:  d.DeviceMac,
+			Host: d.DevicePrivateIP,
+		})
+	}
+	return devices, nil
+}
+
+// Discovery probes subnets for devices answering Channel/GetAllConf
+type Discovery struct {
+	// Subnets to scan; defaults to the IPv4 networks of the local
+	// interfaces, each narrowed to at most a /24
+	Subnets []netip.Prefix
+	// Port the devices listen on (default DefaultPort)
+	Port int
+	// Timeout per probe (default 700ms)
+	Timeout time.Duration
+	// Concurrency is the number of hosts probed at once (default 64)
+	Concurrency int
+}
+
+// Discover scans the local subnets with the default settings
+func Discover(ctx context.Context) ([]Device, error) {
+	return Discovery{}.Run(ctx)
+}
+
+// Run probes every address in the subnets and returns the devices found,
+// ordered by address
+func (d Discovery) Run(ctx context.Context) ([]Device, error) {
+	subnets := d.Subnets
+	if len(subnets) == 0 {
+		var err error
+		if subnets, err = LocalSubnets(); err != nil {
+			return nil, err
+		}
+		if len(subnets) == 0 {
+			return nil, fmt.Errorf("no IPv4 network to scan")
+		}
+	}
+	port := d.Port
+	if port == 0 {
+		port = DefaultPort
+	}
+	timeout := d.Timeout
+	if timeout <= 0 {
+		timeout = 700 * time.Millisecond
+	}
+	concurrency := d.Concurrency
+	if concurrency <= 0 {
+		concurrency = 64
+	}
+
+	client := &http.Client{Timeout: timeout}
+	addrs := make(chan netip.Addr)
+	var (
+		mu    sync.Mutex
+		found []Device
+		wg    sync.WaitGroup
+	)
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for addr := range addrs {
+				host := addr.String()
+				if port != DefaultPort {
+					host = net.JoinHostPort(host, strconv.Itoa(port))
+				}
+				if dev, ok := probe(ctx, client, host); ok {
+					mu.Lock()
+					found = append(found, dev)
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+
+feed:
+	for _, prefix := range subnets {
+		for addr := prefix.Masked().Addr(); prefix.Contains(addr); addr = addr.Next() {
+			if isNetworkOrBroadcast(prefix, addr) {
+				continue
+			}
+			select {
+			case addrs <- addr:
+			case <-ctx.Done():
+				break feed
+			}
+		}
+	}
+	close(addrs)
+	wg.Wait()
+
+	sort.Slice(found, func(i, j int) bool {
+		return hostAddr(found[i].Host).Less(hostAddr(found[j].Host))
+	})
+	return found, ctx.Err()
+}
+
+// isNetworkOrBroadcast reports whether addr is the first or last address
+// of a subnet that has room for hosts in between
+func isNetworkOrBroadcast(prefix netip.Prefix, addr netip.Addr) bool {
+	if prefix.Bits() > 30 {
+		return false
+	}
+	return addr == prefix.Masked().Addr() || !prefix.Contains(addr.Next())
+}
+
+// hostAddr parses the address part of an ip or ip:port host
+func hostAddr(host string) netip.Addr {
+	if ap, err := netip.ParseAddrPort(host); err == nil {
+		return ap.Addr()
+	}
+	addr, _ := netip.ParseAddr(host)
+	return addr
+}
+
+// probe asks host for its configuration; anything that answers like a
+// Pixoo (error_code 0 plus a brightness) counts as one
+func probe(ctx context.Context, client *http.Client, host string) (Device, bool) {
+	body := bytes.NewReader([]byte(`{"Command":"Channel/GetAllConf"}`))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "http://"+host+"/post", body)
+	if err != nil {
+		return Device{}, false
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return Device{}, false
+	}
+	defer resp.Body.Close()
+
+	var conf struct {
+		ErrorCode  *int   `json:"error_code"`
+		Brightness *int   `json:"Brightness"`
+		DeviceName string `json:"DeviceName"`
+		DeviceId   int    `json:"DeviceId"`
+	}
+	if resp.StatusCode != http.StatusOK || json.NewDecoder(resp.Body).Decode(&conf) != nil {
+		return Device{}, false
+	}
+	if conf.ErrorCode == nil || *conf.ErrorCode != 0 || conf.Brightness == nil {
+		return Device{}, false
+	}
+
+	name := conf.DeviceName
+	if name == "" {
+		name = "Pixoo"
+	}
+	return Device{Name: name, ID: conf.DeviceId, Host: host}, true
+}
+
+// LocalSubnets returns the IPv4 networks of the interfaces that are up,
+// skipping loopback. Networks larger than a /24 are narrowed to the /24
+// around the interface address to keep scans short.
+func LocalSubnets() ([]netip.Prefix, error) {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil, fmt.Errorf("list interfaces: %w", err)
+	}
+
+	var subnets []netip.Prefix
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, a := range addrs {
+			ipnet, ok := a.(*net.IPNet)
+			if !ok {
+				continue
+			}
+			addr, ok := netip.AddrFromSlice(ipnet.IP.To4())
+			if !ok {
+				continue
+			}
+			bits, _ := ipnet.Mask.Size()
+			if bits < 24 {
+				bits = 24
+			}
+			subnets = append(subnets, netip.PrefixFrom(addr, bits).Masked())
+		}
+	}
+	return subnets, nil
+}
+
+// ResolveHost returns host unchanged unless it is AutoHost. Then it asks
+// the cloud at cloudURL (skipped when empty) and falls back to scanning
+// the local subnets, returning the first device that answers.
+func ResolveHost(ctx context.Context, host, cloudURL string) (string, error) {
+	return Discovery{}.Resolve(ctx, host, cloudURL)
+}
+
+// Resolve is ResolveHost with d used for the fallback scan
+func (d Discovery) Resolve(ctx context.Context, host, cloudURL string) (string, error) {
+	if host != AutoHost {
+		return host, nil
+	}
+
+	if cloudURL != "" {
+		devices, err := NewCloudClient(cloudURL).LANDevices(ctx)
+		if err == nil {
+			client := &http.Client{Timeout: 2 * time.Second}
+			for _, d := range devices {
+				if _, ok := probe(ctx, client, d.Host); ok {
+					return d.Host, nil
+				}
+			}
+		}
+	}
+
+	devices, err := d.Run(ctx)
+	if len(devices) > 0 {
+		return devices[0].Host, nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("discover devices: %w", err)
+	}
+	return "", fmt.Errorf("no Pixoo found on the local network")
+}
@@ -0,0 +1,199 @@
+package pixoo
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakePixoo answers Channel/GetAllConf like a panel named name
+func fakePixoo(name string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var cmd map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&cmd)
+		if r.URL.Path != "/post" || cmd["Command"] != "Channel/GetAllConf" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprintf(w, `{"error_code":0,"Brightness":80,"DeviceName":%q,"DeviceId":42}`, name)
+	})
+}
+
+// serveAt starts h on addr, e.g. "127.0.0.2:8080", for the test's duration
+func serveAt(t *testing.T, addr string, h http.Handler) {
+	t.Helper()
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		t.Skipf("listen on %s: %v", addr, err)
+	}
+	srv := &httptest.Server{Listener: ln, Config: &http.Server{Handler: h}}
+	srv.Start()
+	t.Cleanup(srv.Close)
+}
+
+// fakePort starts a fake Pixoo on 127.0.0.1 and returns its port
+func fakePort(t *testing.T, name string) int {
+	t.Helper()
+	srv := httptest.NewServer(fakePixoo(name))
+	t.Cleanup(srv.Close)
+	_, port, _ := net.SplitHostPort(srv.Listener.Addr().String())
+	n, _ := strconv.Atoi(port)
+	return n
+}
+
+func TestCloudLANDevices(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		status  int
+		want    []Device
+		wantErr string
+	}{
+		{
+			name: "devices",
+			body: `{"ReturnCode":0,"ReturnMessage":"","DeviceList":[
+				{"DeviceName":"Desk","DeviceId":300001,"DevicePrivateIP":"192.168.1.50","DeviceMac":"a0:b1:c2:d3:e4:f5"},
+				{"DeviceName":"Shelf","DeviceId":300002,"DevicePrivateIP":"192.168.1.51","DeviceMac":"a0:b1:c2:d3:e4:f6"}]}`,
+			want: []Device{
+				{Name: "Desk", ID: 300001, MAC: "a0:b1:c2:d3:e4:f5", Host: "192.168.1.50"},
+				{Name: "Shelf", ID: 300002, MAC: "a0:b1:c2:d3:e4:f6", Host: "192.168.1.51"},
+			},
+		},
+		{name: "empty", body: `{"ReturnCode":0,"DeviceList":[]}`, want: []Device{}},
+		{name: "return code", body: `{"ReturnCode":1,"ReturnMessage":"Request Fail"}`, wantErr: "cloud error 1: Request Fail"},
+		{name: "status", status: http.StatusBadGateway, wantErr: "unexpected status code: 502"},
+		{name: "bad json", body: `{"ReturnCode":`, wantErr: "decode response"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != http.MethodPost || r.URL.Path != "/Device/ReturnSameLANDevice" {
+					t.Errorf("got %s %s", r.Method, r.URL.Path)
+				}
+				if tt.status != 0 {
+					w.WriteHeader(tt.status)
+				}
+				w.Write([]byte(tt.body))
+			}))
+			defer srv.Close()
+
+			got, err := NewCloudClient(srv.URL).LANDevices(context.Background())
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %+v, want %+v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("device %d = %+v, want %+v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestDiscoveryRun(t *testing.T) {
+	port := fakePort(t, "Desk")
+	addr := func(ip string) string { return net.JoinHostPort(ip, strconv.Itoa(port)) }
+	// Other loopback addresses on the same port: a second panel, a web
+	// server that isn't a Pixoo, and a Pixoo reporting an error
+	serveAt(t, addr("127.0.0.3"), fakePixoo("Shelf"))
+	serveAt(t, addr("127.0.0.4"), http.NotFoundHandler())
+	serveAt(t, addr("127.0.0.5"), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"error_code":1}`))
+	}))
+
+	d := Discovery{
+		Subnets: []netip.Prefix{netip.MustParsePrefix("127.0.0.0/29")},
+		Port:    port,
+		Timeout: time.Second,
+	}
+	got, err := d.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Device{
+		{Name: "Desk", ID: 42, Host: addr("127.0.0.1")},
+		{Name: "Shelf", ID: 42, Host: addr("127.0.0.3")},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Errorf("device %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestResolveHost(t *testing.T) {
+	port := fakePort(t, "Desk")
+	scan := Discovery{
+		Subnets: []netip.Prefix{netip.MustParsePrefix("127.0.0.1/32")},
+		Port:    port,
+		Timeout: time.Second,
+	}
+	scanned := net.JoinHostPort("127.0.0.1", strconv.Itoa(port))
+
+	// Lists a device that doesn't answer, so the cloud result is unusable
+	stale := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"ReturnCode":0,"DeviceList":[{"DeviceName":"Gone","DevicePrivateIP":"127.0.0.9:1"}]}`))
+	}))
+	defer stale.Close()
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"ReturnCode":1,"ReturnMessage":"Request Fail"}`))
+	}))
+	defer failing.Close()
+	// Points at a second panel, which should win over the scan
+	cloudPort := fakePort(t, "Shelf")
+	cloudHost := net.JoinHostPort("127.0.0.1", strconv.Itoa(cloudPort))
+	cloud := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"ReturnCode":0,"DeviceList":[{"DeviceName":"Shelf","DevicePrivateIP":%q}]}`, cloudHost)
+	}))
+	defer cloud.Close()
+
+	tests := []struct {
+		name, host, cloudURL, want string
+	}{
+		{name: "explicit host", host: "192.168.1.50", cloudURL: cloud.URL, want: "192.168.1.50"},
+		{name: "cloud", host: AutoHost, cloudURL: cloud.URL, want: cloudHost},
+		{name: "no cloud", host: AutoHost, want: scanned},
+		{name: "cloud error", host: AutoHost, cloudURL: failing.URL, want: scanned},
+		{name: "cloud unreachable", host: AutoHost, cloudURL: "http://127.0.0.1:1", want: scanned},
+		{name: "cloud device offline", host: AutoHost, cloudURL: stale.URL, want: scanned},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := scan.Resolve(context.Background(), tt.host, tt.cloudURL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("resolved %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("nothing found", func(t *testing.T) {
+		empty := scan
+		empty.Port = 1
+		if _, err := empty.Resolve(context.Background(), AutoHost, ""); err == nil {
+			t.Error("resolved a host with no device on the network")
+		}
+	})
+}
@@ -1,11 +1,21 @@
 package pixoo
 
-import "image"
+import (
+	"context"
+	"image"
+)
 
-// PixooClient interface for different client implementations
+// PixooClient interface for different client implementations. The
+// Context variants give up when ctx is done, so a caller can bound each
+// call with a deadline or abort it on shutdown.
 type PixooClient interface {
 	SetBrightness(brightness int) error
 	ClearScreen() error
 	DrawImage(img image.Image) error
 	DrawText(text string, r, g, b uint8) error
+
+	SetBrightnessContext(ctx context.Context, brightness int) error
+	ClearScreenContext(ctx context.Context) error
+	DrawImageContext(ctx context.Context, img image.Image) error
+	DrawTextContext(ctx context.Context, text string, r, g, b uint8) error
 }
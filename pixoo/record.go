@@ -0,0 +1,153 @@
+package pixoo
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// RecordedCommand is one request sent to a device, as stored by a
+// Recorder. Retries are recorded as separate requests.
+type RecordedCommand struct {
+	Time time.Time `json:"time"`
+	Host string    `json:"host,omitempty"`
+	// Command is the JSON body exactly as it was posted
+	Command json.RawMessage `json:"command"`
+	// LatencyMS is how long the device took to answer
+	LatencyMS float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// Name returns the command's "Command" field, e.g. "Draw/SendHttpGif"
+func (rc RecordedCommand) Name() string {
+	return rawCommandName(rc.Command)
+}
+
+func rawCommandName(data []byte) string {
+	var cmd struct{ Command string }
+	if err := json.Unmarshal(data, &cmd); err != nil || cmd.Command == "" {
+		return "unknown"
+	}
+	return cmd.Command
+}
+
+// Recorder writes every request a Client sends to a JSON Lines file, one
+// RecordedCommand per line, so a session can be inspected or replayed
+// later. One Recorder can be shared by several clients.
+type Recorder struct {
+	mu     sync.Mutex
+	w      *bufio.Writer
+	closer io.Closer
+	err    error
+	closed bool
+}
+
+// NewRecorder records to w; Close flushes it but leaves it open
+func NewRecorder(w io.Writer) *Recorder {
+	return &Recorder{w: bufio.NewWriter(w)}
+}
+
+// CreateRecording records to a new file at path, replacing an existing one
+func CreateRecording(path string) (*Recorder, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("create recording: %w", err)
+	}
+	r := NewRecorder(f)
+	r.closer = f
+	return r, nil
+}
+
+// record appends one request. Each line is flushed right away, so a
+// recording is complete up to a crash or a hung device.
+func (r *Recorder) record(host string, start time.Time, data []byte, latency time.Duration, err error) {
+	rc := RecordedCommand{
+		Time:      start,
+		Host:      host,
+		Command:   data,
+		LatencyMS: float64(latency.Microseconds()) / 1000,
+	}
+	if err != nil {
+		rc.Error = err.Error()
+	}
+	line, merr := json.Marshal(rc)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil || r.closed {
+		return
+	}
+	if merr != nil {
+		r.err = fmt.Errorf("encode command: %w", merr)
+		return
+	}
+	r.w.Write(line)
+	r.w.WriteByte('\n')
+	r.err = r.w.Flush()
+}
+
+// Err returns the first error writing the recording; later requests are
+// no longer recorded
+func (r *Recorder) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+// Close flushes the recording, and closes the file if it was created
+// with CreateRecording
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return nil
+	}
+	r.closed = true
+	err := r.err
+	if ferr := r.w.Flush(); err == nil {
+		err = ferr
+	}
+	if r.closer != nil {
+		if cerr := r.closer.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// ReadRecording parses a recording written by a Recorder
+func ReadRecording(rd io.Reader) ([]RecordedCommand, error) {
+	var cmds []RecordedCommand
+	scanner := bufio.NewScanner(rd)
+	// Frames are about 16KB of base64; leave room for larger commands
+	scanner.Buffer(make([]byte, 64*1024), 4<<20)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var rc RecordedCommand
+		if err := json.Unmarshal(scanner.Bytes(), &rc); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		cmds = append(cmds, rc)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read recording: %w", err)
+	}
+	return cmds, nil
+}
+
+// OpenRecording reads the recording at path
+func OpenRecording(path string) ([]RecordedCommand, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open recording: %w", err)
+	}
+	defer f.Close()
+	return ReadRecording(f)
+}
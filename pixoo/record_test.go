@@ -0,0 +1,126 @@
+package pixoo
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"strings"
+	"testing"
+	"time"
+)
+
+// received returns every request body received so far
+func (d *fakeDevice) received() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	out := make([]string, len(d.bodies))
+	for i, b := range d.bodies {
+		out[i] = string(b)
+	}
+	return out
+}
+
+func TestRecordReplayRoundTrip(t *testing.T) {
+	recorded, other := newFakeDevice(t), newFakeDevice(t)
+	var buf bytes.Buffer
+	rec := NewRecorder(&buf)
+
+	c := recorded.client(t, 0, BreakerConfig{})
+	c.SetRecorder(rec)
+	// A second device on the same recording, left out of the replay
+	o := other.client(t, 0, BreakerConfig{})
+	o.SetRecorder(rec)
+
+	frame := image.NewRGBA(image.Rect(0, 0, 64, 64))
+	for i := range frame.Pix {
+		frame.Pix[i] = uint8(i)
+	}
+	steps := []func() error{
+		func() error { return c.SetBrightness(40) },
+		func() error { return c.DrawImage(frame) },
+		func() error { return o.SetBrightness(90) },
+		func() error { return c.DrawText("hello", 255, 128, 0) },
+		func() error { return c.SetChannel(3) },
+		func() error {
+			frame.Set(10, 10, color.RGBA{255, 255, 255, 255})
+			return c.DrawImage(frame)
+		},
+	}
+	for i, step := range steps {
+		if err := step(); err != nil {
+			t.Fatalf("step %d: %v", i, err)
+		}
+	}
+	if err := rec.Close(); err != nil {
+		t.Fatalf("close recorder: %v", err)
+	}
+
+	names := strings.Join(recorded.commands(), " ")
+	if want := "Channel/SetBrightness Draw/ResetHttpGifId Draw/SendHttpGif Draw/SendHttpText Channel/SetIndex Draw/ResetHttpGifId Draw/SendHttpGif"; names != want {
+		t.Fatalf("device got %s, want %s", names, want)
+	}
+
+	cmds, err := ReadRecording(&buf)
+	if err != nil {
+		t.Fatalf("read recording: %v", err)
+	}
+	want := recorded.received()
+	host := strings.TrimPrefix(recorded.srv.URL, "http://")
+	var mine int
+	for _, rc := range cmds {
+		if rc.Host == host {
+			if mine < len(want) && string(rc.Command) != want[mine] {
+				t.Errorf("recorded command %d = %s, want %s", mine, rc.Command, want[mine])
+			}
+			mine++
+		}
+		if rc.Error != "" {
+			t.Errorf("recorded %s with error %q", rc.Name(), rc.Error)
+		}
+	}
+	if mine != len(want) || len(cmds) != mine+len(other.received()) {
+		t.Fatalf("recorded %d commands (%d for %s), device got %d", len(cmds), mine, host, len(want))
+	}
+
+	replayed := newFakeDevice(t)
+	var failures []string
+	start := time.Now()
+	err = Replay(context.Background(), replayed.client(t, 0, BreakerConfig{}), cmds, ReplayOptions{
+		Host: host,
+		OnCommand: func(rc RecordedCommand, err error) {
+			if err != nil {
+				failures = append(failures, rc.Name()+": "+err.Error())
+			}
+		},
+	})
+	if err != nil {
+		t.Fatalf("replay: %v", err)
+	}
+	if len(failures) > 0 {
+		t.Errorf("replay failures: %v", failures)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("replay at speed 0 took %v", elapsed)
+	}
+
+	got := replayed.received()
+	if len(got) != len(want) {
+		t.Fatalf("replayed %d commands %v, want %d %v", len(got), replayed.commands(), len(want), recorded.commands())
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("command %d = %.80s, want %.80s", i, got[i], want[i])
+		}
+	}
+}
+
+func TestReadRecordingErrors(t *testing.T) {
+	cmds, err := ReadRecording(strings.NewReader("\n" + `{"command":{"Command":"Channel/GetIndex"}}` + "\n\n"))
+	if err != nil || len(cmds) != 1 || cmds[0].Name() != "Channel/GetIndex" {
+		t.Fatalf("got %v, %v", cmds, err)
+	}
+	if _, err := ReadRecording(strings.NewReader(`{"command":{}}` + "\nnot json\n")); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("err = %v, want one naming line 2", err)
+	}
+}
@@ -0,0 +1,168 @@
+package pixoo
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// ReplayOptions controls Replay
+type ReplayOptions struct {
+	// Speed scales the recorded pauses between commands: 2 replays twice
+	// as fast, and 0 sends the commands back to back
+	Speed float64
+	// Host, if set, only replays the commands recorded for that device
+	Host string
+	// OnCommand, if set, is called after each command with its result
+	OnCommand func(rc RecordedCommand, err error)
+}
+
+// rawSender is implemented by clients that can post recorded commands
+// as they are, like Client
+type rawSender interface {
+	SendRawContext(ctx context.Context, command json.RawMessage) ([]byte, error)
+}
+
+// Replay sends recorded commands to c, keeping their original timing. A
+// Client gets the exact JSON; other clients, such as simulators and file
+// outputs, get the equivalent calls (see ApplyCommand). Failed commands
+// are reported to opts.OnCommand and do not stop the replay; only ctx
+// does.
+func Replay(ctx context.Context, c PixooClient, cmds []RecordedCommand, opts ReplayOptions) error {
+	var selected []RecordedCommand
+	for _, rc := range cmds {
+		if opts.Host == "" || rc.Host == opts.Host {
+			selected = append(selected, rc)
+		}
+	}
+	if len(selected) == 0 {
+		return nil
+	}
+
+	raw, isRaw := c.(rawSender)
+	start, first := time.Now(), selected[0].Time
+	for i, rc := range selected {
+		if opts.Speed > 0 {
+			due := start.Add(time.Duration(float64(rc.Time.Sub(first)) / opts.Speed))
+			if wait := time.Until(due); wait > 0 {
+				select {
+				case <-time.After(wait):
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			}
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		var err error
+		switch {
+		case isRaw:
+			_, err = raw.SendRawContext(ctx, rc.Command)
+		case rc.Name() == "Draw/ResetHttpGifId" && i+1 < len(selected) && selected[i+1].Name() == "Draw/SendHttpGif":
+			// The reset that starts every image upload; clearing the
+			// screen for it would flash black between frames
+		default:
+			err = ApplyCommand(ctx, c, rc.Command)
+		}
+		if opts.OnCommand != nil {
+			opts.OnCommand(rc, err)
+		}
+	}
+	return nil
+}
+
+// ApplyCommand performs a device command on c through the PixooClient
+// methods, so recordings can be replayed on clients that do not speak the
+// device protocol. Images, text, brightness and clearing are supported;
+// channel changes, scrolling text and the buzzer only on clients with
+// those methods. Queries are ignored. Of an animation, only the first
+// frame is shown.
+func ApplyCommand(ctx context.Context, c PixooClient, command json.RawMessage) error {
+	var cmd struct {
+		Command     string
+		PicOffset   int
+		PicData     string
+		Brightness  int
+		SelectIndex int
+		TextString  string
+		Y           int
+		Speed       int
+		Color       string
+
+		ActiveTimeInCycle int
+		OffTimeInCycle    int
+		PlayTotalTime     int
+	}
+	if err := json.Unmarshal(command, &cmd); err != nil {
+		return fmt.Errorf("decode command: %w", err)
+	}
+
+	switch cmd.Command {
+	case "Draw/SendHttpGif":
+		if cmd.PicOffset != 0 {
+			return nil
+		}
+		pix, err := base64.StdEncoding.DecodeString(cmd.PicData)
+		if err != nil {
+			return fmt.Errorf("decode image: %w", err)
+		}
+		if len(pix) != 64*64*3 {
+			return fmt.Errorf("image has %d bytes, want %d", len(pix), 64*64*3)
+		}
+		return c.DrawImageContext(ctx, &Canvas{Pix: pix})
+
+	case "Draw/ResetHttpGifId":
+		return c.ClearScreenContext(ctx)
+
+	case "Draw/SendHttpText":
+		var r, g, b uint8
+		if _, err := fmt.Sscanf(cmd.Color, "#%02x%02x%02x", &r, &g, &b); err != nil {
+			return fmt.Errorf("invalid text color %q", cmd.Color)
+		}
+		// Static text replaces the screen; an overlay needs a text layer
+		if cmd.Speed == 0 {
+			return c.DrawTextContext(ctx, cmd.TextString, r, g, b)
+		}
+		if o, ok := c.(interface {
+			DrawScrollingTextContext(ctx context.Context, text string, y int, r, g, b uint8, speed int) error
+		}); ok {
+			return o.DrawScrollingTextContext(ctx, cmd.TextString, cmd.Y, r, g, b, cmd.Speed)
+		}
+		return nil
+
+	case "Draw/ClearHttpText":
+		if o, ok := c.(interface {
+			ClearTextContext(ctx context.Context) error
+		}); ok {
+			return o.ClearTextContext(ctx)
+		}
+		return nil
+
+	case "Channel/SetBrightness":
+		return c.SetBrightnessContext(ctx, cmd.Brightness)
+
+	case "Channel/SetIndex":
+		if sc, ok := c.(interface {
+			SetChannelContext(ctx context.Context, channel int) error
+		}); ok {
+			return sc.SetChannelContext(ctx, cmd.SelectIndex)
+		}
+		return nil
+
+	case "Device/PlayBuzzer":
+		if bz, ok := c.(interface {
+			PlayBuzzerContext(ctx context.Context, activeMs, offMs, totalMs int) error
+		}); ok {
+			return bz.PlayBuzzerContext(ctx, cmd.ActiveTimeInCycle, cmd.OffTimeInCycle, cmd.PlayTotalTime)
+		}
+		return nil
+
+	case "Channel/GetAllConf", "Channel/GetIndex":
+		return nil
+	}
+	return fmt.Errorf("unsupported command %q", cmd.Command)
+}
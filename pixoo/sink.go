@@ -0,0 +1,197 @@
+package pixoo
+
+import (
+	"context"
+	"image"
+	"sync"
+	"time"
+)
+
+// DefaultMaxFPS is a frame rate the Pixoo 64 keeps up with over HTTP;
+// faster uploads make it stall
+const DefaultMaxFPS = 4
+
+// fpsWindow is how far back SinkStats.FPS looks
+const fpsWindow = 5 * time.Second
+
+// SinkStats reports how a FrameSink keeps up with its producer
+type SinkStats struct {
+	// Sent counts frames uploaded, Dropped those replaced by a newer
+	// frame before they could be sent
+	Sent    uint64
+	Dropped uint64
+	// FPS is the achieved upload rate over the last few seconds
+	FPS float64
+	// Errors counts failed uploads; LastError is the latest one
+	Errors    uint64
+	LastError error
+}
+
+// FrameSink decouples a frame producer from the device. Send never
+// blocks: the sink uploads at most maxFPS frames per second in the
+// background and, when the device falls behind, skips to the newest
+// frame instead of queueing stale ones.
+type FrameSink struct {
+	client   PixooClient
+	interval time.Duration
+	timeout  time.Duration
+	wake     chan struct{}
+	ctx      context.Context
+	cancel   context.CancelFunc
+	done     chan struct{}
+
+	mu      sync.Mutex
+	pending image.Image
+	// spare holds canvases to copy frames into, reused once sent
+	spare  []*Canvas
+	sentAt []time.Time
+	stats  SinkStats
+}
+
+// NewFrameSink starts a sink that uploads to client at up to maxFPS
+// frames per second (DefaultMaxFPS if maxFPS <= 0)
+func NewFrameSink(client PixooClient, maxFPS float64) *FrameSink {
+	if maxFPS <= 0 {
+		maxFPS = DefaultMaxFPS
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &FrameSink{
+		client:   client,
+		interval: time.Duration(float64(time.Second) / maxFPS),
+		timeout:  10 * time.Second,
+		wake:     make(chan struct{}, 1),
+		ctx:      ctx,
+		cancel:   cancel,
+		done:     make(chan struct{}),
+	}
+	go s.run()
+	return s
+}
+
+// Send queues img as the next frame, replacing a frame still waiting. A
+// Canvas is copied, so the caller can draw the next frame on it right
+// away; any other image must not be modified afterwards.
+func (s *FrameSink) Send(img image.Image) {
+	s.mu.Lock()
+	if c, ok := img.(*Canvas); ok {
+		img = s.copyLocked(c)
+	}
+	if s.pending != nil {
+		s.stats.Dropped++
+		s.recycleLocked(s.pending)
+	}
+	s.pending = img
+	s.mu.Unlock()
+
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Stats returns the sink's counters and achieved frame rate
+func (s *FrameSink) Stats() SinkStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st := s.stats
+	s.pruneLocked(time.Now())
+	if n := len(s.sentAt); n > 1 {
+		if span := s.sentAt[n-1].Sub(s.sentAt[0]); span > 0 {
+			st.FPS = float64(n-1) / span.Seconds()
+		}
+	}
+	return st
+}
+
+// Close stops the sink, aborting an upload in flight and dropping a
+// frame not sent yet
+func (s *FrameSink) Close() {
+	s.cancel()
+	<-s.done
+}
+
+func (s *FrameSink) run() {
+	defer close(s.done)
+
+	var last time.Time
+	for {
+		select {
+		case <-s.wake:
+		case <-s.ctx.Done():
+			return
+		}
+
+		// Hold off until the device is due another frame; anything sent
+		// meanwhile replaces the pending frame
+		if wait := s.interval - time.Since(last); wait > 0 {
+			select {
+			case <-time.After(wait):
+			case <-s.ctx.Done():
+				return
+			}
+		}
+
+		s.mu.Lock()
+		img := s.pending
+		s.pending = nil
+		s.mu.Unlock()
+		if img == nil {
+			continue
+		}
+
+		last = time.Now()
+		ctx, cancel := context.WithTimeout(s.ctx, s.timeout)
+		err := s.client.DrawImageContext(ctx, img)
+		cancel()
+		if s.ctx.Err() != nil {
+			return
+		}
+		s.sent(img, err)
+	}
+}
+
+// copyLocked copies c into a canvas owned by the sink
+func (s *FrameSink) copyLocked(c *Canvas) *Canvas {
+	var buf *Canvas
+	if n := len(s.spare); n > 0 {
+		buf, s.spare = s.spare[n-1], s.spare[:n-1]
+	} else {
+		buf = NewCanvas()
+	}
+	buf.CopyFrom(c)
+	return buf
+}
+
+// recycleLocked keeps a frame's canvas for reuse; every canvas queued is
+// one of ours since Send copies them
+func (s *FrameSink) recycleLocked(img image.Image) {
+	if c, ok := img.(*Canvas); ok {
+		s.spare = append(s.spare, c)
+	}
+}
+
+func (s *FrameSink) sent(img image.Image, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.recycleLocked(img)
+	if err != nil {
+		s.stats.Errors++
+		s.stats.LastError = err
+		return
+	}
+	now := time.Now()
+	s.stats.Sent++
+	s.sentAt = append(s.sentAt, now)
+	s.pruneLocked(now)
+}
+
+// pruneLocked forgets send times older than fpsWindow
+func (s *FrameSink) pruneLocked(now time.Time) {
+	i := 0
+	for i < len(s.sentAt) && now.Sub(s.sentAt[i]) > fpsWindow {
+		i++
+	}
+	s.sentAt = s.sentAt[i:]
+}
@@ -0,0 +1,163 @@
+package pixoo
+
+import (
+	"context"
+	"errors"
+	"image"
+	"sync"
+	"testing"
+	"time"
+)
+
+// captureClient records the frames a FrameSink draws. Each draw waits
+// for release when it is set, and the pixels are captured only after
+// that, so a frame aliasing the caller's canvas would show later edits.
+type captureClient struct {
+	started chan struct{}
+	release chan struct{}
+	err     error
+
+	mu     sync.Mutex
+	frames []*Canvas
+	images []image.Image
+}
+
+func newCaptureClient() *captureClient {
+	return &captureClient{started: make(chan struct{}, 16)}
+}
+
+func (c *captureClient) DrawImageContext(ctx context.Context, img image.Image) error {
+	c.started <- struct{}{}
+	if c.release != nil {
+		select {
+		case <-c.release:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	frame := NewCanvas()
+	copy(frame.Pix, rgbBytes(img))
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.frames = append(c.frames, frame)
+	c.images = append(c.images, img)
+	return c.err
+}
+
+func (c *captureClient) DrawImage(img image.Image) error {
+	return c.DrawImageContext(context.Background(), img)
+}
+func (c *captureClient) SetBrightness(int) error                         { return nil }
+func (c *captureClient) SetBrightnessContext(context.Context, int) error { return nil }
+func (c *captureClient) ClearScreen() error                              { return nil }
+func (c *captureClient) ClearScreenContext(context.Context) error        { return nil }
+func (c *captureClient) DrawText(string, uint8, uint8, uint8) error      { return nil }
+func (c *captureClient) DrawTextContext(context.Context, string, uint8, uint8, uint8) error {
+	return nil
+}
+
+// waitSent waits until the sink has uploaded or failed n frames
+func waitSent(t *testing.T, s *FrameSink, n uint64) SinkStats {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		st := s.Stats()
+		if st.Sent+st.Errors >= n {
+			return st
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("sink handled %d frames, want %d", st.Sent+st.Errors, n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestFrameSinkCoalescesToLatest(t *testing.T) {
+	client := newCaptureClient()
+	client.release = make(chan struct{})
+	sink := NewFrameSink(client, 1000)
+	defer sink.Close()
+
+	canvas := NewCanvas()
+	canvas.Fill(1, 0, 0)
+	sink.Send(canvas)
+	<-client.started // the first frame is uploading and stuck
+
+	// The caller keeps drawing on the same canvas while the device is busy
+	for v := uint8(2); v <= 4; v++ {
+		canvas.Fill(v, 0, 0)
+		sink.Send(canvas)
+	}
+	canvas.Fill(99, 0, 0)
+	close(client.release)
+
+	st := waitSent(t, sink, 2)
+	if st.Sent != 2 || st.Dropped != 2 || st.Errors != 0 {
+		t.Errorf("stats %+v, want 2 sent and 2 dropped", st)
+	}
+
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	var got []uint8
+	for i, f := range client.frames {
+		got = append(got, f.Pix[0])
+		if client.images[i] == image.Image(canvas) {
+			t.Errorf("frame %d is the caller's canvas, not a copy", i)
+		}
+	}
+	if len(got) != 2 || got[0] != 1 || got[1] != 4 {
+		t.Errorf("device got frames %v, want [1 4]", got)
+	}
+}
+
+func TestFrameSinkLimitsRate(t *testing.T) {
+	client := newCaptureClient()
+	sink := NewFrameSink(client, 20)
+	defer sink.Close()
+
+	start := time.Now()
+	canvas := NewCanvas()
+	for i := 0; i < 3; i++ {
+		canvas.Fill(uint8(i), 0, 0)
+		sink.Send(canvas)
+		waitSent(t, sink, uint64(i+1))
+	}
+	// Three frames at 20fps are two 50ms intervals apart
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("3 frames took %v, want at least 100ms at 20fps", elapsed)
+	}
+}
+
+func TestFrameSinkCountsErrors(t *testing.T) {
+	client := newCaptureClient()
+	client.err = errors.New("device unreachable")
+	sink := NewFrameSink(client, 1000)
+	defer sink.Close()
+
+	sink.Send(NewCanvas())
+	st := waitSent(t, sink, 1)
+	if st.Errors != 1 || st.Sent != 0 || !errors.Is(st.LastError, client.err) {
+		t.Errorf("stats %+v, want one error", st)
+	}
+}
+
+func TestFrameSinkCloseAbortsUpload(t *testing.T) {
+	client := newCaptureClient()
+	client.release = make(chan struct{})
+	sink := NewFrameSink(client, 1000)
+
+	sink.Send(NewCanvas())
+	<-client.started
+
+	done := make(chan struct{})
+	go func() {
+		sink.Close()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Close waited for a stuck upload")
+	}
+}
@@ -0,0 +1,112 @@
+package pixoo
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// LatencyBounds are the upper bounds of the push latency histogram
+var LatencyBounds = []time.Duration{
+	5 * time.Millisecond, 10 * time.Millisecond, 25 * time.Millisecond, 50 * time.Millisecond,
+	100 * time.Millisecond, 250 * time.Millisecond, 500 * time.Millisecond,
+	time.Second, 2500 * time.Millisecond, 5 * time.Second, 10 * time.Second,
+}
+
+// CommandStats aggregates the requests sent for one device command
+type CommandStats struct {
+	Requests    uint64
+	Failures    uint64
+	LatencySum  time.Duration
+	LastLatency time.Duration
+	// LatencyBuckets counts the requests that took at most the matching
+	// LatencyBounds entry, so the counts are cumulative
+	LatencyBuckets []uint64
+}
+
+// Stats is a point-in-time copy of a client's push statistics
+type Stats struct {
+	Commands      map[string]CommandStats
+	FramesSent    uint64
+	FramesSkipped uint64 // unchanged frames that were not uploaded
+	LastSuccess   time.Time
+}
+
+// statsRecorder accumulates per-command push statistics for a client
+type statsRecorder struct {
+	mu            sync.Mutex
+	commands      map[string]CommandStats
+	framesSent    uint64
+	framesSkipped uint64
+	lastSuccess   time.Time
+}
+
+func newStatsRecorder() *statsRecorder {
+	return &statsRecorder{commands: make(map[string]CommandStats)}
+}
+
+func (s *statsRecorder) record(command string, latency time.Duration, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cs := s.commands[command]
+	cs.Requests++
+	cs.LatencySum += latency
+	cs.LastLatency = latency
+	if cs.LatencyBuckets == nil {
+		cs.LatencyBuckets = make([]uint64, len(LatencyBounds))
+	}
+	for i, bound := range LatencyBounds {
+		if latency <= bound {
+			cs.LatencyBuckets[i]++
+		}
+	}
+	if err != nil {
+		cs.Failures++
+	} else {
+		s.lastSuccess = time.Now()
+	}
+	s.commands[command] = cs
+}
+
+func (s *statsRecorder) frameSent() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.framesSent++
+}
+
+func (s *statsRecorder) frameSkipped() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.framesSkipped++
+}
+
+func (s *statsRecorder) snapshot() Stats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	commands := make(map[string]CommandStats, len(s.commands))
+	for name, cs := range s.commands {
+		cs.LatencyBuckets = append([]uint64(nil), cs.LatencyBuckets...)
+		commands[name] = cs
+	}
+	return Stats{
+		Commands:      commands,
+		FramesSent:    s.framesSent,
+		FramesSkipped: s.framesSkipped,
+		LastSuccess:   s.lastSuccess,
+	}
+}
+
+// commandName extracts the "Command" field from a request payload
+func commandName(command interface{}) string {
+	switch cmd := command.(type) {
+	case map[string]interface{}:
+		if name, ok := cmd["Command"].(string); ok {
+			return name
+		}
+	case json.RawMessage:
+		return rawCommandName(cmd)
+	}
+	return "unknown"
+}
@@ -0,0 +1,88 @@
+package pixoo
+
+import (
+	"image"
+	"image/color"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// CharWidth is the horizontal advance of the built-in 5x7 font
+	CharWidth = 6
+	// LineHeight is the vertical advance of the built-in 5x7 font
+	LineHeight = 9
+	// CharsPerLine is how many characters fit across the panel
+	CharsPerLine = 64 / CharWidth
+)
+
+// WrapText splits text into lines of at most CharsPerLine characters,
+// breaking on spaces where possible
+func WrapText(text string) []string {
+	var lines []string
+	var line string
+
+	for _, word := range strings.Fields(text) {
+		for utf8.RuneCountInString(word) > CharsPerLine {
+			if line != "" {
+				lines = append(lines, line)
+				line = ""
+			}
+			head := Truncate(word, CharsPerLine)
+			lines = append(lines, head)
+			word = word[len(head):]
+		}
+
+		switch {
+		case line == "":
+			line = word
+		case utf8.RuneCountInString(line)+1+utf8.RuneCountInString(word) <= CharsPerLine:
+			line += " " + word
+		default:
+			lines = append(lines, line)
+			line = word
+		}
+	}
+	if line != "" {
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// Truncate returns the first n characters of s, never splitting a
+// multi-byte character
+func Truncate(s string, n int) string {
+	i := 0
+	for j := range s {
+		if i == n {
+			return s[:j]
+		}
+		i++
+	}
+	return s
+}
+
+// TextWidth is how many pixels text takes in the built-in font
+func TextWidth(text string) int {
+	return utf8.RuneCountInString(text) * CharWidth
+}
+
+// RenderText draws text wrapped and centered on a black 64x64 image with
+// the built-in font. Lines that don't fit are dropped.
+func RenderText(text string, c color.Color) *image.RGBA {
+	img := CreateImage()
+	FillRect(img, 0, 0, 64, 64, color.RGBA{0, 0, 0, 255})
+
+	lines := WrapText(text)
+	if max := 64 / LineHeight; len(lines) > max {
+		lines = lines[:max]
+	}
+
+	y := (64 - len(lines)*LineHeight) / 2
+	for _, line := range lines {
+		x := (64 - TextWidth(line) + 1) / 2
+		DrawTextOnImage(img, line, x, y+1, c)
+		y += LineHeight
+	}
+	return img
+}
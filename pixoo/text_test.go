@@ -0,0 +1,54 @@
+package pixoo
+
+import (
+	"slices"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestWrapText(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"", nil},
+		{"Build passed", []string{"Build", "passed"}},
+		{"CPU at 97%", []string{"CPU at 97%"}},
+		{"supercalifragilistic", []string{"supercalif", "ragilistic"}},
+		{"Grüße aus Köln", []string{"Grüße aus", "Köln"}},
+		{"äääääääääääää", []string{"ääääääääää", "äää"}},
+		{"ok 日本語のメッセージです", []string{"ok", "日本語のメッセージで", "す"}},
+		{"✓✓✓✓✓✓✓✓✓✓✓ done", []string{"✓✓✓✓✓✓✓✓✓✓", "✓ done"}},
+	}
+	for _, tt := range tests {
+		got := WrapText(tt.text)
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("WrapText(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+		for _, line := range got {
+			if !utf8.ValidString(line) || utf8.RuneCountInString(line) > CharsPerLine {
+				t.Errorf("WrapText(%q) line %q is invalid or too long", tt.text, line)
+			}
+		}
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		s    string
+		n    int
+		want string
+	}{
+		{"short", 10, "short"},
+		{"exactly10!", 10, "exactly10!"},
+		{"feature/long-branch", 10, "feature/lo"},
+		{"über-lange-zeile", 5, "über-"},
+		{"日本語", 2, "日本"},
+		{"abc", 0, ""},
+	}
+	for _, tt := range tests {
+		if got := Truncate(tt.s, tt.n); got != tt.want {
+			t.Errorf("Truncate(%q, %d) = %q, want %q", tt.s, tt.n, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,239 @@
+package pixoo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// ErrOffline is returned without contacting the device while the circuit
+// breaker is open
+var ErrOffline = errors.New("device offline (circuit open)")
+
+// RetryPolicy controls how failed commands are retried. Delays grow
+// exponentially from BaseDelay up to MaxDelay, each randomized by
+// +/- Jitter (a fraction of the delay).
+type RetryPolicy struct {
+	MaxRetries int
+	BaseDelay  time.Duration
+	MaxDelay   time.Duration
+	Jitter     float64
+}
+
+// DefaultRetryPolicy retries twice, quickly enough for an interactive
+// display
+var DefaultRetryPolicy = RetryPolicy{
+	MaxRetries: 2,
+	BaseDelay:  250 * time.Millisecond,
+	MaxDelay:   5 * time.Second,
+	Jitter:     0.2,
+}
+
+// delay returns the backoff before retry number attempt (starting at 1)
+func (p RetryPolicy) delay(attempt int) time.Duration {
+	d := p.BaseDelay
+	for i := 1; i < attempt && d < p.MaxDelay; i++ {
+		d *= 2
+	}
+	if p.MaxDelay > 0 && d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	if p.Jitter > 0 {
+		d += time.Duration((rand.Float64()*2 - 1) * p.Jitter * float64(d))
+	}
+	return d
+}
+
+// BreakerConfig controls the circuit breaker. After Threshold consecutive
+// failed commands the device is considered offline: commands fail fast
+// with ErrOffline until Cooldown has passed, then one trial command is
+// let through. A Threshold of 0 disables the breaker.
+type BreakerConfig struct {
+	Threshold int
+	Cooldown  time.Duration
+}
+
+// DefaultBreakerConfig opens after 5 failed commands for 15s
+var DefaultBreakerConfig = BreakerConfig{Threshold: 5, Cooldown: 15 * time.Second}
+
+// permanentError marks failures that retrying cannot fix
+type permanentError struct{ err error }
+
+func (e permanentError) Error() string { return e.err.Error() }
+func (e permanentError) Unwrap() error { return e.err }
+
+// Permanent marks err as a failure that retrying cannot fix, like an
+// invalid argument or a command the device rejected
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return permanentError{err}
+}
+
+// IsPermanent reports whether err was marked with Permanent, so the
+// caller should give up instead of retrying
+func IsPermanent(err error) bool {
+	var permanent permanentError
+	return errors.As(err, &permanent)
+}
+
+// withClose returns a context that is done when ctx is done or closed
+// is cancelled, tying a call to the lifetime of its client
+func withClose(ctx, closed context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(ctx)
+	stop := context.AfterFunc(closed, cancel)
+	return ctx, func() {
+		stop()
+		cancel()
+	}
+}
+
+// sendFunc performs one attempt of a command and returns the response body
+type sendFunc func(ctx context.Context, data []byte) ([]byte, error)
+
+// transport sends commands with retries and a circuit breaker, records
+// stats, and notices when an unreachable device comes back
+type transport struct {
+	stats *statsRecorder
+	send  sendFunc
+
+	mu          sync.Mutex
+	retry       RetryPolicy
+	breaker     BreakerConfig
+	failures    int
+	openUntil   time.Time
+	trial       bool
+	unreachable bool
+	onReconnect func(ctx context.Context)
+}
+
+func newTransport(send sendFunc) *transport {
+	return &transport{
+		stats:   newStatsRecorder(),
+		send:    send,
+		retry:   DefaultRetryPolicy,
+		breaker: DefaultBreakerConfig,
+	}
+}
+
+func (t *transport) setRetryPolicy(p RetryPolicy) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.retry = p
+}
+
+func (t *transport) setBreaker(cfg BreakerConfig) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.breaker = cfg
+	t.failures, t.openUntil, t.trial = 0, time.Time{}, false
+}
+
+// do sends command with the default send function
+func (t *transport) do(ctx context.Context, command interface{}) ([]byte, error) {
+	return t.doWith(ctx, command, t.send)
+}
+
+// doWith marshals command and sends it, retrying transient failures.
+// Every attempt is recorded in the stats.
+func (t *transport) doWith(ctx context.Context, command interface{}, send sendFunc) ([]byte, error) {
+	data, err := json.Marshal(command)
+	if err != nil {
+		return nil, Permanent(fmt.Errorf("marshal command: %w", err))
+	}
+	if err := t.allow(); err != nil {
+		return nil, err
+	}
+
+	t.mu.Lock()
+	policy := t.retry
+	t.mu.Unlock()
+
+	name := commandName(command)
+	for attempt := 0; ; attempt++ {
+		start := time.Now()
+		body, err := send(ctx, data)
+		t.stats.record(name, time.Since(start), err)
+		if err == nil {
+			t.responded(ctx)
+			return body, nil
+		}
+
+		// The device answered, it just rejected the command
+		if IsPermanent(err) {
+			t.responded(ctx)
+			return nil, err
+		}
+		// The caller gave up, which says nothing about the device
+		if ctx.Err() != nil {
+			t.canceled()
+			return nil, ctx.Err()
+		}
+		if attempt >= policy.MaxRetries {
+			t.failed()
+			return nil, err
+		}
+
+		select {
+		case <-time.After(policy.delay(attempt + 1)):
+		case <-ctx.Done():
+			t.canceled()
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// allow fails fast while the breaker is open and lets a single trial
+// command through once the cooldown has passed
+func (t *transport) allow() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.openUntil.IsZero() {
+		return nil
+	}
+	if time.Now().Before(t.openUntil) || t.trial {
+		return ErrOffline
+	}
+	t.trial = true
+	return nil
+}
+
+// responded closes the breaker after the device answered
+func (t *transport) responded(ctx context.Context) {
+	t.mu.Lock()
+	reconnected := t.unreachable
+	t.failures, t.openUntil, t.trial, t.unreachable = 0, time.Time{}, false, false
+	onReconnect := t.onReconnect
+	t.mu.Unlock()
+
+	// The device may have rebooted while we could not reach it
+	if reconnected && onReconnect != nil {
+		onReconnect(ctx)
+	}
+}
+
+func (t *transport) failed() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.failures++
+	t.unreachable = true
+	t.trial = false
+	if t.breaker.Threshold > 0 && t.failures >= t.breaker.Threshold {
+		t.openUntil = time.Now().Add(t.breaker.Cooldown)
+	}
+}
+
+// canceled lets another trial command through when the caller abandoned
+// this one, without counting it as a failure
+func (t *transport) canceled() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.trial = false
+}
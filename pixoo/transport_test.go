@@ -0,0 +1,321 @@
+package pixoo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeDevice is an httptest stand-in for a panel that records every
+// command it receives
+type fakeDevice struct {
+	srv *httptest.Server
+	// status is the HTTP status to answer with; 0 means 200
+	status atomic.Int32
+	// hold, when set, makes requests wait until it is closed or the
+	// request is abandoned
+	hold atomic.Pointer[chan struct{}]
+
+	mu     sync.Mutex
+	bodies [][]byte
+	conf   string
+}
+
+func newFakeDevice(t *testing.T) *fakeDevice {
+	t.Helper()
+	d := &fakeDevice{conf: `{"error_code":0,"Brightness":50,"SelectIndex":3}`}
+	d.srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		d.mu.Lock()
+		d.bodies = append(d.bodies, body)
+		conf := d.conf
+		d.mu.Unlock()
+
+		if hold := d.hold.Load(); hold != nil {
+			select {
+			case <-*hold:
+			case <-r.Context().Done():
+				return
+			}
+		}
+		if status := d.status.Load(); status != 0 {
+			w.WriteHeader(int(status))
+			return
+		}
+		w.Write([]byte(conf))
+	}))
+	t.Cleanup(d.srv.Close)
+	return d
+}
+
+// client returns a Client for the device that retries without waiting
+func (d *fakeDevice) client(t *testing.T, retries int, breaker BreakerConfig) *Client {
+	t.Helper()
+	c := NewClient(strings.TrimPrefix(d.srv.URL, "http://"))
+	c.SetRetryPolicy(RetryPolicy{MaxRetries: retries, BaseDelay: time.Millisecond})
+	c.SetBreaker(breaker)
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+// commands returns the Command of every request received so far
+func (d *fakeDevice) commands() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	names := make([]string, len(d.bodies))
+	for i, b := range d.bodies {
+		var cmd struct{ Command string }
+		json.Unmarshal(b, &cmd)
+		names[i] = cmd.Command
+	}
+	return names
+}
+
+func (d *fakeDevice) requests() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return len(d.bodies)
+}
+
+func TestRetryPolicyDelay(t *testing.T) {
+	p := RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+	for attempt, want := range map[int]time.Duration{
+		1: 100 * time.Millisecond,
+		2: 200 * time.Millisecond,
+		3: 400 * time.Millisecond,
+		4: 800 * time.Millisecond,
+		5: time.Second,
+		9: time.Second,
+	} {
+		if got := p.delay(attempt); got != want {
+			t.Errorf("delay(%d) = %v, want %v", attempt, got, want)
+		}
+	}
+
+	p.Jitter = 0.2
+	lo, hi := 80*time.Millisecond, 120*time.Millisecond
+	below, above := false, false
+	for i := 0; i < 1000; i++ {
+		d := p.delay(1)
+		if d < lo || d > hi {
+			t.Fatalf("delay(1) = %v with 20%% jitter, want within [%v, %v]", d, lo, hi)
+		}
+		below = below || d < p.BaseDelay
+		above = above || d > p.BaseDelay
+	}
+	if !below || !above {
+		t.Errorf("jitter only went one way (below %v, above %v)", below, above)
+	}
+	// Jitter applies after the cap
+	if d := p.delay(9); d < 800*time.Millisecond || d > 1200*time.Millisecond {
+		t.Errorf("delay(9) = %v, want within 20%% of 1s", d)
+	}
+}
+
+func TestTransportRetries(t *testing.T) {
+	dev := newFakeDevice(t)
+	c := dev.client(t, 2, BreakerConfig{})
+
+	// Fails twice, then the device recovers within the retries
+	var calls atomic.Int32
+	dev.status.Store(http.StatusServiceUnavailable)
+	send := func(ctx context.Context, data []byte) ([]byte, error) {
+		if calls.Add(1) == 3 {
+			dev.status.Store(0)
+		}
+		return c.send(ctx, data)
+	}
+	if _, err := c.transport.doWith(context.Background(), brightnessCommand(10), send); err != nil {
+		t.Fatalf("command failed despite recovering on the last retry: %v", err)
+	}
+	if n := dev.requests(); n != 3 {
+		t.Errorf("device got %d requests, want 3", n)
+	}
+	st := c.Stats().Commands["Channel/SetBrightness"]
+	if st.Requests != 3 || st.Failures != 2 {
+		t.Errorf("stats = %d requests, %d failures, want 3 and 2", st.Requests, st.Failures)
+	}
+
+	// Gives up after MaxRetries
+	dev.status.Store(http.StatusInternalServerError)
+	if err := c.SetBrightness(20); err == nil || !strings.Contains(err.Error(), "500") {
+		t.Fatalf("err = %v, want the last status", err)
+	}
+	if n := dev.requests(); n != 6 {
+		t.Errorf("device got %d requests, want 3 more", n)
+	}
+}
+
+func TestTransportPermanentErrors(t *testing.T) {
+	dev := newFakeDevice(t)
+	c := dev.client(t, 2, BreakerConfig{Threshold: 2, Cooldown: time.Minute})
+
+	dev.status.Store(http.StatusBadRequest)
+	for i := 0; i < 4; i++ {
+		err := c.ClearScreen()
+		if !IsPermanent(err) {
+			t.Fatalf("call %d: err = %v, want permanent", i, err)
+		}
+	}
+	// One request per call: no retries, and the breaker stays closed
+	if n := dev.requests(); n != 4 {
+		t.Errorf("device got %d requests, want 4", n)
+	}
+
+	// Invalid arguments never reach the device
+	if err := c.SetBrightness(200); !IsPermanent(err) {
+		t.Errorf("SetBrightness(200) = %v, want permanent", err)
+	}
+	if n := dev.requests(); n != 4 {
+		t.Errorf("invalid brightness was sent")
+	}
+}
+
+func TestTransportBreaker(t *testing.T) {
+	const cooldown = 50 * time.Millisecond
+	dev := newFakeDevice(t)
+	c := dev.client(t, 0, BreakerConfig{Threshold: 2, Cooldown: cooldown})
+
+	// Closed: failures reach the device until the threshold
+	dev.status.Store(http.StatusInternalServerError)
+	for i := 0; i < 2; i++ {
+		if err := c.ClearScreen(); err == nil || errors.Is(err, ErrOffline) {
+			t.Fatalf("call %d: err = %v, want a device error", i, err)
+		}
+	}
+
+	// Open: fail fast without contacting the device
+	if err := c.ClearScreen(); !errors.Is(err, ErrOffline) {
+		t.Fatalf("err = %v, want ErrOffline", err)
+	}
+	if n := dev.requests(); n != 2 {
+		t.Fatalf("device got %d requests while open, want 2", n)
+	}
+
+	// Trial after the cooldown; a failed trial opens the breaker again
+	time.Sleep(cooldown + 10*time.Millisecond)
+	if err := c.ClearScreen(); err == nil || errors.Is(err, ErrOffline) {
+		t.Fatalf("trial err = %v, want a device error", err)
+	}
+	if err := c.ClearScreen(); !errors.Is(err, ErrOffline) {
+		t.Fatalf("err after failed trial = %v, want ErrOffline", err)
+	}
+
+	// Only one trial at a time: others fail fast while it is in flight
+	time.Sleep(cooldown + 10*time.Millisecond)
+	dev.status.Store(0)
+	hold := make(chan struct{})
+	dev.hold.Store(&hold)
+	trial := make(chan error, 1)
+	go func() { trial <- c.ClearScreen() }()
+	for dev.requests() < 4 {
+		time.Sleep(time.Millisecond)
+	}
+	if err := c.ClearScreen(); !errors.Is(err, ErrOffline) {
+		t.Errorf("err during trial = %v, want ErrOffline", err)
+	}
+	dev.hold.Store(nil)
+	close(hold)
+
+	// A successful trial closes the breaker
+	if err := <-trial; err != nil {
+		t.Fatalf("trial failed: %v", err)
+	}
+	before := dev.requests()
+	for i := 0; i < 3; i++ {
+		if err := c.ClearScreen(); err != nil {
+			t.Fatalf("err after close = %v", err)
+		}
+	}
+	if got := dev.requests() - before; got < 3 {
+		t.Errorf("device got %d requests after closing, want at least 3", got)
+	}
+}
+
+func TestTransportCancelIsNotAFailure(t *testing.T) {
+	dev := newFakeDevice(t)
+	c := dev.client(t, 2, BreakerConfig{Threshold: 1, Cooldown: time.Minute})
+
+	hold := make(chan struct{})
+	defer close(hold)
+	dev.hold.Store(&hold)
+	for i := 0; i < 3; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		err := c.ClearScreenContext(ctx)
+		cancel()
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("call %d: err = %v, want the context's error", i, err)
+		}
+	}
+	dev.hold.Store(nil)
+
+	// A threshold of 1 would have opened the breaker on any counted failure
+	if err := c.ClearScreen(); err != nil {
+		t.Fatalf("err = %v, want the device to be reachable", err)
+	}
+	if st := c.Stats().Commands["Draw/ResetHttpGifId"]; st.Requests != 4 {
+		t.Errorf("%d requests recorded, want one per call", st.Requests)
+	}
+}
+
+func TestTransportReappliesSettingsOnReconnect(t *testing.T) {
+	dev := newFakeDevice(t)
+	dev.conf = `{"error_code":0,"Brightness":40,"SelectIndex":3}`
+	c := dev.client(t, 0, BreakerConfig{})
+
+	if err := c.SetBrightness(40); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.SetChannel(3); err != nil {
+		t.Fatal(err)
+	}
+
+	dev.status.Store(http.StatusBadGateway)
+	if err := c.ClearScreen(); err == nil {
+		t.Fatal("ClearScreen succeeded against a failing device")
+	}
+	dev.status.Store(0)
+	if err := c.ClearScreen(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"Channel/SetBrightness", "Channel/SetIndex",
+		"Draw/ResetHttpGifId", // failed
+		"Draw/ResetHttpGifId", "Channel/SetBrightness", "Channel/SetIndex",
+	}
+	if got := dev.commands(); strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("commands = %v, want %v", got, want)
+	}
+	var last struct{ Brightness int }
+	dev.mu.Lock()
+	json.Unmarshal(dev.bodies[4], &last)
+	dev.mu.Unlock()
+	if last.Brightness != 40 {
+		t.Errorf("re-applied brightness %d, want 40", last.Brightness)
+	}
+
+	// A device that rebooted unnoticed reports its defaults
+	rebooted, err := c.CheckReboot()
+	if err != nil || rebooted {
+		t.Fatalf("CheckReboot = %v, %v with matching settings, want false", rebooted, err)
+	}
+	dev.mu.Lock()
+	dev.conf = `{"error_code":0,"Brightness":100,"SelectIndex":0}`
+	dev.mu.Unlock()
+	before := dev.requests()
+	if rebooted, err = c.CheckReboot(); err != nil || !rebooted {
+		t.Fatalf("CheckReboot = %v, %v after a reboot, want true", rebooted, err)
+	}
+	if got := dev.commands()[before:]; strings.Join(got, " ") != "Channel/GetAllConf Channel/GetIndex Channel/SetBrightness Channel/SetIndex" {
+		t.Errorf("commands = %v, want settings read and re-applied", got)
+	}
+}
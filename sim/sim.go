@@ -0,0 +1,306 @@
+// Package sim simulates a panel in the browser: frames sent to a
+// Simulator are pushed to every open page over server-sent events and
+// drawn as a grid of LEDs, so people without a panel can watch it live.
+package sim
+
+import (
+	"context"
+	_ "embed"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"divoom-monitor/output"
+	"divoom-monitor/pixoo"
+)
+
+// Host is the -host value that sends frames to a simulator instead of a
+// device
+const Host = "sim"
+
+// DefaultAddr is where the simulator is served unless told otherwise
+const DefaultAddr = ":8064"
+
+// keepAlive is how often an idle event stream gets a comment, so proxies
+// do not close it
+const keepAlive = 15 * time.Second
+
+//go:embed index.html
+var indexHTML []byte
+
+// Simulator keeps the current frame and brightness and streams them to
+// browsers. It implements pixoo.PixooClient and http.Handler.
+type Simulator struct {
+	mux *http.ServeMux
+	srv *http.Server
+	ln  net.Listener
+
+	mu         sync.Mutex
+	frame      *pixoo.Canvas
+	encoded    string
+	brightness int
+	// seq counts frames so a stream only resends a changed one
+	seq    uint64
+	subs   map[chan struct{}]struct{}
+	closed bool
+	done   chan struct{}
+}
+
+// New creates a simulator showing a black frame. Serve it with
+// ServeHTTP, or use Start.
+func New() *Simulator {
+	s := &Simulator{
+		mux:        http.NewServeMux(),
+		frame:      pixoo.NewCanvas(),
+		brightness: 100,
+		seq:        1,
+		subs:       make(map[chan struct{}]struct{}),
+		done:       make(chan struct{}),
+	}
+	s.encoded = base64.StdEncoding.EncodeToString(s.frame.Pix)
+
+	s.mux.HandleFunc("GET /{$}", s.handleIndex)
+	s.mux.HandleFunc("GET /events", s.handleEvents)
+	s.mux.HandleFunc("GET /frame.png", s.handleFrame)
+	return s
+}
+
+// Start creates a simulator and serves it on addr in the background
+func Start(addr string) (*Simulator, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("listen for simulator: %w", err)
+	}
+	s := New()
+	s.ln = ln
+	s.srv = &http.Server{Handler: s}
+	go s.srv.Serve(ln)
+	return s, nil
+}
+
+// URL returns the page to open in a browser when the simulator was
+// started with Start
+func (s *Simulator) URL() string {
+	if s.ln == nil {
+		return ""
+	}
+	host, port, err := net.SplitHostPort(s.ln.Addr().String())
+	if err != nil {
+		return "http://" + s.ln.Addr().String() + "/"
+	}
+	if ip := net.ParseIP(host); ip == nil || ip.IsUnspecified() {
+		host = "localhost"
+	}
+	return "http://" + net.JoinHostPort(host, port) + "/"
+}
+
+func (s *Simulator) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// Frame returns a copy of the current frame
+func (s *Simulator) Frame() *pixoo.Canvas {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c := pixoo.NewCanvas()
+	c.CopyFrom(s.frame)
+	return c
+}
+
+// DrawImage shows a 64x64 frame
+func (s *Simulator) DrawImage(img image.Image) error {
+	return s.DrawImageContext(context.Background(), img)
+}
+
+// DrawImageContext shows a 64x64 frame; ctx is only checked before
+// starting
+func (s *Simulator) DrawImageContext(ctx context.Context, img image.Image) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	b := img.Bounds()
+	if b.Dx() != 64 || b.Dy() != 64 {
+		return pixoo.Permanent(fmt.Errorf("image must be 64x64 pixels"))
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return errors.New("simulator closed")
+	}
+	if c, ok := img.(*pixoo.Canvas); ok {
+		s.frame.CopyFrom(c)
+	} else {
+		draw.Draw(s.frame, s.frame.Bounds(), img, b.Min, draw.Src)
+	}
+	s.encoded = base64.StdEncoding.EncodeToString(s.frame.Pix)
+	s.seq++
+	s.notifyLocked()
+	return nil
+}
+
+// DrawText renders text with the built-in font and shows it as a frame
+func (s *Simulator) DrawText(text string, r, g, b uint8) error {
+	return s.DrawTextContext(context.Background(), text, r, g, b)
+}
+
+// DrawTextContext is DrawText bounded by ctx
+func (s *Simulator) DrawTextContext(ctx context.Context, text string, r, g, b uint8) error {
+	return s.DrawImageContext(ctx, pixoo.RenderText(text, color.RGBA{r, g, b, 255}))
+}
+
+// ClearScreen shows a black frame
+func (s *Simulator) ClearScreen() error {
+	return s.ClearScreenContext(context.Background())
+}
+
+// ClearScreenContext is ClearScreen bounded by ctx
+func (s *Simulator) ClearScreenContext(ctx context.Context) error {
+	return s.DrawImageContext(ctx, pixoo.NewCanvas())
+}
+
+// SetBrightness sets the brightness (0-100) the page dims the LEDs to
+func (s *Simulator) SetBrightness(brightness int) error {
+	return s.SetBrightnessContext(context.Background(), brightness)
+}
+
+// SetBrightnessContext is SetBrightness bounded by ctx
+func (s *Simulator) SetBrightnessContext(ctx context.Context, brightness int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if brightness < 0 || brightness > 100 {
+		return pixoo.Permanent(fmt.Errorf("brightness must be between 0 and 100"))
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.brightness = brightness
+	s.notifyLocked()
+	return nil
+}
+
+// notifyLocked wakes every event stream. A stream that is still busy
+// keeps its pending wake-up and sends the newest state when it is done.
+func (s *Simulator) notifyLocked() {
+	for ch := range s.subs {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Close ends the event streams and, if started with Start, stops the
+// server. Frames drawn afterwards are rejected.
+func (s *Simulator) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	close(s.done)
+	s.mu.Unlock()
+
+	if s.srv == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	return s.srv.Shutdown(ctx)
+}
+
+func (s *Simulator) handleIndex(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(indexHTML)
+}
+
+// handleEvents streams "frame" events carrying the base64 RGB888 pixels
+// and "brightness" events. A new stream gets the current state first;
+// afterwards, frames drawn faster than the browser reads are skipped.
+func (s *Simulator) handleEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-store")
+
+	wake := make(chan struct{}, 1)
+	wake <- struct{}{}
+	s.mu.Lock()
+	s.subs[wake] = struct{}{}
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.subs, wake)
+		s.mu.Unlock()
+	}()
+
+	ticker := time.NewTicker(keepAlive)
+	defer ticker.Stop()
+
+	var sentSeq uint64
+	sentBrightness := -1
+	for {
+		select {
+		case <-wake:
+		case <-ticker.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+			continue
+		case <-r.Context().Done():
+			return
+		case <-s.done:
+			return
+		}
+
+		s.mu.Lock()
+		seq, frame, brightness := s.seq, s.encoded, s.brightness
+		s.mu.Unlock()
+
+		if brightness != sentBrightness {
+			if _, err := fmt.Fprintf(w, "event: brightness\ndata: %d\n\n", brightness); err != nil {
+				return
+			}
+			sentBrightness = brightness
+		}
+		if seq != sentSeq {
+			if _, err := fmt.Fprintf(w, "event: frame\ndata: %s\n\n", frame); err != nil {
+				return
+			}
+			sentSeq = seq
+		}
+		flusher.Flush()
+	}
+}
+
+// handleFrame returns the current frame as LED dots, at the optional
+// scale query parameter (3-16, default 8)
+func (s *Simulator) handleFrame(w http.ResponseWriter, r *http.Request) {
+	scale := 8
+	if v := r.URL.Query().Get("scale"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 3 || n > 16 {
+			http.Error(w, "scale must be between 3 and 16", http.StatusBadRequest)
+			return
+		}
+		scale = n
+	}
+
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Cache-Control", "no-store")
+	png.Encode(w, output.Upscale(s.Frame(), output.Options{Scale: scale, LED: true}))
+}
@@ -0,0 +1,188 @@
+package sim
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"image/color"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"divoom-monitor/pixoo"
+)
+
+// event is one server-sent event
+type event struct {
+	name, data string
+}
+
+// openEvents subscribes to the simulator's event stream and returns its
+// events as they arrive
+func openEvents(t *testing.T, url string) <-chan event {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, url+"/events", nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Content-Type = %q", ct)
+	}
+
+	events := make(chan event)
+	go func() {
+		defer resp.Body.Close()
+		defer close(events)
+		var ev event
+		scanner := bufio.NewScanner(resp.Body)
+		scanner.Buffer(nil, 1<<20)
+		for scanner.Scan() {
+			line := scanner.Text()
+			switch {
+			case line == "":
+				if ev.name != "" {
+					select {
+					case events <- ev:
+					case <-ctx.Done():
+						return
+					}
+				}
+				ev = event{}
+			case strings.HasPrefix(line, "event: "):
+				ev.name = strings.TrimPrefix(line, "event: ")
+			case strings.HasPrefix(line, "data: "):
+				ev.data = strings.TrimPrefix(line, "data: ")
+			}
+		}
+	}()
+	return events
+}
+
+func next(t *testing.T, events <-chan event) event {
+	t.Helper()
+	select {
+	case ev, ok := <-events:
+		if !ok {
+			t.Fatal("event stream ended")
+		}
+		return ev
+	case <-time.After(2 * time.Second):
+		t.Fatal("no event within 2s")
+	}
+	return event{}
+}
+
+func decodeFrame(t *testing.T, data string) *pixoo.Canvas {
+	t.Helper()
+	pix, err := base64.StdEncoding.DecodeString(data)
+	if err != nil || len(pix) != 64*64*3 {
+		t.Fatalf("frame data has %d bytes, err %v", len(pix), err)
+	}
+	return &pixoo.Canvas{Pix: pix}
+}
+
+func TestSimulatorEvents(t *testing.T) {
+	s := New()
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+	defer s.Close()
+
+	events := openEvents(t, srv.URL)
+	// A new stream starts with the current state
+	if ev := next(t, events); ev.name != "brightness" || ev.data != "100" {
+		t.Errorf("first event = %+v, want brightness 100", ev)
+	}
+	ev := next(t, events)
+	if ev.name != "frame" {
+		t.Fatalf("second event = %q, want frame", ev.name)
+	}
+	if r, g, b := decodeFrame(t, ev.data).RGBAt(0, 0); r|g|b != 0 {
+		t.Errorf("initial frame is not black")
+	}
+
+	img := pixoo.CreateImage()
+	pixoo.FillRect(img, 0, 0, 64, 64, color.RGBA{255, 0, 0, 255})
+	img.Set(63, 63, color.RGBA{0, 255, 0, 255})
+	if err := s.DrawImage(img); err != nil {
+		t.Fatal(err)
+	}
+	ev = next(t, events)
+	if ev.name != "frame" {
+		t.Fatalf("event after DrawImage = %q, want frame", ev.name)
+	}
+	frame := decodeFrame(t, ev.data)
+	if r, g, b := frame.RGBAt(0, 0); r != 255 || g != 0 || b != 0 {
+		t.Errorf("pixel 0,0 = %d %d %d, want red", r, g, b)
+	}
+	if r, g, b := frame.RGBAt(63, 63); r != 0 || g != 255 || b != 0 {
+		t.Errorf("pixel 63,63 = %d %d %d, want green", r, g, b)
+	}
+
+	// Brightness alone does not resend the frame
+	if err := s.SetBrightness(30); err != nil {
+		t.Fatal(err)
+	}
+	if ev := next(t, events); ev.name != "brightness" || ev.data != "30" {
+		t.Errorf("event after SetBrightness = %+v, want brightness 30", ev)
+	}
+	if err := s.SetBrightness(101); !pixoo.IsPermanent(err) {
+		t.Errorf("brightness 101 gave %v, want a permanent error", err)
+	}
+
+	// Closing ends the stream
+	s.Close()
+	select {
+	case _, ok := <-events:
+		if ok {
+			t.Error("event after Close")
+		}
+	case <-time.After(2 * time.Second):
+		t.Error("stream still open after Close")
+	}
+	if err := s.DrawImage(img); err == nil {
+		t.Error("drawing after Close succeeded")
+	}
+}
+
+func TestSimulatorFramePNG(t *testing.T) {
+	s := New()
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+
+	canvas := pixoo.NewCanvas()
+	canvas.Fill(0, 0, 255)
+	s.DrawImage(canvas)
+
+	resp, err := http.Get(srv.URL + "/frame.png?scale=4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	img, err := png.Decode(resp.Body)
+	if err != nil {
+		t.Fatalf("decode frame.png: %v", err)
+	}
+	if b := img.Bounds(); b.Dx() != 256 || b.Dy() != 256 {
+		t.Errorf("frame.png is %v, want 256x256", b)
+	}
+	if r, g, b, _ := img.At(2, 2).RGBA(); r != 0 || g != 0 || b>>8 != 255 {
+		t.Errorf("dot color = %d %d %d, want blue", r>>8, g>>8, b>>8)
+	}
+
+	for _, scale := range []string{"2", "17", "x"} {
+		resp, err := http.Get(srv.URL + "/frame.png?scale=" + scale)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("scale %s: status %d, want 400", scale, resp.StatusCode)
+		}
+	}
+}
@@ -0,0 +1,373 @@
+// Package webhook turns Alertmanager, GitHub Actions and generic JSON
+// webhooks into panel notifications. Each endpoint maps its payload
+// through configurable templates and clears the notification again when
+// the event resolves, so the panel can act as a build or alert light.
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"divoom-monitor/notify"
+)
+
+// maxBody limits the size of webhook payloads
+const maxBody = 5 << 20
+
+// Notifier receives the notifications; control.Display satisfies it
+type Notifier interface {
+	ShowMessage(msg notify.Message) error
+	ClearMessage(key string) bool
+}
+
+// Alert is the data passed to Alertmanager templates, one per alert in
+// the notification
+type Alert struct {
+	Status       string // "firing" or "resolved"
+	Name         string // the alertname label
+	Severity     string // the severity label
+	Labels       map[string]string
+	Annotations  map[string]string
+	Fingerprint  string
+	GeneratorURL string
+	StartsAt     time.Time
+	// CommonLabels are the labels shared by every alert in the group
+	CommonLabels map[string]string
+}
+
+// Build is the data passed to GitHub templates for workflow_run and
+// workflow_job events
+type Build struct {
+	Event      string // "workflow_run" or "workflow_job"
+	Repo       string // owner/name
+	Workflow   string
+	Job        string // empty for workflow_run
+	Branch     string
+	Status     string // queued, in_progress or completed
+	Conclusion string // success, failure, cancelled, ... once completed
+	Failed     bool   // conclusion is failure, timed_out or startup_failure
+	Actor      string
+	URL        string
+}
+
+// Result summarizes what a webhook call did
+type Result struct {
+	Shown   int `json:"shown"`
+	Cleared int `json:"cleared"`
+	Ignored int `json:"ignored"`
+}
+
+// Handler serves the webhook endpoints:
+//
+//	POST /webhook/alertmanager
+//	POST /webhook/github
+//	POST /webhook/{name}   generic templates from Config.Generic
+type Handler struct {
+	notifier     Notifier
+	alertmanager *compiled
+	github       *compiled
+	secret       string
+	generic      map[string]*compiled
+	mux          *http.ServeMux
+}
+
+// NewHandler compiles the templates in cfg
+func NewHandler(cfg Config, notifier Notifier) (*Handler, error) {
+	h := &Handler{
+		notifier: notifier,
+		secret:   cfg.GitHub.Secret,
+		generic:  make(map[string]*compiled),
+		mux:      http.NewServeMux(),
+	}
+
+	var err error
+	if h.alertmanager, err = compile("alertmanager", cfg.Alertmanager); err != nil {
+		return nil, err
+	}
+	if h.github, err = compile("github", cfg.GitHub.Template); err != nil {
+		return nil, err
+	}
+	for name, t := range cfg.Generic {
+		if name == "alertmanager" || name == "github" {
+			return nil, fmt.Errorf("generic webhook %q clashes with a built-in endpoint", name)
+		}
+		if h.generic[name], err = compile(name, t); err != nil {
+			return nil, err
+		}
+	}
+
+	h.mux.HandleFunc("POST /webhook/alertmanager", h.handleAlertmanager)
+	h.mux.HandleFunc("POST /webhook/github", h.handleGitHub)
+	h.mux.HandleFunc("POST /webhook/{name}", h.handleGeneric)
+	return h, nil
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+// alertmanagerPayload is the Alertmanager webhook body (version 4)
+type alertmanagerPayload struct {
+	Status       string            `json:"status"`
+	CommonLabels map[string]string `json:"commonLabels"`
+	Alerts       []struct {
+		Status       string            `json:"status"`
+		Labels       map[string]string `json:"labels"`
+		Annotations  map[string]string `json:"annotations"`
+		StartsAt     time.Time         `json:"startsAt"`
+		GeneratorURL string            `json:"generatorURL"`
+		Fingerprint  string            `json:"fingerprint"`
+	} `json:"alerts"`
+}
+
+func (h *Handler) handleAlertmanager(w http.ResponseWriter, r *http.Request) {
+	var payload alertmanagerPayload
+	if err := json.NewDecoder(io.LimitReader(r.Body, maxBody)).Decode(&payload); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("decode payload: %w", err))
+		return
+	}
+
+	// Render every alert before touching the display, so a template error
+	// rejects the whole notification rather than leaving it half applied
+	// for Alertmanager to send again
+	actions := make([]action, 0, len(payload.Alerts))
+	for _, a := range payload.Alerts {
+		alert := Alert{
+			Status:       a.Status,
+			Name:         a.Labels["alertname"],
+			Severity:     a.Labels["severity"],
+			Labels:       a.Labels,
+			Annotations:  a.Annotations,
+			Fingerprint:  a.Fingerprint,
+			GeneratorURL: a.GeneratorURL,
+			StartsAt:     a.StartsAt,
+			CommonLabels: payload.CommonLabels,
+		}
+		if alert.Status == "" {
+			alert.Status = payload.Status
+		}
+		act, err := prepare(h.alertmanager, alert)
+		if err != nil {
+			writeError(w, http.StatusUnprocessableEntity, fmt.Errorf("alert %q: %w", alert.Name, err))
+			return
+		}
+		actions = append(actions, act)
+	}
+
+	var res Result
+	for _, act := range actions {
+		if err := h.apply(act, &res); err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+	}
+	writeResult(w, res)
+}
+
+// githubPayload holds the fields of workflow_run and workflow_job events
+// that Build exposes
+type githubPayload struct {
+	Repository struct {
+		FullName string `json:"full_name"`
+	} `json:"repository"`
+	Sender struct {
+		Login string `json:"login"`
+	} `json:"sender"`
+	WorkflowRun *struct {
+		Name       string `json:"name"`
+		HeadBranch string `json:"head_branch"`
+		Status     string `json:"status"`
+		Conclusion string `json:"conclusion"`
+		HTMLURL    string `json:"html_url"`
+	} `json:"workflow_run"`
+	WorkflowJob *struct {
+		Name         string `json:"name"`
+		WorkflowName string `json:"workflow_name"`
+		HeadBranch   string `json:"head_branch"`
+		Status       string `json:"status"`
+		Conclusion   string `json:"conclusion"`
+		HTMLURL      string `json:"html_url"`
+	} `json:"workflow_job"`
+}
+
+func (h *Handler) handleGitHub(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxBody))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("read payload: %w", err))
+		return
+	}
+	if h.secret != "" && !validSignature(h.secret, body, r.Header.Get("X-Hub-Signature-256")) {
+		writeError(w, http.StatusUnauthorized, fmt.Errorf("invalid signature"))
+		return
+	}
+
+	event := r.Header.Get("X-GitHub-Event")
+	if event != "workflow_run" && event != "workflow_job" {
+		// ping and anything else we don't display
+		writeResult(w, Result{Ignored: 1})
+		return
+	}
+
+	var payload githubPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("decode payload: %w", err))
+		return
+	}
+
+	build := Build{
+		Event: event,
+		Repo:  payload.Repository.FullName,
+		Actor: payload.Sender.Login,
+	}
+	switch {
+	case event == "workflow_run" && payload.WorkflowRun != nil:
+		run := payload.WorkflowRun
+		build.Workflow, build.Branch = run.Name, run.HeadBranch
+		build.Status, build.Conclusion, build.URL = run.Status, run.Conclusion, run.HTMLURL
+	case event == "workflow_job" && payload.WorkflowJob != nil:
+		job := payload.WorkflowJob
+		build.Workflow, build.Job, build.Branch = job.WorkflowName, job.Name, job.HeadBranch
+		build.Status, build.Conclusion, build.URL = job.Status, job.Conclusion, job.HTMLURL
+	default:
+		writeError(w, http.StatusBadRequest, fmt.Errorf("%s payload is missing its %s object", event, event))
+		return
+	}
+	switch build.Conclusion {
+	case "failure", "timed_out", "startup_failure":
+		build.Failed = true
+	}
+
+	act, err := prepare(h.github, build)
+	if err != nil {
+		writeError(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	var res Result
+	if err := h.apply(act, &res); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeResult(w, res)
+}
+
+// validSignature checks a GitHub "sha256=<hex hmac>" signature header
+func validSignature(secret string, body []byte, header string) bool {
+	sig, ok := strings.CutPrefix(header, "sha256=")
+	if !ok {
+		return false
+	}
+	got, err := hex.DecodeString(sig)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(got, mac.Sum(nil))
+}
+
+func (h *Handler) handleGeneric(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	tmpl, ok := h.generic[name]
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Errorf("unknown webhook %q", name))
+		return
+	}
+
+	var payload interface{}
+	if err := json.NewDecoder(io.LimitReader(r.Body, maxBody)).Decode(&payload); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("decode payload: %w", err))
+		return
+	}
+
+	act, err := prepare(tmpl, payload)
+	if err != nil {
+		writeError(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	var res Result
+	if err := h.apply(act, &res); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeResult(w, res)
+}
+
+// action is what one event does to the display once its templates have
+// rendered: show msg, clear the message with key clear, or nothing
+type action struct {
+	msg   *notify.Message
+	clear string
+}
+
+// prepare renders tmpl for one event and decides what it does, without
+// touching the display
+func prepare(tmpl *compiled, data interface{}) (action, error) {
+	req, err := tmpl.request(data)
+	if err != nil {
+		return action{}, err
+	}
+
+	resolve, err := isTrue(tmpl.resolve, data)
+	if err != nil {
+		return action{}, err
+	}
+	if resolve {
+		key := req.Key
+		if key == "" {
+			key = req.Text
+		}
+		return action{clear: key}, nil
+	}
+
+	if tmpl.when != nil {
+		show, err := isTrue(tmpl.when, data)
+		if err != nil {
+			return action{}, err
+		}
+		if !show {
+			return action{}, nil
+		}
+	}
+
+	msg, err := req.Message()
+	if err != nil {
+		return action{}, err
+	}
+	return action{msg: &msg}, nil
+}
+
+// apply shows, clears or ignores a prepared event
+func (h *Handler) apply(act action, res *Result) error {
+	switch {
+	case act.msg != nil:
+		if err := h.notifier.ShowMessage(*act.msg); err != nil {
+			return fmt.Errorf("show message: %w", err)
+		}
+		res.Shown++
+	case act.clear != "" && h.notifier.ClearMessage(act.clear):
+		log.Printf("Webhook cleared message %q", act.clear)
+		res.Cleared++
+	default:
+		res.Ignored++
+	}
+	return nil
+}
+
+func writeResult(w http.ResponseWriter, res Result) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(res)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+}
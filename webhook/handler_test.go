@@ -0,0 +1,83 @@
+package webhook
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"divoom-monitor/notify"
+)
+
+// fakeNotifier records the messages shown and the keys cleared
+type fakeNotifier struct {
+	shown   []notify.Message
+	cleared []string
+}
+
+func (n *fakeNotifier) ShowMessage(msg notify.Message) error {
+	n.shown = append(n.shown, msg)
+	return nil
+}
+
+func (n *fakeNotifier) ClearMessage(key string) bool {
+	n.cleared = append(n.cleared, key)
+	return true
+}
+
+func post(t *testing.T, h http.Handler, path, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, path, strings.NewReader(body)))
+	return rec
+}
+
+func TestAlertmanagerShowsAndClears(t *testing.T) {
+	n := &fakeNotifier{}
+	h, err := NewHandler(DefaultConfig(), n)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rec := post(t, h, "/webhook/alertmanager", `{"status":"firing","alerts":[
+		{"status":"firing","labels":{"alertname":"DiskFull","instance":"db1","severity":"critical"}},
+		{"status":"resolved","labels":{"alertname":"HighLoad","instance":"web1"}}
+	]}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	if len(n.shown) != 1 || n.shown[0].Text != "DiskFull" || n.shown[0].Key != "alertmanager-DiskFull-db1" {
+		t.Errorf("shown = %+v, want DiskFull keyed by instance", n.shown)
+	}
+	if len(n.cleared) != 1 || n.cleared[0] != "alertmanager-HighLoad-web1" {
+		t.Errorf("cleared = %v, want [alertmanager-HighLoad-web1]", n.cleared)
+	}
+}
+
+func TestAlertmanagerRejectsWholePayloadOnTemplateError(t *testing.T) {
+	cfg := DefaultConfig()
+	// Renders an unknown color for anything but critical alerts
+	cfg.Alertmanager.Color = `{{if eq .Severity "critical"}}red{{else}}chartreuse{{end}}`
+	n := &fakeNotifier{}
+	h, err := NewHandler(cfg, n)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rec := post(t, h, "/webhook/alertmanager", `{"status":"firing","alerts":[
+		{"status":"firing","labels":{"alertname":"DiskFull","severity":"critical"}},
+		{"status":"resolved","labels":{"alertname":"Stale","severity":"critical"}},
+		{"status":"firing","labels":{"alertname":"HighLoad","severity":"warning"}}
+	]}`)
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("status %d, want %d", rec.Code, http.StatusUnprocessableEntity)
+	}
+	if !strings.Contains(rec.Body.String(), "HighLoad") {
+		t.Errorf("error %q does not name the failing alert", rec.Body)
+	}
+	// Alertmanager retries the whole notification, so nothing may have
+	// been applied yet
+	if len(n.shown) != 0 || len(n.cleared) != 0 {
+		t.Errorf("applied shown=%v cleared=%v before the error", n.shown, n.cleared)
+	}
+}
@@ -0,0 +1,190 @@
+package webhook
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+
+	"divoom-monitor/control"
+)
+
+// Template maps a webhook event to a notification. Every field is a
+// text/template executed against the event data; see Config for what
+// each endpoint passes in.
+type Template struct {
+	Text     string `json:"text"`
+	Scroll   string `json:"scroll"`
+	Key      string `json:"key"`
+	Color    string `json:"color"`
+	Icon     string `json:"icon"`
+	Priority string `json:"priority"`
+	TTL      string `json:"ttl"`
+
+	// When, if set, must render "true" for the event to show a message
+	When string `json:"when"`
+	// Resolve rendering "true" clears the message with the rendered Key
+	// instead of showing one
+	Resolve string `json:"resolve"`
+}
+
+// GitHubConfig is the template for GitHub Actions events plus the secret
+// used to verify their signatures
+type GitHubConfig struct {
+	Template
+	// Secret, if set, is checked against the X-Hub-Signature-256 header
+	Secret string `json:"secret"`
+}
+
+// Config holds the templates for each webhook endpoint.
+//
+// Alertmanager templates run once per alert with an Alert; GitHub
+// templates run with a Build; Generic templates run with the decoded
+// JSON body and are served at /webhook/{name}.
+type Config struct {
+	Alertmanager Template            `json:"alertmanager"`
+	GitHub       GitHubConfig        `json:"github"`
+	Generic      map[string]Template `json:"generic"`
+}
+
+// DefaultConfig shows firing alerts until they resolve and failed builds
+// until the next successful run of the same workflow on the same branch
+func DefaultConfig() Config {
+	return Config{
+		Alertmanager: Template{
+			Text:     `{{.Name}}`,
+			Scroll:   `{{or .Annotations.summary .Labels.instance}}`,
+			Key:      `alertmanager-{{.Name}}-{{.Labels.instance}}`,
+			Color:    `{{if eq .Severity "critical"}}red{{else}}orange{{end}}`,
+			Icon:     `warning`,
+			Priority: `{{if eq .Severity "critical"}}high{{else}}normal{{end}}`,
+			TTL:      `24h`,
+			Resolve:  `{{eq .Status "resolved"}}`,
+		},
+		GitHub: GitHubConfig{Template: Template{
+			Text:     `BUILD FAILED{{if le (len .Branch) 10}} {{.Branch}}{{end}}`,
+			Scroll:   `{{.Branch}}: {{.Repo}} {{.Workflow}}{{with .Job}} / {{.}}{{end}}`,
+			Key:      `github-{{.Repo}}-{{.Branch}}-{{.Workflow}}{{with .Job}}-{{.}}{{end}}`,
+			Color:    `red`,
+			Icon:     `cross`,
+			Priority: `high`,
+			TTL:      `24h`,
+			When:     `{{.Failed}}`,
+			Resolve:  `{{eq .Conclusion "success"}}`,
+		}},
+	}
+}
+
+// LoadConfig reads a JSON config file. Fields it leaves out keep their
+// defaults, so a file can override just the templates it cares about.
+func LoadConfig(path string) (Config, error) {
+	cfg := DefaultConfig()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return cfg, fmt.Errorf("read webhook config: %w", err)
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return cfg, fmt.Errorf("parse webhook config: %w", err)
+	}
+	return cfg, nil
+}
+
+var funcs = template.FuncMap{
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+	"default": func(def string, v interface{}) string {
+		if s := fmt.Sprint(v); v != nil && s != "" {
+			return s
+		}
+		return def
+	},
+}
+
+// compiled is a parsed Template
+type compiled struct {
+	fields  map[string]*template.Template
+	when    *template.Template
+	resolve *template.Template
+}
+
+func compile(name string, t Template) (*compiled, error) {
+	if t.Text == "" {
+		return nil, fmt.Errorf("%s: text template is required", name)
+	}
+
+	c := &compiled{fields: make(map[string]*template.Template)}
+	parse := func(field, text string) (*template.Template, error) {
+		if text == "" {
+			return nil, nil
+		}
+		tmpl, err := template.New(name + "." + field).Funcs(funcs).Option("missingkey=zero").Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("parse %s template: %w", field, err)
+		}
+		return tmpl, nil
+	}
+
+	for field, text := range map[string]string{
+		"text": t.Text, "scroll": t.Scroll, "key": t.Key, "color": t.Color,
+		"icon": t.Icon, "priority": t.Priority, "ttl": t.TTL,
+	} {
+		tmpl, err := parse(field, text)
+		if err != nil {
+			return nil, err
+		}
+		if tmpl != nil {
+			c.fields[field] = tmpl
+		}
+	}
+
+	var err error
+	if c.when, err = parse("when", t.When); err != nil {
+		return nil, err
+	}
+	if c.resolve, err = parse("resolve", t.Resolve); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// render executes tmpl on data; a nil template renders empty
+func render(tmpl *template.Template, data interface{}) (string, error) {
+	if tmpl == nil {
+		return "", nil
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("execute %s template: %w", tmpl.Name(), err)
+	}
+	return strings.TrimSpace(strings.ReplaceAll(buf.String(), "<no value>", "")), nil
+}
+
+// isTrue reports whether tmpl renders "true"
+func isTrue(tmpl *template.Template, data interface{}) (bool, error) {
+	s, err := render(tmpl, data)
+	return s == "true", err
+}
+
+// request renders the notification fields for data
+func (c *compiled) request(data interface{}) (control.MessageRequest, error) {
+	out := make(map[string]string, len(c.fields))
+	for field, tmpl := range c.fields {
+		s, err := render(tmpl, data)
+		if err != nil {
+			return control.MessageRequest{}, err
+		}
+		out[field] = s
+	}
+
+	return control.MessageRequest{
+		Text:     out["text"],
+		Scroll:   out["scroll"],
+		Key:      out["key"],
+		Color:    out["color"],
+		Icon:     out["icon"],
+		Priority: out["priority"],
+		TTL:      out["ttl"],
+	}, nil
+}